@@ -2,8 +2,8 @@ package main
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
-	"log"
 	"net/http"
 	"os"
 	"os/signal"
@@ -12,12 +12,28 @@ import (
 
 	"github.com/josestg/justforfun/internal/conf"
 
+	"github.com/josestg/justforfun/pkg/idempotency"
+
+	"github.com/josestg/justforfun/pkg/loadshed"
+
+	"github.com/josestg/justforfun/pkg/logx"
+
+	"github.com/josestg/justforfun/pkg/metrics"
+
+	"github.com/josestg/justforfun/pkg/mux"
+
 	"github.com/josestg/justforfun/pkg/pqx"
 
+	"github.com/josestg/justforfun/pkg/ratelimit"
+
+	"github.com/josestg/justforfun/pkg/tracing"
+
 	"github.com/josestg/justforfun/pkg/xerrs"
 
 	"github.com/josestg/justforfun/internal/domain/sys"
 
+	uHealth "github.com/josestg/justforfun/internal/usecase/health"
+
 	"github.com/josestg/justforfun/internal/delivery/restapi"
 )
 
@@ -35,6 +51,10 @@ func main() {
 	cfg := conf.New(
 		conf.WithRestAPIFromOSEnv(),
 		conf.WithDBPostgreFromOSEnv(),
+		conf.WithCORSFromOSEnv(),
+		conf.WithTracingFromOSEnv(),
+		conf.WithLogFromOSEnv(),
+		conf.WithHealthFromOSEnv(),
 	)
 
 	if err := run(cfg); err != nil {
@@ -45,10 +65,20 @@ func main() {
 }
 
 func run(c *conf.Config) error {
-	logger := log.New(os.Stdout, "HTTPD ", log.LstdFlags|log.Lmicroseconds|log.Lshortfile)
+	level, err := logx.ParseLevel(c.Log.Level)
+	if err != nil {
+		return xerrs.Wrap(err, "parse log level")
+	}
 
-	logger.Println("main:", "started")
-	defer logger.Println("main:", "stopped")
+	encoder, err := logx.NewEncoder(c.Log.Format)
+	if err != nil {
+		return xerrs.Wrap(err, "parse log format")
+	}
+
+	logger := logx.New(os.Stdout, logx.WithLevel(level), logx.WithEncoder(encoder)).With("app", "httpd")
+
+	logger.Info("main: started")
+	defer logger.Info("main: stopped")
 
 	// open database connection.
 	//
@@ -60,7 +90,7 @@ func run(c *conf.Config) error {
 		return xerrs.Wrap(err, "open database connection")
 	}
 
-	logger.Println("main:", "checking database connection")
+	logger.Info("main: checking database connection")
 	checkCtx, checkCancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer checkCancel()
 
@@ -75,47 +105,163 @@ func run(c *conf.Config) error {
 	shutdownChannel := make(chan os.Signal, 1)
 	signal.Notify(shutdownChannel, syscall.SIGTERM, syscall.SIGINT, syscall.SIGABRT)
 
+	// register the application metrics, they are served at GET /metrics to
+	// the networks of API_METRICS_ALLOWED_NETWORKS.
+	registry := metrics.NewRegistry()
+	registry.MustRegister(
+		metrics.NewRuntimeCollector(),
+		pqx.NewStatsCollector(c.DB.Postgre.Name, db),
+		buildInfo(),
+	)
+
+	// limit the requests of each client by its IP address.
+	var limiter *ratelimit.Limiter
+	if c.RestAPI.RateLimit > 0 {
+		limiter = ratelimit.New(
+			ratelimit.TokenBucket(c.RestAPI.RateLimit, c.RestAPI.RateLimitPeriod),
+			ratelimit.WithKey(ratelimit.ClientIP(c.RestAPI.TrustedProxies...)),
+		)
+	}
+
+	// shed the requests above the concurrency limits, so the latency of the
+	// admitted requests stays bounded when the database pool is saturated.
+	queue := loadshed.WithQueue(c.RestAPI.QueueSize, c.RestAPI.QueueWait)
+
+	var concurrencyLimiter *loadshed.Limiter
+	if c.RestAPI.MaxInFlight > 0 {
+		options := []loadshed.Option{queue}
+		if c.RestAPI.AdaptiveConcurrency {
+			options = append(options, loadshed.WithAdaptive(1, 2))
+		}
+		concurrencyLimiter = loadshed.New(c.RestAPI.MaxInFlight, options...)
+	}
+
+	var routeConcurrencyLimit mux.Middleware
+	if c.RestAPI.RouteMaxInFlight > 0 {
+		routeConcurrencyLimit = loadshed.PerRoute(c.RestAPI.RouteMaxInFlight, queue)
+	}
+
+	// replay the responses of the retried requests with the same Idempotency-Key,
+	// the keys are stored in Postgres, so they are shared between the instances.
+	var idempotent mux.Middleware
+	if c.RestAPI.IdempotencyTTL > 0 {
+		idempotent = idempotency.Middleware(
+			idempotency.NewPostgresStore(db),
+			idempotency.WithTTL(c.RestAPI.IdempotencyTTL),
+			idempotency.WithLockTimeout(c.RestAPI.IdempotencyLockTimeout),
+		)
+	}
+
+	// trace the requests into the export file, if any.
+	var (
+		tracer   *tracing.Tracer
+		exporter *tracing.JSONLinesExporter
+	)
+
+	if c.Tracing.ExportFile != "" {
+		exporter, err = tracing.NewFileExporter(c.Tracing.ExportFile)
+		if err != nil {
+			return xerrs.Wrap(err, "open trace export file")
+		}
+
+		tracer = tracing.NewTracer(c.Tracing.ServiceName, exporter, tracing.WithErrorHandler(func(err error) {
+			logger.Error("main: exporting span", "error", err)
+		}))
+	}
+
+	// the readiness fails while the server is not serving, so the load balancer
+	// stops routing to this instance as soon as the shutdown starts.
+	var server *mux.Server
+
+	health, err := healthUseCase(c, db, func() bool { return server != nil && server.Ready() })
+	if err != nil {
+		return xerrs.Wrap(err, "create health use case")
+	}
+
 	router := restapi.NewRouter(&restapi.Option{
 		Logger:          logger,
 		ShutdownChannel: shutdownChannel,
+		HandlerTimeout:  c.RestAPI.HandlerTimeout,
+		PanicThreshold:  c.RestAPI.PanicThreshold,
+		PanicWindow:     c.RestAPI.PanicWindow,
+		RateLimiter:     limiter,
+		CORS:            c.CORS,
+		Metrics:         registry,
+		Tracer:          tracer,
+		Health:          health,
+
+		ConcurrencyLimiter:    concurrencyLimiter,
+		RouteConcurrencyLimit: routeConcurrencyLimit,
+		Idempotency:           idempotent,
+
+		MetricsAllowedNetworks: c.RestAPI.MetricsAllowedNetworks,
 	})
 
-	server := &http.Server{
-		Handler:      router,
-		Addr:         c.RestAPI.Addr,
-		ReadTimeout:  c.RestAPI.ReadTimeout,
-		WriteTimeout: c.RestAPI.WriteTimeout,
-	}
-
-	listenErr := make(chan error, 1)
-	go func() {
-		logger.Println("main:", "server is listening on "+server.Addr)
-		listenErr <- server.ListenAndServe()
-	}()
-
-	select {
-	case sig := <-shutdownChannel:
-		logger.Println("main:", "server receives shutdown signal:", sig)
-
-		// creating a deadline for the server to complete the incoming request
-		// before the shutdown signal is received.
-		ctx, cancel := context.WithTimeout(context.Background(), c.RestAPI.ShutdownTimeout)
-		defer cancel()
-
-		// Gracefully shutdown
-		// a non-nil error caused by the server failing to shut down gracefully
-		// or the server exceeding the shutdown timeout.
-		if err := server.Shutdown(ctx); err != nil {
-			// Force shutdown
-			return server.Close()
-		}
+	server = mux.NewServer(
+		&http.Server{
+			Handler:      router,
+			Addr:         c.RestAPI.Addr,
+			ReadTimeout:  c.RestAPI.ReadTimeout,
+			WriteTimeout: c.RestAPI.WriteTimeout,
+		},
+		shutdownChannel,
+		mux.WithShutdownTimeout(c.RestAPI.ShutdownTimeout),
+		mux.WithDrainDelay(c.RestAPI.DrainDelay),
+		mux.WithServerPrinter(logger),
+	)
+
+	// the hooks are executed in order after the server has been shut down.
+	server.OnShutdown("close database connection", 5*time.Second, func(_ context.Context) error {
+		return db.Close()
+	})
 
-		logger.Println("main:", "server was shutting down gracefully")
-	case err := <-listenErr:
+	if exporter != nil {
+		server.OnShutdown("close trace export file", 5*time.Second, func(_ context.Context) error {
+			return exporter.Close()
+		})
+	}
+
+	if err := server.ListenAndServe(); err != nil {
+		return xerrs.Wrap(err, "running server")
+	}
+
+	return nil
+}
+
+// healthUseCase creates the health use case that checks the dependencies.
+func healthUseCase(c *conf.Config, db *sql.DB, ready func() bool) (*uHealth.UseCase, error) {
+	instanceID := c.Health.InstanceID
+	if instanceID == "" {
+		hostname, err := os.Hostname()
 		if err != nil {
-			return xerrs.Wrap(err, "listening failed")
+			return nil, xerrs.Wrap(err, "get host name")
 		}
+		instanceID = hostname
 	}
 
-	return nil
+	options := []uHealth.Option{
+		uHealth.WithInfo(sys.NewInfo(instanceID, c.Health.Environment)),
+		uHealth.WithSupport(sys.Support{
+			Database: pqx.Driver,
+			Storage:  c.Health.DiskPath,
+			Service:  "httpd",
+		}),
+		uHealth.WithReadiness(ready),
+		uHealth.WithCacheTTL(c.Health.CacheTTL),
+		uHealth.WithCheckTimeout(c.Health.CheckTimeout),
+		uHealth.WithCritical("postgres", uHealth.Postgres(db)),
+	}
+
+	if c.Health.DiskMinFree > 0 {
+		options = append(options, uHealth.WithOptional("disk", uHealth.DiskSpace(c.Health.DiskPath, uint64(c.Health.DiskMinFree))))
+	}
+
+	return uHealth.NewUseCase(options...), nil
+}
+
+// buildInfo creates the build info metric, the value is always 1.
+func buildInfo() metrics.Collector {
+	info := metrics.NewGauge("build_info", "The build information of this service.", "name", "ref", "date")
+	info.Set(1, sys.BuildName.Value(), sys.BuildRef.Value(), sys.BuildDate.Value())
+	return info
 }
@@ -8,10 +8,14 @@ import (
 
 	"github.com/josestg/justforfun/internal/conf"
 
+	"github.com/josestg/justforfun/pkg/logx"
+
 	"github.com/josestg/justforfun/pkg/pqx"
 
 	"github.com/josestg/justforfun/pkg/sqlize"
 
+	"github.com/josestg/justforfun/pkg/tracing"
+
 	"github.com/josestg/justforfun/pkg/xerrs"
 )
 
@@ -21,6 +25,8 @@ func main() {
 	cfg := conf.New(
 		conf.WithDBPostgreFromOSEnv(),
 		conf.WithMigrationFromEnv(),
+		conf.WithTracingFromOSEnv(),
+		conf.WithLogFromOSEnv(),
 	)
 
 	if err := run(cfg, args); err != nil {
@@ -54,7 +60,36 @@ func run(c *conf.Config, args []string) error {
 	source := sqlize.NewSourceFromDir(c.Migration.SourceDir)
 	repository := sqlize.NewPostgreRepository(db, c.Migration.TableName)
 
-	migrator := sqlize.NewMigrator(source, repository)
+	level, err := logx.ParseLevel(c.Log.Level)
+	if err != nil {
+		return xerrs.Wrap(err, "parse log level")
+	}
+
+	encoder, err := logx.NewEncoder(c.Log.Format)
+	if err != nil {
+		return xerrs.Wrap(err, "parse log format")
+	}
+
+	logger := logx.New(os.Stdout, logx.WithLevel(level), logx.WithEncoder(encoder)).With("app", "sqlize")
+
+	migrator := sqlize.NewMigrator(source, repository, sqlize.WithPrinter(logger))
+
+	// trace the command into the export file, if any.
+	// The migration steps and their queries are the children of the command span.
+	ctx := context.Background()
+	if c.Tracing.ExportFile != "" {
+		exporter, err := tracing.NewFileExporter(c.Tracing.ExportFile)
+		if err != nil {
+			return xerrs.Wrap(err, "open trace export file")
+		}
+		defer exporter.Close()
+
+		tracer := tracing.NewTracer(c.Tracing.ServiceName, exporter)
+
+		var span *tracing.Span
+		ctx, span = tracer.Start(ctx, "sqlize "+args[0])
+		defer span.End()
+	}
 
 	switch args[0] {
 	case "inspect":
@@ -68,31 +103,31 @@ func run(c *conf.Config, args []string) error {
 			return xerrs.Wrap(err, "exec create command")
 		}
 	case "status":
-		if err := migrator.Status(context.Background()); err != nil {
+		if err := migrator.Status(ctx); err != nil {
 			return xerrs.Wrap(err, "exec status command")
 		}
 
 		return nil
 	case "init":
-		if err := migrator.Init(context.Background()); err != nil {
+		if err := migrator.Init(ctx); err != nil {
 			return xerrs.Wrap(err, "exec init command")
 		}
 
 		return nil
 	case "up":
-		if err := migrator.Up(context.Background()); err != nil {
+		if err := migrator.Up(ctx); err != nil {
 			return xerrs.Wrap(err, "exec migrate command")
 		}
 
 		return nil
 	case "undo":
-		if err := migrator.Undo(context.Background()); err != nil {
+		if err := migrator.Undo(ctx); err != nil {
 			return xerrs.Wrap(err, "exec undo command")
 		}
 
 		return nil
 	case "down":
-		if err := migrator.Down(context.Background()); err != nil {
+		if err := migrator.Down(ctx); err != nil {
 			return xerrs.Wrap(err, "exec undo command")
 		}
 		return nil
@@ -3,6 +3,7 @@ package conf
 import (
 	"time"
 
+	"github.com/josestg/justforfun/pkg/cors"
 	"github.com/josestg/justforfun/pkg/env"
 	"github.com/josestg/justforfun/pkg/pqx"
 )
@@ -12,9 +13,13 @@ type Option func(c *Config)
 
 // Config holds all configs.
 type Config struct {
-	DB        *DB        `json:"db,omitempty"`
-	RestAPI   *RestAPI   `json:"rest_api,omitempty"`
-	Migration *Migration `json:"migration"`
+	DB        *DB          `json:"db,omitempty"`
+	RestAPI   *RestAPI     `json:"rest_api,omitempty"`
+	Migration *Migration   `json:"migration"`
+	CORS      *cors.Config `json:"cors,omitempty"`
+	Tracing   *Tracing     `json:"tracing,omitempty"`
+	Log       *Log         `json:"log,omitempty"`
+	Health    *Health      `json:"health,omitempty"`
 }
 
 // New creates a new config based on given options.
@@ -23,6 +28,9 @@ func New(options ...Option) *Config {
 		DB:        &DB{},
 		RestAPI:   &RestAPI{},
 		Migration: &Migration{},
+		Tracing:   &Tracing{},
+		Log:       &Log{},
+		Health:    &Health{},
 	}
 
 	for _, fn := range options {
@@ -37,7 +45,48 @@ type RestAPI struct {
 	Addr            string        `json:"addr"`
 	ReadTimeout     time.Duration `json:"read_timeout"`
 	WriteTimeout    time.Duration `json:"write_timeout"`
+	HandlerTimeout  time.Duration `json:"handler_timeout"`
 	ShutdownTimeout time.Duration `json:"shutdown_timeout"`
+
+	// DrainDelay is the time between failing the readiness and shutting down,
+	// it should cover the period of the readiness probe. Zero means no delay.
+	DrainDelay time.Duration `json:"drain_delay"`
+
+	// RateLimit is the number of requests allowed for each client per RateLimitPeriod.
+	// Zero means no limit.
+	RateLimit       int           `json:"rate_limit"`
+	RateLimitPeriod time.Duration `json:"rate_limit_period"`
+
+	// TrustedProxies are the CIDRs of the proxies whose X-Forwarded-For is trusted.
+	TrustedProxies []string `json:"trusted_proxies"`
+
+	// MaxInFlight is the maximum number of the in-flight requests of all routes,
+	// and RouteMaxInFlight is the maximum of each route. Zero means no limit.
+	// The requests above the limit wait at most QueueWait in a queue of
+	// QueueSize, the others are shed with 503 Service Unavailable.
+	MaxInFlight      int           `json:"max_in_flight"`
+	RouteMaxInFlight int           `json:"route_max_in_flight"`
+	QueueSize        int           `json:"queue_size"`
+	QueueWait        time.Duration `json:"queue_wait"`
+
+	// AdaptiveConcurrency lowers MaxInFlight while the latency rises.
+	AdaptiveConcurrency bool `json:"adaptive_concurrency"`
+
+	// PanicThreshold is the number of the recovered panics within PanicWindow
+	// that shuts the server down. Zero means never.
+	PanicThreshold int           `json:"panic_threshold"`
+	PanicWindow    time.Duration `json:"panic_window"`
+
+	// IdempotencyTTL is the time the responses of the Idempotency-Key requests
+	// are replayed. Zero means the Idempotency-Key header is ignored.
+	// IdempotencyLockTimeout is the time an in-progress request holds its key.
+	IdempotencyTTL         time.Duration `json:"idempotency_ttl"`
+	IdempotencyLockTimeout time.Duration `json:"idempotency_lock_timeout"`
+
+	// MetricsAllowedNetworks are the CIDRs or IPs of the clients GET /metrics is
+	// served to. The metrics are on the API port, which is public, so only the
+	// loopback is allowed by default, add the network of the scrapers.
+	MetricsAllowedNetworks []string `json:"metrics_allowed_networks"`
 }
 
 // WithRestAPIFromOSEnv creates a RestAPI config loader from OS Env.
@@ -47,7 +96,111 @@ func WithRestAPIFromOSEnv() Option {
 			Addr:            env.String("API_ADDR", ":8000"),
 			ReadTimeout:     env.Duration("API_REQUEST_READ_TIMEOUT", 20*time.Second),
 			WriteTimeout:    env.Duration("API_REQUEST_WRITE_TIMEOUT", 30*time.Second),
+			HandlerTimeout:  env.Duration("API_REQUEST_HANDLER_TIMEOUT", 25*time.Second),
 			ShutdownTimeout: env.Duration("API_REQUEST_SHUTDOWN_TIMEOUT", 30*time.Second),
+			DrainDelay:      env.Duration("API_DRAIN_DELAY", 0),
+			RateLimit:       env.Int("API_RATE_LIMIT", 100),
+			RateLimitPeriod: env.Duration("API_RATE_LIMIT_PERIOD", time.Minute),
+			TrustedProxies:  env.Strings("API_TRUSTED_PROXIES", nil),
+
+			MaxInFlight:         env.Int("API_MAX_IN_FLIGHT", 64),
+			RouteMaxInFlight:    env.Int("API_ROUTE_MAX_IN_FLIGHT", 0),
+			QueueSize:           env.Int("API_QUEUE_SIZE", 64),
+			QueueWait:           env.Duration("API_QUEUE_WAIT", 100*time.Millisecond),
+			AdaptiveConcurrency: env.Bool("API_ADAPTIVE_CONCURRENCY", false),
+
+			PanicThreshold: env.Int("API_PANIC_THRESHOLD", 20),
+			PanicWindow:    env.Duration("API_PANIC_WINDOW", time.Minute),
+
+			IdempotencyTTL:         env.Duration("API_IDEMPOTENCY_TTL", 24*time.Hour),
+			IdempotencyLockTimeout: env.Duration("API_IDEMPOTENCY_LOCK_TIMEOUT", time.Minute),
+
+			MetricsAllowedNetworks: env.Strings("API_METRICS_ALLOWED_NETWORKS", []string{"127.0.0.0/8", "::1"}),
+		}
+	}
+}
+
+// WithCORSFromOSEnv creates a CORS config loader from OS Env.
+// The list values are comma-separated, and no origin is allowed by default.
+func WithCORSFromOSEnv() Option {
+	return func(c *Config) {
+		c.CORS = &cors.Config{
+			AllowedOrigins:   env.Strings("CORS_ALLOWED_ORIGINS", nil),
+			AllowedMethods:   env.Strings("CORS_ALLOWED_METHODS", nil),
+			AllowedHeaders:   env.Strings("CORS_ALLOWED_HEADERS", nil),
+			ExposedHeaders:   env.Strings("CORS_EXPOSED_HEADERS", []string{"X-Request-ID", "ETag", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "Retry-After", "Idempotent-Replayed"}),
+			AllowCredentials: env.Bool("CORS_ALLOW_CREDENTIALS", false),
+			MaxAge:           env.Duration("CORS_MAX_AGE", 10*time.Minute),
+		}
+	}
+}
+
+// Tracing holds all tracing config.
+type Tracing struct {
+	ServiceName string `json:"service_name"`
+
+	// ExportFile is the path of the JSON-lines file the spans are appended to.
+	// Empty means tracing is disabled.
+	ExportFile string `json:"export_file"`
+}
+
+// WithTracingFromOSEnv creates a Tracing config loader from OS Env.
+func WithTracingFromOSEnv() Option {
+	return func(c *Config) {
+		c.Tracing = &Tracing{
+			ServiceName: env.String("TRACING_SERVICE_NAME", "justforfun"),
+			ExportFile:  env.String("TRACING_EXPORT_FILE", ""),
+		}
+	}
+}
+
+// Log holds all logging config.
+type Log struct {
+	// Level is the minimum level of the written logs: debug, info, warn or error.
+	Level string `json:"level"`
+
+	// Format is the log line format: json or text.
+	Format string `json:"format"`
+}
+
+// WithLogFromOSEnv creates a Log config loader from OS Env.
+func WithLogFromOSEnv() Option {
+	return func(c *Config) {
+		c.Log = &Log{
+			Level:  env.String("LOG_LEVEL", "info"),
+			Format: env.String("LOG_FORMAT", "json"),
+		}
+	}
+}
+
+// Health holds all health check config.
+type Health struct {
+	// InstanceID identifies this instance in the reports.
+	// Empty means the host name.
+	InstanceID  string `json:"instance_id"`
+	Environment string `json:"environment"`
+
+	// CacheTTL is how long the result of each dependency check is reused,
+	// and CheckTimeout is the time limit of each check.
+	CacheTTL     time.Duration `json:"cache_ttl"`
+	CheckTimeout time.Duration `json:"check_timeout"`
+
+	// DiskPath is the path whose file system must have DiskMinFree bytes
+	// available. Zero DiskMinFree means the disk space is not checked.
+	DiskPath    string `json:"disk_path"`
+	DiskMinFree int    `json:"disk_min_free"`
+}
+
+// WithHealthFromOSEnv creates a Health config loader from OS Env.
+func WithHealthFromOSEnv() Option {
+	return func(c *Config) {
+		c.Health = &Health{
+			InstanceID:   env.String("HEALTH_INSTANCE_ID", ""),
+			Environment:  env.String("HEALTH_ENVIRONMENT", "develop"),
+			CacheTTL:     env.Duration("HEALTH_CACHE_TTL", 5*time.Second),
+			CheckTimeout: env.Duration("HEALTH_CHECK_TIMEOUT", 2*time.Second),
+			DiskPath:     env.String("HEALTH_DISK_PATH", "/"),
+			DiskMinFree:  env.Int("HEALTH_DISK_MIN_FREE", 512<<20),
 		}
 	}
 }
@@ -0,0 +1,41 @@
+package docs
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/josestg/justforfun/internal/serialize"
+
+	"github.com/josestg/justforfun/pkg/mux"
+	"github.com/josestg/justforfun/pkg/openapi"
+)
+
+// Handler is a docs handler.
+// This handler serves the OpenAPI document generated from the registered routes.
+type Handler struct {
+	router    *mux.Router
+	generator *openapi.Generator
+
+	once sync.Once
+	doc  *openapi.Document
+}
+
+// NewHandler creates a new docs handler.
+func NewHandler(router *mux.Router, info openapi.Info) *Handler {
+	return &Handler{
+		router:    router,
+		generator: openapi.NewGenerator(info),
+	}
+}
+
+// ServeHTTP serves the Docs Handler at GET /v1/openapi.json.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) error {
+	// the routes are registered at startup,
+	// so the document is generated once at the first request.
+	h.once.Do(func() {
+		h.doc = h.generator.Generate(h.router.Routes())
+	})
+
+	// the document is served at openapi.json, so it is always JSON.
+	return serialize.RestAPI(r.Context(), w, h.doc, http.StatusOK, serialize.WithRequest(r), serialize.WithCodecs(serialize.JSON))
+}
@@ -23,24 +23,35 @@ func NewHandler(u dHealth.UseCase) *Handler {
 	}
 }
 
-func (h *Handler) showHealthStatus(w http.ResponseWriter, r *http.Request) error {
+// ShowLiveness serves the liveness report at GET /v1/healths/live.
+func (h *Handler) ShowLiveness(w http.ResponseWriter, r *http.Request) error {
 	ctx := r.Context()
 
-	report, err := h.u.HealthReport(ctx)
+	report, err := h.u.Liveness(ctx)
 	if err != nil {
-		return xerrs.Wrap(err, "getting health report")
+		return xerrs.Wrap(err, "getting liveness report")
 	}
 
-	return serialize.RestAPI(ctx, w, report, http.StatusOK)
+	w.Header().Set("Cache-Control", "no-store")
+	return serialize.RestAPI(ctx, w, report, http.StatusOK, serialize.WithRequest(r))
 }
 
-// ServeHTTP serves the Health Handler at /v1/healths.
-func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) error {
-	switch r.Method {
-	default:
-		http.NotFound(w, r)
-		return nil
-	case http.MethodGet:
-		return h.showHealthStatus(w, r)
+// ShowReadiness serves the readiness report at GET /v1/healths/ready.
+// The unhealthy instance responds 503 Service Unavailable, so the load
+// balancer stops routing to it, the degraded instance still responds 200 OK.
+func (h *Handler) ShowReadiness(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+
+	report, err := h.u.Readiness(ctx)
+	if err != nil {
+		return xerrs.Wrap(err, "getting readiness report")
 	}
+
+	status := http.StatusOK
+	if report.Status == dHealth.StatusUnhealthy {
+		status = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	return serialize.RestAPI(ctx, w, report, status, serialize.WithRequest(r))
 }
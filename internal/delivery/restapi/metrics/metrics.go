@@ -0,0 +1,92 @@
+package metrics
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/josestg/justforfun/pkg/metrics"
+	"github.com/josestg/justforfun/pkg/mux"
+)
+
+// Handler is a metrics handler.
+// This handler serves the registered metrics in the Prometheus text format.
+type Handler struct {
+	registry *metrics.Registry
+	allowed  []*net.IPNet
+}
+
+// NewHandler creates a new metrics handler.
+//
+// The metrics are only served to the clients in the allowed networks, given as
+// CIDR (for example "10.0.0.0/8") or single IP. The client is the peer of the
+// connection, the X-Forwarded-For is never honored. If no network is given,
+// the metrics are served to any client.
+//
+// NewHandler panics if an allowed network is not a valid CIDR or IP.
+func NewHandler(registry *metrics.Registry, allowedNetworks ...string) *Handler {
+	allowed := make([]*net.IPNet, 0, len(allowedNetworks))
+	for _, network := range allowedNetworks {
+		if !strings.Contains(network, "/") {
+			if ip := net.ParseIP(network); ip != nil && ip.To4() != nil {
+				network += "/32"
+			} else {
+				network += "/128"
+			}
+		}
+
+		_, ipNet, err := net.ParseCIDR(network)
+		if err != nil {
+			panic(fmt.Sprintf("metrics: invalid allowed network %q: %v", network, err))
+		}
+
+		allowed = append(allowed, ipNet)
+	}
+
+	return &Handler{
+		registry: registry,
+		allowed:  allowed,
+	}
+}
+
+// ServeHTTP serves the Metrics Handler at GET /metrics.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) error {
+	state, err := mux.GetState(r.Context())
+	if err != nil {
+		return mux.NewShutdownError(err.Error())
+	}
+
+	if !h.isAllowed(r.RemoteAddr) {
+		return mux.NewError(http.StatusForbidden, "forbidden", "the metrics are not served to this client")
+	}
+
+	state.StatusCode = http.StatusOK
+	w.Header().Set("Content-Type", metrics.TextContentType)
+	return h.registry.WriteText(w)
+}
+
+// isAllowed reports whether the remote address is in the allowed networks.
+func (h *Handler) isAllowed(remoteAddr string) bool {
+	if len(h.allowed) == 0 {
+		return true
+	}
+
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+
+	for _, network := range h.allowed {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/josestg/justforfun/pkg/metrics"
+	"github.com/josestg/justforfun/pkg/mux"
+)
+
+func TestHandler_AllowedNetworks(t *testing.T) {
+	reg := metrics.NewRegistry()
+	reg.MustRegister(metrics.NewCounter("hits_total", "Total number of hits."))
+
+	tests := map[string]struct {
+		allowed    []string
+		remoteAddr string
+		forwarded  string
+		status     int
+	}{
+		"any client":          {nil, "203.0.113.7:1234", "", http.StatusOK},
+		"loopback":            {[]string{"127.0.0.0/8", "::1"}, "127.0.0.1:1234", "", http.StatusOK},
+		"loopback ipv6":       {[]string{"127.0.0.0/8", "::1"}, "[::1]:1234", "", http.StatusOK},
+		"single ip":           {[]string{"10.0.0.5"}, "10.0.0.5:1234", "", http.StatusOK},
+		"public client":       {[]string{"127.0.0.0/8", "10.0.0.0/8"}, "203.0.113.7:1234", "", http.StatusForbidden},
+		"forwarded ignored":   {[]string{"10.0.0.0/8"}, "203.0.113.7:1234", "10.0.0.1", http.StatusForbidden},
+		"invalid remote addr": {[]string{"10.0.0.0/8"}, "unknown", "", http.StatusForbidden},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			router := mux.NewRouter(nil)
+			router.Handle("GET /metrics", NewHandler(reg, tt.allowed...))
+
+			req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.forwarded != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("expecting status %d but got %d", tt.status, rec.Code)
+			}
+
+			want := tt.status == http.StatusOK
+			if served := strings.Contains(rec.Body.String(), "hits_total"); served != want {
+				t.Fatalf("expecting the metrics are served %v but got %s", want, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestNewHandler_InvalidNetwork(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("expecting panics")
+		}
+	}()
+
+	NewHandler(metrics.NewRegistry(), "10.0.0.0/33")
+}
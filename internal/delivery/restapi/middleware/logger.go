@@ -1,15 +1,20 @@
 package middleware
 
 import (
-	"log"
 	"net/http"
 	"time"
 
+	"github.com/josestg/justforfun/pkg/logx"
+
 	"github.com/josestg/justforfun/pkg/mux"
 )
 
 // Logger logs request information for each incoming request.
-func Logger(logger *log.Logger) mux.Middleware {
+//
+// The request logger, a child of the given logger with the request ID, the method,
+// the path and the route fields, is stored in the request context, so the
+// handlers can log with the request-scoped fields by using logx.FromContext.
+func Logger(logger *logx.Logger) mux.Middleware {
 	return func(handler mux.Handler) mux.Handler {
 		fn := func(w http.ResponseWriter, r *http.Request) error {
 			ctx := r.Context()
@@ -18,12 +23,45 @@ func Logger(logger *log.Logger) mux.Middleware {
 				return mux.NewShutdownError(err.Error())
 			}
 
-			logger.Printf("logger: receiving: %s %s", r.Method, r.URL.Path)
+			reqLogger := logger.With(
+				"request_id", state.RequestID,
+				"method", r.Method,
+				"path", r.URL.Path,
+				"route", state.Route,
+			)
+
+			ctx = logx.ContextWithLogger(ctx, reqLogger)
+
+			reqLogger.Debug("request received")
 			defer func(s *mux.State) {
-				logger.Printf(
-					"logger: completed: %s %s  %d  %s μs",
-					r.Method, r.URL.Path, state.StatusCode, time.Since(state.RequestCreated),
-				)
+				level := logx.LevelInfo
+				fields := []interface{}{
+					"status", s.StatusCode,
+					"duration_us", time.Since(s.RequestCreated).Microseconds(),
+				}
+
+				if s.ContentEncoding != "" {
+					fields = append(fields,
+						"content_encoding", s.ContentEncoding,
+						"uncompressed_bytes", s.UncompressedBytes,
+						"compressed_bytes", s.CompressedBytes,
+					)
+				}
+
+				if s.TimedOut {
+					level = logx.LevelWarn
+					fields = append(fields, "timeout", s.Timeout)
+				}
+
+				if s.Err != nil {
+					level = logx.LevelWarn
+					if s.StatusCode >= http.StatusInternalServerError {
+						level = logx.LevelError
+					}
+					fields = append(fields, "error", s.Err)
+				}
+
+				reqLogger.Log(level, "request completed", fields...)
 			}(state)
 
 			return handler.ServeHTTP(w, r.WithContext(ctx))
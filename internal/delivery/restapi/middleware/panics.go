@@ -2,16 +2,88 @@ package middleware
 
 import (
 	"fmt"
-	"log"
 	"net/http"
+	"runtime/debug"
+	"sync"
 	"time"
 
+	"github.com/josestg/justforfun/pkg/logx"
+
+	"github.com/josestg/justforfun/pkg/metrics"
+
 	"github.com/josestg/justforfun/pkg/mux"
 )
 
+// PanicOption is an option type that can be used to customize the Panics middleware.
+type PanicOption func(p *panics)
+
+// WithPanicCounter counts the panics by the route label.
+func WithPanicCounter(c *metrics.Counter) PanicOption {
+	return func(p *panics) {
+		p.counter = c
+	}
+}
+
+// WithPanicThreshold makes the recovered panic a shutdown error when there are
+// at least threshold panics within the window, since the service is likely
+// in a broken state. Only the panic that crosses the threshold escalates, the
+// later panics are plain 500 Internal Server Error, so the shutdown is
+// signaled once. Zero threshold means never.
+func WithPanicThreshold(threshold int, window time.Duration) PanicOption {
+	return func(p *panics) {
+		p.threshold = threshold
+		p.window = window
+	}
+}
+
+// panics holds the panics recovery config and the recent panic times.
+type panics struct {
+	logger    *logx.Logger
+	counter   *metrics.Counter
+	threshold int
+	window    time.Duration
+
+	mu        sync.Mutex
+	recent    []time.Time
+	escalated bool
+}
+
+// record records the panic and reports the number of the panics within the
+// window, and whether this panic is the first that crosses the threshold.
+func (p *panics) record(at time.Time) (int, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	recent := p.recent[:0]
+	for _, t := range p.recent {
+		if at.Sub(t) < p.window {
+			recent = append(recent, t)
+		}
+	}
+
+	p.recent = append(recent, at)
+
+	n := len(p.recent)
+	if n < p.threshold || p.escalated {
+		return n, false
+	}
+
+	p.escalated = true
+	return n, true
+}
+
 // Panics is middleware for panics recovery.
-// This middleware transform panic into normal error.
-func Panics(logger *log.Logger) mux.Middleware {
+// This middleware transform panic into 500 Internal Server Error, which is
+// written as a response if the header has not been written yet.
+//
+// The panic is logged with its stack trace by the request logger in the context
+// if any, otherwise by the given logger.
+func Panics(logger *logx.Logger, options ...PanicOption) mux.Middleware {
+	p := panics{logger: logger}
+	for _, fn := range options {
+		fn(&p)
+	}
+
 	return func(handler mux.Handler) mux.Handler {
 		fn := func(w http.ResponseWriter, r *http.Request) (err error) {
 			ctx := r.Context()
@@ -21,14 +93,52 @@ func Panics(logger *log.Logger) mux.Middleware {
 			}
 
 			defer func(state *mux.State) {
-				if rec := recover(); rec != nil {
-					err = fmt.Errorf("panics: %v", rec)
+				rec := recover()
+				if rec == nil {
+					return
+				}
+
+				// the handler aborts the response on purpose, let the http.Server handle it.
+				if rec == http.ErrAbortHandler {
+					panic(rec)
+				}
+
+				// the panic re-panicked by mux.Timeout keeps the stack of the handler.
+				value, stack := rec, debug.Stack()
+				if pe, ok := rec.(*mux.PanicError); ok {
+					value, stack = pe.Value, pe.Stack
+				}
+
+				route := state.Route
+				if route == "" {
+					route = "unmatched"
+				}
+
+				if p.counter != nil {
+					p.counter.Inc(route)
+				}
 
-					logger.Printf(
-						"panics: recovered: %s %s  %d  %s μs",
-						r.Method, r.URL.Path, state.StatusCode, time.Since(state.RequestCreated),
-					)
+				reqLogger := logx.FromContext(ctx)
+				if reqLogger == nil {
+					reqLogger = p.logger.With("request_id", state.RequestID, "method", r.Method, "path", r.URL.Path, "route", state.Route)
 				}
+
+				reqLogger.Error("panic recovered",
+					"panic", fmt.Sprint(value),
+					"header_written", state.WroteHeader,
+					"duration_us", time.Since(state.RequestCreated).Microseconds(),
+					"stack", string(stack),
+				)
+
+				var cause error = fmt.Errorf("panics: %v", value)
+				if p.threshold > 0 {
+					if n, escalate := p.record(time.Now()); escalate {
+						reqLogger.Error("panic threshold exceeded, shutting down", "panics", n, "window", p.window)
+						cause = mux.NewShutdownError(fmt.Sprintf("panics: %d panics within %s: %v", n, p.window, value))
+					}
+				}
+
+				err = mux.WrapError(cause, http.StatusInternalServerError, "internal_error", "")
 			}(state)
 
 			return handler.ServeHTTP(w, r.WithContext(ctx))
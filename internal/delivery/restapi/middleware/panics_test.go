@@ -0,0 +1,139 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/josestg/justforfun/pkg/logx"
+
+	"github.com/josestg/justforfun/pkg/mux"
+)
+
+// syncBuffer is a bytes.Buffer that is safe for the concurrent writes.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func newPanicRouter(sc mux.ShutdownChannel, logs *syncBuffer, options ...PanicOption) *mux.Router {
+	router := mux.NewRouter(sc, Panics(logx.New(logs), options...))
+	router.Handle("GET /panic", mux.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		panic("boom")
+	}))
+	return router
+}
+
+func TestPanics_Recover(t *testing.T) {
+	logs := &syncBuffer{}
+	sc := make(mux.ShutdownChannel, 1)
+	router := newPanicRouter(sc, logs)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/panic", nil))
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("expecting status 500 but got %d", rec.Code)
+	}
+
+	if strings.Contains(rec.Body.String(), "boom") {
+		t.Fatalf("expecting the panic value is not responded but got %s", rec.Body.String())
+	}
+
+	out := logs.String()
+	if !strings.Contains(out, "panic recovered") || !strings.Contains(out, "boom") || !strings.Contains(out, "panics_test.go") {
+		t.Fatalf("expecting the panic is logged with the stack but got %s", out)
+	}
+
+	select {
+	case <-sc:
+		t.Fatalf("expecting no shutdown without the threshold")
+	default:
+	}
+}
+
+func TestPanics_Threshold(t *testing.T) {
+	logs := &syncBuffer{}
+	sc := make(mux.ShutdownChannel, 1)
+	router := newPanicRouter(sc, logs, WithPanicThreshold(2, time.Minute))
+
+	do := func() int {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/panic", nil))
+		return rec.Code
+	}
+
+	if code := do(); code != http.StatusInternalServerError {
+		t.Fatalf("expecting status 500 but got %d", code)
+	}
+
+	select {
+	case <-sc:
+		t.Fatalf("expecting no shutdown below the threshold")
+	default:
+	}
+
+	// the panics after the threshold must not block on the full shutdown channel.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 5; i++ {
+			if code := do(); code != http.StatusInternalServerError {
+				t.Errorf("expecting status 500 but got %d", code)
+			}
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expecting the panics after the threshold do not block")
+	}
+
+	select {
+	case <-sc:
+	default:
+		t.Fatalf("expecting a shutdown signal")
+	}
+
+	if n := strings.Count(logs.String(), "panic threshold exceeded"); n != 1 {
+		t.Fatalf("expecting the threshold is escalated once but got %d", n)
+	}
+}
+
+func TestPanics_UnderTimeout(t *testing.T) {
+	logs := &syncBuffer{}
+	router := mux.NewRouter(make(mux.ShutdownChannel, 1), Panics(logx.New(logs)))
+	router.Handle("GET /panic", mux.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		panic("boom")
+	}), mux.Timeout(time.Second))
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/panic", nil))
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("expecting status 500 but got %d", rec.Code)
+	}
+
+	// the stack is of the handler goroutine, not of the re-panic in mux.Timeout.
+	out := logs.String()
+	if !strings.Contains(out, `"panic":"boom"`) || !strings.Contains(out, "panics_test.go") {
+		t.Fatalf("expecting the panic is logged with the handler stack but got %s", out)
+	}
+}
@@ -1,35 +1,226 @@
 package restapi
 
 import (
-	"log"
+	"net/http"
+	"time"
 
 	"github.com/josestg/justforfun/internal/delivery/restapi/middleware"
 
+	"github.com/josestg/justforfun/internal/serialize"
+
 	uHealth "github.com/josestg/justforfun/internal/usecase/health"
 
 	hHealth "github.com/josestg/justforfun/internal/delivery/restapi/health"
 
+	hDocs "github.com/josestg/justforfun/internal/delivery/restapi/docs"
+
+	hMetrics "github.com/josestg/justforfun/internal/delivery/restapi/metrics"
+
+	dHealth "github.com/josestg/justforfun/internal/domain/health"
+
+	"github.com/josestg/justforfun/internal/domain/sys"
+
+	"github.com/josestg/justforfun/pkg/openapi"
+
+	"github.com/josestg/justforfun/pkg/ratelimit"
+
+	"github.com/josestg/justforfun/pkg/cors"
+
+	"github.com/josestg/justforfun/pkg/loadshed"
+
+	"github.com/josestg/justforfun/pkg/logx"
+
+	"github.com/josestg/justforfun/pkg/compress"
+
+	"github.com/josestg/justforfun/pkg/metrics"
+
 	"github.com/josestg/justforfun/pkg/mux"
+
+	"github.com/josestg/justforfun/pkg/tracing"
 )
 
 // Option contains all required dependencies to serve the HTTP REST API delivery.
 type Option struct {
-	Logger          *log.Logger
+	Logger          *logx.Logger
 	ShutdownChannel mux.ShutdownChannel
+
+	// HandlerTimeout limits the execution time of the v1 API handlers.
+	// Zero means no limit.
+	HandlerTimeout time.Duration
+
+	// PanicThreshold is the number of the recovered panics within PanicWindow
+	// that shuts the server down. Zero means never.
+	PanicThreshold int
+	PanicWindow    time.Duration
+
+	// RateLimiter limits the v1 API requests of each client.
+	// Nil means no limit.
+	RateLimiter *ratelimit.Limiter
+
+	// ConcurrencyLimiter limits the in-flight v1 API requests of all routes together.
+	// Nil means no limit.
+	ConcurrencyLimiter *loadshed.Limiter
+
+	// RouteConcurrencyLimit limits the in-flight v1 API requests of each route,
+	// see loadshed.PerRoute. Nil means no limit.
+	RouteConcurrencyLimit mux.Middleware
+
+	// Idempotency replays the responses of the retried v1 API requests with
+	// the same Idempotency-Key, see idempotency.Middleware.
+	// Nil means the Idempotency-Key header is ignored.
+	Idempotency mux.Middleware
+
+	// CORS is the policy for the browser clients.
+	// Nil means the cross-origin requests are not allowed.
+	CORS *cors.Config
+
+	// Metrics is the registry of the application metrics. If it is not nil,
+	// the HTTP request metrics are recorded and served at GET /metrics.
+	Metrics *metrics.Registry
+
+	// MetricsAllowedNetworks are the CIDRs of the clients GET /metrics is
+	// served to, the API port is public, so it should only list the scrapers.
+	// Empty means any client.
+	MetricsAllowedNetworks []string
+
+	// Tracer creates a span for each request, continuing the trace of the
+	// incoming traceparent header. Nil means the requests are not traced.
+	Tracer *tracing.Tracer
+
+	// Health reports the liveness and the readiness of this instance.
+	// Nil means the readiness does not check any dependency.
+	Health dHealth.UseCase
 }
 
 // NewRouter creates a configured router for HTTP REST API delivery.
 func NewRouter(opt *Option) *mux.Router {
+	panicOptions := []middleware.PanicOption{
+		middleware.WithPanicThreshold(opt.PanicThreshold, opt.PanicWindow),
+	}
+
+	var httpMetrics mux.Middleware
+	if opt.Metrics != nil {
+		httpMetrics = metrics.NewHTTPMetrics(opt.Metrics).Middleware()
+
+		panics := metrics.NewCounter("http_panics_total", "Total number of recovered panics of HTTP handlers.", "route")
+		opt.Metrics.MustRegister(panics)
+		panicOptions = append(panicOptions, middleware.WithPanicCounter(panics))
+	}
+
 	router := mux.NewRouter(
 		opt.ShutdownChannel,
 		middleware.Logger(opt.Logger),
-		middleware.Panics(opt.Logger),
+		requestTracing(opt.Tracer),
+		httpMetrics,
+		middleware.Panics(opt.Logger, panicOptions...),
+		corsPolicy(opt.CORS),
+		compress.Middleware(),
 	)
 
-	healthUseCase := uHealth.NewUseCase()
-	healthHandler := hHealth.NewHandler(healthUseCase)
+	router.SetErrorHandler(serialize.ProblemErrorHandler)
+
+	v1 := router.Group("/v1",
+		rateLimit(opt.RateLimiter),
+		concurrencyLimit(opt.ConcurrencyLimiter),
+		opt.RouteConcurrencyLimit,
+		opt.Idempotency,
+		handlerTimeout(opt.HandlerTimeout),
+	)
+	docsRoutes(v1)
+
+	// the probes must not be throttled, shed or timed out with the API traffic.
+	healthRoutes(router, opt.Health)
 
-	router.Handle("/v1/healths", healthHandler)
+	if opt.Metrics != nil {
+		metricsRoutes(router, opt.Metrics, opt.MetricsAllowedNetworks)
+	}
 
 	return router
 }
+
+// healthRoutes registers the routes of the health API.
+// They are registered on the root router, so only the global middlewares run.
+func healthRoutes(router *mux.Router, healthUseCase dHealth.UseCase) {
+	if healthUseCase == nil {
+		healthUseCase = uHealth.NewUseCase()
+	}
+
+	healthHandler := hHealth.NewHandler(healthUseCase)
+
+	router.Handle("GET /v1/healths/live", mux.HandlerFunc(healthHandler.ShowLiveness)).
+		Describe("Shows whether the process is running.").
+		Returns(http.StatusOK, dHealth.Report{})
+
+	router.Handle("GET /v1/healths/ready", mux.HandlerFunc(healthHandler.ShowReadiness)).
+		Describe("Shows whether the instance can serve the requests, with the check of each dependency.").
+		Returns(http.StatusOK, dHealth.Report{}).
+		Returns(http.StatusServiceUnavailable, dHealth.Report{})
+}
+
+// docsRoutes registers the routes of the API documentation.
+func docsRoutes(router *mux.Router) {
+	version := sys.BuildRef.Value()
+	if version == "" {
+		version = "unknown"
+	}
+
+	docsHandler := hDocs.NewHandler(router, openapi.Info{
+		Title:       "justforfun",
+		Description: "The justforfun REST API.",
+		Version:     version,
+	})
+
+	router.Handle("GET /openapi.json", docsHandler).
+		Describe("Shows the OpenAPI document of this API.").
+		Returns(http.StatusOK, map[string]interface{}{})
+}
+
+// metricsRoutes registers the routes of the metrics scraping.
+// It is not versioned, because the scrapers expect /metrics, and it is only
+// served to the allowed networks.
+func metricsRoutes(router *mux.Router, registry *metrics.Registry, allowedNetworks []string) {
+	metricsHandler := hMetrics.NewHandler(registry, allowedNetworks...)
+
+	router.Handle("GET /metrics", metricsHandler).
+		Describe("Shows the metrics in the Prometheus text format, to the allowed networks only.")
+}
+
+// handlerTimeout creates the timeout middleware, or nil if d is zero.
+func handlerTimeout(d time.Duration) mux.Middleware {
+	if d <= 0 {
+		return nil
+	}
+	return mux.Timeout(d)
+}
+
+// rateLimit creates the rate limit middleware, or nil if l is nil.
+func rateLimit(l *ratelimit.Limiter) mux.Middleware {
+	if l == nil {
+		return nil
+	}
+	return l.Middleware()
+}
+
+// concurrencyLimit creates the concurrency limit middleware, or nil if l is nil.
+func concurrencyLimit(l *loadshed.Limiter) mux.Middleware {
+	if l == nil {
+		return nil
+	}
+	return l.Middleware()
+}
+
+// corsPolicy creates the CORS middleware, or nil if c is nil.
+func corsPolicy(c *cors.Config) mux.Middleware {
+	if c == nil {
+		return nil
+	}
+	return cors.New(*c)
+}
+
+// requestTracing creates the tracing middleware, or nil if t is nil.
+func requestTracing(t *tracing.Tracer) mux.Middleware {
+	if t == nil {
+		return nil
+	}
+	return tracing.Middleware(t)
+}
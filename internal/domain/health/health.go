@@ -2,18 +2,66 @@ package health
 
 import (
 	"context"
+	"time"
 
 	"github.com/josestg/justforfun/internal/domain/sys"
 )
 
 // UseCase is contract that must be implemented by the health check use case.
 type UseCase interface {
-	// HealthReport returns the health report.
-	HealthReport(ctx context.Context) (*Report, error)
+	// Liveness returns the liveness report, it tells whether the process is
+	// running, so the dependencies are not checked.
+	Liveness(ctx context.Context) (*Report, error)
+
+	// Readiness returns the readiness report, it tells whether the instance
+	// can serve the requests, so each dependency is checked.
+	Readiness(ctx context.Context) (*Report, error)
+}
+
+// Checker knows how to check a dependency.
+type Checker interface {
+	// Check returns an error if the dependency is not usable.
+	Check(ctx context.Context) error
 }
 
+// CheckerFunc is an adapter to allow the use of ordinary functions as Checker.
+type CheckerFunc func(ctx context.Context) error
+
+// Check calls fn(ctx).
+func (fn CheckerFunc) Check(ctx context.Context) error {
+	return fn(ctx)
+}
+
+// Status is the health status.
+type Status string
+
+// Health statuses.
+const (
+	// StatusHealthy means everything works.
+	StatusHealthy Status = "healthy"
+
+	// StatusDegraded means a non-critical dependency fails,
+	// the instance still serves the requests.
+	StatusDegraded Status = "degraded"
+
+	// StatusUnhealthy means a critical dependency fails,
+	// the instance can not serve the requests.
+	StatusUnhealthy Status = "unhealthy"
+)
+
 // Report represents the health report.
 type Report struct {
-	Info    sys.Info    `json:"info"`
-	Support sys.Support `json:"support"`
+	Status  Status           `json:"status"`
+	Info    sys.Info         `json:"info"`
+	Support sys.Support      `json:"support"`
+	Checks  map[string]Check `json:"checks,omitempty"`
+}
+
+// Check represents the result of checking a dependency.
+type Check struct {
+	Status    Status    `json:"status"`
+	Critical  bool      `json:"critical"`
+	LatencyMS float64   `json:"latency_ms"`
+	CheckedAt time.Time `json:"checked_at"`
+	Error     string    `json:"error,omitempty"`
 }
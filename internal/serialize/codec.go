@@ -0,0 +1,420 @@
+package serialize
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/josestg/justforfun/pkg/cbor"
+	"github.com/josestg/justforfun/pkg/mux"
+)
+
+// ErrUnsupportedType is an error when the codec can not encode or decode the
+// type, for example CSV only supports the slices of structs.
+var ErrUnsupportedType = errors.New("serialize: unsupported type")
+
+// Codec encodes and decodes the values of a media type.
+type Codec struct {
+	// MediaType is the media type of the encoded values,
+	// it is also the Content-Type of the responses.
+	MediaType string
+
+	// Marshal encodes the value, it returns the error that wraps
+	// ErrUnsupportedType if the value can not be encoded.
+	Marshal func(v interface{}) ([]byte, error)
+
+	// Unmarshal decodes the data into v, which is a pointer.
+	Unmarshal func(data []byte, v interface{}) error
+
+	// CanDecode reports whether Unmarshal can decode into v, so the request
+	// is rejected before its body is read. Nil means any v.
+	CanDecode func(v interface{}) bool
+}
+
+// The supported codecs.
+var (
+	JSON = Codec{MediaType: "application/json", Marshal: json.Marshal, Unmarshal: json.Unmarshal}
+	XML  = Codec{MediaType: "application/xml", Marshal: marshalXML, Unmarshal: xml.Unmarshal, CanDecode: canDecodeXML}
+	CSV  = Codec{MediaType: "text/csv", Marshal: marshalCSV, Unmarshal: unmarshalCSV, CanDecode: canDecodeCSV}
+	CBOR = Codec{MediaType: "application/cbor", Marshal: marshalCBOR, Unmarshal: cbor.Unmarshal}
+)
+
+// DefaultCodecs are the codecs used by RestAPI and Decode by default, in the
+// order of preference when the client accepts them equally.
+var DefaultCodecs = []Codec{JSON, XML, CSV, CBOR}
+
+// matches reports whether the codec decodes the media type. JSON also decodes
+// the structured +json types.
+func (c Codec) matches(mediaType string) bool {
+	if c.MediaType == JSON.MediaType && strings.HasSuffix(mediaType, "+json") {
+		return true
+	}
+	return strings.EqualFold(c.MediaType, mediaType)
+}
+
+// mediaRange is a media range of the Accept header.
+type mediaRange struct {
+	typ, subtype string
+	q            float64
+}
+
+// parseAccept parses the media ranges of the Accept header, the malformed
+// ranges are ignored. The empty header accepts any media type.
+func parseAccept(accept string) []mediaRange {
+	if strings.TrimSpace(accept) == "" {
+		return []mediaRange{{typ: "*", subtype: "*", q: 1}}
+	}
+
+	var ranges []mediaRange
+	for _, s := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(s))
+		if err != nil {
+			continue
+		}
+
+		i := strings.IndexByte(mediaType, '/')
+		if i < 0 {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil || q < 0 || q > 1 {
+				continue
+			}
+		}
+
+		ranges = append(ranges, mediaRange{typ: mediaType[:i], subtype: mediaType[i+1:], q: q})
+	}
+
+	return ranges
+}
+
+// quality returns the quality of the media type, which is the quality of the
+// most specific matching range, see RFC 7231 section 5.3.2.
+func quality(ranges []mediaRange, mediaType string) float64 {
+	typ, subtype := mediaType, ""
+	if i := strings.IndexByte(mediaType, '/'); i >= 0 {
+		typ, subtype = mediaType[:i], mediaType[i+1:]
+	}
+
+	q, specificity := 0.0, -1
+	for _, r := range ranges {
+		s := -1
+		switch {
+		case strings.EqualFold(r.typ, typ) && strings.EqualFold(r.subtype, subtype):
+			s = 2
+		case strings.EqualFold(r.typ, typ) && r.subtype == "*":
+			s = 1
+		case r.typ == "*" && r.subtype == "*":
+			s = 0
+		}
+
+		if s > specificity {
+			q, specificity = r.q, s
+		}
+	}
+
+	return q
+}
+
+// negotiate encodes the data with the codec the client prefers according to
+// the Accept header. If the preferred codec does not support the data, the
+// next acceptable codec is used, and if there is none, it returns a 406 Not
+// Acceptable error.
+func negotiate(codecs []Codec, accept string, data interface{}) (Codec, []byte, error) {
+	ranges := parseAccept(accept)
+
+	acceptable := make([]Codec, 0, len(codecs))
+	for _, c := range codecs {
+		if quality(ranges, c.MediaType) > 0 {
+			acceptable = append(acceptable, c)
+		}
+	}
+
+	// the stable sort keeps the order of the codecs with the same quality.
+	sort.SliceStable(acceptable, func(i, j int) bool {
+		return quality(ranges, acceptable[i].MediaType) > quality(ranges, acceptable[j].MediaType)
+	})
+
+	for _, c := range acceptable {
+		b, err := c.Marshal(data)
+		if errors.Is(err, ErrUnsupportedType) {
+			continue
+		}
+		return c, b, err
+	}
+
+	supported := make([]string, 0, len(codecs))
+	for _, c := range codecs {
+		if _, err := c.Marshal(data); !errors.Is(err, ErrUnsupportedType) {
+			supported = append(supported, c.MediaType)
+		}
+	}
+
+	detail := "the resource is available as " + strings.Join(supported, ", ")
+	return Codec{}, nil, mux.NewError(http.StatusNotAcceptable, "not_acceptable", detail)
+}
+
+// lookup returns the codec of the Content-Type.
+func lookup(codecs []Codec, contentType string) (Codec, bool) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return Codec{}, false
+	}
+
+	for _, c := range codecs {
+		if c.matches(mediaType) {
+			return c, true
+		}
+	}
+
+	return Codec{}, false
+}
+
+// marshalXML encodes the data as XML, the slices are wrapped in a list
+// element, each item in an item element.
+func marshalXML(v interface{}) ([]byte, error) {
+	if rv := reflect.Indirect(reflect.ValueOf(v)); rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array {
+		v = struct {
+			XMLName xml.Name    `xml:"list"`
+			Items   interface{} `xml:"item"`
+		}{Items: v}
+	}
+
+	// the XML errors are about the types that have no XML form,
+	// such as the maps and the unnamed types.
+	b, err := xml.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedType, err)
+	}
+
+	return append([]byte(xml.Header), b...), nil
+}
+
+// canDecodeXML reports whether v is a pointer to a struct or a slice,
+// the maps and the interfaces have no XML form.
+func canDecodeXML(v interface{}) bool {
+	t := reflect.TypeOf(v)
+	if t == nil || t.Kind() != reflect.Ptr {
+		return false
+	}
+
+	switch t.Elem().Kind() {
+	case reflect.Map, reflect.Interface, reflect.Func, reflect.Chan:
+		return false
+	default:
+		return true
+	}
+}
+
+// marshalCBOR encodes the data as CBOR.
+func marshalCBOR(v interface{}) ([]byte, error) {
+	b, err := cbor.Marshal(v)
+	if errors.Is(err, cbor.ErrUnsupportedType) {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedType, err)
+	}
+	return b, err
+}
+
+// csvColumn is a CSV column of a struct field.
+type csvColumn struct {
+	name  string
+	index int
+}
+
+// csvColumns returns the columns of the struct type, named by the json tags.
+func csvColumns(t reflect.Type) []csvColumn {
+	columns := make([]csvColumn, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		columns = append(columns, csvColumn{name: name, index: i})
+	}
+	return columns
+}
+
+// csvElem returns the struct type of the slice elements.
+func csvElem(t reflect.Type) (reflect.Type, bool) {
+	if t.Kind() != reflect.Slice && t.Kind() != reflect.Array {
+		return nil, false
+	}
+
+	elem := t.Elem()
+	if elem.Kind() == reflect.Ptr {
+		elem = elem.Elem()
+	}
+
+	return elem, elem.Kind() == reflect.Struct
+}
+
+// marshalCSV encodes the slice of structs as CSV with a header row of the
+// field names. The strings and the text marshalers are written as they are,
+// the other values are written as JSON.
+func marshalCSV(v interface{}) ([]byte, error) {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if !rv.IsValid() {
+		return nil, fmt.Errorf("%w: %T", ErrUnsupportedType, v)
+	}
+
+	elem, ok := csvElem(rv.Type())
+	if !ok {
+		return nil, fmt.Errorf("%w: %T", ErrUnsupportedType, v)
+	}
+
+	columns := csvColumns(elem)
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+
+	record := make([]string, len(columns))
+	for i, c := range columns {
+		record[i] = c.name
+	}
+	if err := w.Write(record); err != nil {
+		return nil, err
+	}
+
+	for i := 0; i < rv.Len(); i++ {
+		item := reflect.Indirect(rv.Index(i))
+		for j, c := range columns {
+			record[j] = ""
+			if item.IsValid() {
+				s, err := csvValue(item.Field(c.index))
+				if err != nil {
+					return nil, err
+				}
+				record[j] = s
+			}
+		}
+
+		if err := w.Write(record); err != nil {
+			return nil, err
+		}
+	}
+
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// csvValue formats the field value as a CSV cell.
+func csvValue(v reflect.Value) (string, error) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return "", nil
+		}
+		v = v.Elem()
+	}
+
+	if m, ok := v.Interface().(encoding.TextMarshaler); ok {
+		b, err := m.MarshalText()
+		return string(b), err
+	}
+
+	if v.Kind() == reflect.String {
+		return v.String(), nil
+	}
+
+	b, err := json.Marshal(v.Interface())
+	return string(b), err
+}
+
+// canDecodeCSV reports whether v is a pointer to a slice of structs.
+func canDecodeCSV(v interface{}) bool {
+	t := reflect.TypeOf(v)
+	if t == nil || t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Slice {
+		return false
+	}
+
+	_, ok := csvElem(t.Elem())
+	return ok
+}
+
+// unmarshalCSV decodes the CSV with a header row into the pointer to a slice
+// of structs. The columns are matched to the fields by their names, and the
+// unknown columns are ignored.
+func unmarshalCSV(data []byte, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if !canDecodeCSV(v) || rv.IsNil() {
+		return fmt.Errorf("%w: %T", ErrUnsupportedType, v)
+	}
+
+	slice := rv.Elem()
+	elem, _ := csvElem(slice.Type())
+
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		return err
+	}
+	if len(records) == 0 {
+		return errors.New("csv: missing header row")
+	}
+
+	fields := make(map[string]int)
+	for _, c := range csvColumns(elem) {
+		fields[c.name] = c.index
+	}
+
+	result := reflect.MakeSlice(slice.Type(), 0, len(records)-1)
+	for _, record := range records[1:] {
+		item := reflect.New(elem).Elem()
+		for i, cell := range record {
+			index, exist := fields[records[0][i]]
+			if !exist || cell == "" {
+				continue
+			}
+
+			if err := setCSVValue(item.Field(index), cell); err != nil {
+				return fmt.Errorf("csv: column %q: %w", records[0][i], err)
+			}
+		}
+
+		if slice.Type().Elem().Kind() == reflect.Ptr {
+			item = item.Addr()
+		}
+		result = reflect.Append(result, item)
+	}
+
+	slice.Set(result)
+	return nil
+}
+
+// setCSVValue parses the CSV cell into the field value, the reverse of csvValue.
+func setCSVValue(v reflect.Value, cell string) error {
+	if v.Kind() == reflect.Ptr {
+		v.Set(reflect.New(v.Type().Elem()))
+		v = v.Elem()
+	}
+
+	if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(cell))
+	}
+
+	if v.Kind() == reflect.String {
+		v.SetString(cell)
+		return nil
+	}
+
+	return json.Unmarshal([]byte(cell), v.Addr().Interface())
+}
@@ -0,0 +1,219 @@
+package serialize
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/josestg/justforfun/pkg/mux"
+)
+
+type item struct {
+	ID      int        `json:"id" xml:"id"`
+	Name    string     `json:"name" xml:"name"`
+	Tags    []string   `json:"tags" xml:"tags"`
+	Created *time.Time `json:"created" xml:"created"`
+	secret  string
+	Skipped string `json:"-" xml:"-"`
+}
+
+func TestNegotiate(t *testing.T) {
+	items := []item{{ID: 1, Name: "a"}}
+	single := item{ID: 1, Name: "a"}
+	dict := map[string]int{"a": 1}
+
+	tests := map[string]struct {
+		accept string
+		data   interface{}
+		want   string
+		status int
+	}{
+		"empty accepts any":          {"", single, "application/json", 0},
+		"any":                        {"*/*", single, "application/json", 0},
+		"exact":                      {"application/xml", single, "application/xml", 0},
+		"type wildcard":              {"text/*", items, "text/csv", 0},
+		"highest q wins":             {"application/json;q=0.5, application/cbor;q=0.9", single, "application/cbor", 0},
+		"order breaks ties":          {"application/cbor, application/json", single, "application/json", 0},
+		"most specific range":        {"application/*;q=0.1, application/xml;q=0.8, */*;q=0.5", single, "application/xml", 0},
+		"q zero excludes":            {"application/json;q=0, */*", single, "application/xml", 0},
+		"falls through unsupported":  {"text/csv, application/json;q=0.1", single, "application/json", 0},
+		"unsupported map as xml":     {"application/xml, application/cbor;q=0.5", dict, "application/cbor", 0},
+		"malformed ranges ignored":   {"nonsense, application/xml;q=abc, application/cbor", single, "application/cbor", 0},
+		"nothing acceptable":         {"image/png", single, "", http.StatusNotAcceptable},
+		"acceptable but unsupported": {"text/csv", single, "", http.StatusNotAcceptable},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			codec, _, err := negotiate(DefaultCodecs, tt.accept, tt.data)
+			if tt.status != 0 {
+				var httpErr *mux.Error
+				if !errors.As(err, &httpErr) || httpErr.Status != tt.status {
+					t.Fatalf("expecting status %d but got %v", tt.status, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expecting nil error but got %v", err)
+			}
+
+			if codec.MediaType != tt.want {
+				t.Fatalf("expecting %s but got %s", tt.want, codec.MediaType)
+			}
+		})
+	}
+}
+
+func TestNegotiate_NotAcceptableDetail(t *testing.T) {
+	_, _, err := negotiate(DefaultCodecs, "text/csv", item{})
+
+	var httpErr *mux.Error
+	if !errors.As(err, &httpErr) {
+		t.Fatalf("expecting *mux.Error but got %v", err)
+	}
+
+	// the single object has no CSV form, so CSV is not listed.
+	want := "the resource is available as application/json, application/xml, application/cbor"
+	if httpErr.Detail != want {
+		t.Fatalf("expecting detail %q but got %q", want, httpErr.Detail)
+	}
+}
+
+func TestCSV(t *testing.T) {
+	created := time.Date(2021, 12, 15, 10, 0, 0, 0, time.UTC)
+	items := []*item{
+		{ID: 1, Name: "plain", Tags: []string{"a", "b"}, Created: &created, secret: "x", Skipped: "y"},
+		{ID: 2, Name: "with, comma and \"quote\""},
+		nil,
+	}
+
+	b, err := CSV.Marshal(items)
+	if err != nil {
+		t.Fatalf("expecting nil error but got %v", err)
+	}
+
+	want := "id,name,tags,created\n" +
+		`1,plain,"[""a"",""b""]",2021-12-15T10:00:00Z` + "\n" +
+		`2,"with, comma and ""quote""",null,` + "\n" +
+		",,,\n"
+
+	if string(b) != want {
+		t.Fatalf("expecting\n%s\nbut got\n%s", want, b)
+	}
+
+	var decoded []*item
+	if err := CSV.Unmarshal(b, &decoded); err != nil {
+		t.Fatalf("expecting nil error but got %v", err)
+	}
+
+	if len(decoded) != 3 || decoded[0].ID != 1 || !decoded[0].Created.Equal(created) ||
+		!reflect.DeepEqual(decoded[0].Tags, []string{"a", "b"}) || decoded[1].Name != items[1].Name {
+		t.Fatalf("expecting the decoded items but got %+v", decoded)
+	}
+
+	for _, v := range []interface{}{item{}, map[string]int{}, []int{1}, nil} {
+		if _, err := CSV.Marshal(v); !errors.Is(err, ErrUnsupportedType) {
+			t.Fatalf("expecting ErrUnsupportedType for %T but got %v", v, err)
+		}
+	}
+
+	var single item
+	if err := CSV.Unmarshal(b, &single); !errors.Is(err, ErrUnsupportedType) {
+		t.Fatalf("expecting ErrUnsupportedType but got %v", err)
+	}
+}
+
+func TestXML(t *testing.T) {
+	b, err := XML.Marshal([]item{{ID: 1, Name: "a"}, {ID: 2, Name: "b"}})
+	if err != nil {
+		t.Fatalf("expecting nil error but got %v", err)
+	}
+
+	want := `<?xml version="1.0" encoding="UTF-8"?>` + "\n" +
+		`<list><item><id>1</id><name>a</name></item><item><id>2</id><name>b</name></item></list>`
+	if string(b) != want {
+		t.Fatalf("expecting\n%s\nbut got\n%s", want, b)
+	}
+
+	if _, err := XML.Marshal(map[string]int{"a": 1}); !errors.Is(err, ErrUnsupportedType) {
+		t.Fatalf("expecting ErrUnsupportedType but got %v", err)
+	}
+}
+
+func TestRestAPI_Negotiated(t *testing.T) {
+	data := []item{{ID: 1, Name: "a"}}
+	etags := make(map[string]string)
+
+	for _, accept := range []string{"application/json", "application/xml", "text/csv", "application/cbor"} {
+		t.Run(accept, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/items", nil)
+			r.Header.Set("Accept", accept)
+			ctx, _ := withState(r.Context())
+
+			rec := httptest.NewRecorder()
+			if err := RestAPI(ctx, rec, data, http.StatusOK, WithRequest(r)); err != nil {
+				t.Fatalf("expecting nil error but got %v", err)
+			}
+
+			if got := rec.Header().Get("Content-Type"); got != accept {
+				t.Fatalf("expecting Content-Type %s but got %s", accept, got)
+			}
+
+			if got := rec.Header().Get("Vary"); got != "Accept" {
+				t.Fatalf("expecting Vary Accept but got %q", got)
+			}
+
+			// the ETag of the representation passes the precondition of the
+			// write that accepts the same media type.
+			w := httptest.NewRequest(http.MethodPut, "/items", nil)
+			w.Header.Set("Accept", accept)
+			w.Header.Set("If-Match", rec.Header().Get("ETag"))
+
+			etag, err := ETag(w, data)
+			if err != nil {
+				t.Fatalf("expecting nil error but got %v", err)
+			}
+
+			if got := rec.Header().Get("ETag"); got != etag {
+				t.Fatalf("expecting ETag %s but got %s", etag, got)
+			}
+
+			if err := Precondition(w, etag, time.Time{}); err != nil {
+				t.Fatalf("expecting the precondition passes but got %v", err)
+			}
+
+			etags[accept] = etag
+		})
+	}
+
+	// the representations differ by the Accept, so they must not share the strong ETag.
+	if jsonTag, xmlTag := etags["application/json"], etags["application/xml"]; jsonTag == "" || jsonTag == xmlTag {
+		t.Fatalf("expecting the JSON and XML ETags differ but got %s and %s", jsonTag, xmlTag)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	tests := map[string]string{
+		"application/json":                  "application/json",
+		"application/json; charset=utf-8":   "application/json",
+		"application/merge-patch+json":      "application/json",
+		"APPLICATION/XML":                   "application/xml",
+		"text/csv; header=present":          "text/csv",
+		"application/cbor":                  "application/cbor",
+		"text/plain":                        "",
+		"":                                  "",
+		"application/json; charset=\"utf-8": "",
+	}
+
+	for contentType, want := range tests {
+		codec, ok := lookup(DefaultCodecs, contentType)
+		if got := codec.MediaType; got != want || ok != (want != "") {
+			t.Errorf("%q: expecting %q but got %q", contentType, want, strings.TrimSpace(got))
+		}
+	}
+}
@@ -0,0 +1,268 @@
+package serialize
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/josestg/justforfun/pkg/mux"
+
+	"github.com/josestg/justforfun/pkg/validate"
+)
+
+// DefaultMaxBodySize is the default maximum size of the decoded request body.
+const DefaultMaxBodySize = 1 << 20
+
+// errBodyTooLarge is an error when the request body exceeds the maximum size.
+var errBodyTooLarge = errors.New("request body too large")
+
+// Schemer is implemented by the request bodies that validate themselves.
+// The schema is built after decoding, so it validates the decoded values.
+type Schemer interface {
+	// Schema returns the validation schema of the body.
+	Schema() validate.Schema
+}
+
+// DecodeOption is an option type that can be used to customize the Decode.
+type DecodeOption func(o *decodeOptions)
+
+// decodeOptions holds the Decode options.
+type decodeOptions struct {
+	maxBodySize int64
+	transformer validate.ErrorTransformer
+	codecs      []Codec
+}
+
+// WithMaxBodySize sets the maximum size of the request body in bytes.
+// The default is DefaultMaxBodySize.
+func WithMaxBodySize(n int64) DecodeOption {
+	return func(o *decodeOptions) {
+		o.maxBodySize = n
+	}
+}
+
+// WithTransformer sets the transformer of the validation error messages.
+// By default, the message is the error text.
+func WithTransformer(t validate.ErrorTransformer) DecodeOption {
+	return func(o *decodeOptions) {
+		o.transformer = t
+	}
+}
+
+// WithDecoders sets the codecs the request body can be decoded by.
+// The default is DefaultCodecs.
+func WithDecoders(codecs ...Codec) DecodeOption {
+	return func(o *decodeOptions) {
+		o.codecs = codecs
+	}
+}
+
+// Decode decodes the request body into dst, which must be a pointer.
+//
+// The body is decoded by the codec of the request Content-Type, the request
+// with an unsupported Content-Type fails with 415 Unsupported Media Type, and
+// the body larger than the maximum size fails with 413 Payload Too Large.
+// The JSON body is decoded strictly: the empty body, the malformed JSON, the
+// unknown fields, the mismatched types and the trailing data after the JSON
+// value fail with 400 Bad Request, the field-level problems are reported in
+// the error fields. The bodies of the other codecs fail with 400 Bad Request
+// if they are empty or can not be decoded.
+// Finally, if dst implements Schemer, its schema is validated, and the invalid
+// body fails with 422 Unprocessable Entity. The returned errors are the
+// *mux.Error or validate.Errors, so the router writes them as responses.
+func Decode(r *http.Request, dst interface{}, opts ...DecodeOption) error {
+	o := decodeOptions{
+		maxBodySize: DefaultMaxBodySize,
+		transformer: validate.TransformFunc(func(_ context.Context, err error) string { return err.Error() }),
+		codecs:      DefaultCodecs,
+	}
+
+	for _, fn := range opts {
+		fn(&o)
+	}
+
+	// only the codecs that can decode into dst are supported,
+	// for example a single object can not be sent as CSV.
+	codecs := make([]Codec, 0, len(o.codecs))
+	for _, c := range o.codecs {
+		if c.CanDecode == nil || c.CanDecode(dst) {
+			codecs = append(codecs, c)
+		}
+	}
+
+	codec, ok := lookup(codecs, r.Header.Get("Content-Type"))
+	if !ok {
+		return unsupportedMediaType(codecs, nil)
+	}
+
+	body := &limitedReader{r: r.Body, n: o.maxBodySize}
+	if codec.MediaType == JSON.MediaType {
+		if err := decodeJSON(body, dst); err != nil {
+			return decodeError(err, o.maxBodySize)
+		}
+	} else {
+		data, err := io.ReadAll(body)
+		if err == nil && len(data) == 0 {
+			err = io.EOF
+		}
+		if err != nil {
+			return decodeError(err, o.maxBodySize)
+		}
+
+		if err := codec.Unmarshal(data, dst); err != nil {
+			if errors.Is(err, ErrUnsupportedType) {
+				return unsupportedMediaType(codecs, err)
+			}
+			return mux.WrapError(err, http.StatusBadRequest, "invalid_body", "request body can not be decoded as "+codec.MediaType)
+		}
+	}
+
+	if s, ok := dst.(Schemer); ok {
+		if err := s.Schema().Valid(r.Context(), o.transformer); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// unsupportedMediaType creates the 415 Unsupported Media Type error
+// that lists the supported codecs.
+func unsupportedMediaType(codecs []Codec, cause error) error {
+	supported := make([]string, len(codecs))
+	for i, c := range codecs {
+		supported[i] = c.MediaType
+	}
+
+	detail := "Content-Type must be one of " + strings.Join(supported, ", ")
+	return mux.WrapError(cause, http.StatusUnsupportedMediaType, "unsupported_media_type", detail)
+}
+
+// errTrailingData is an error when the JSON value is followed by other data.
+var errTrailingData = errors.New("request body must contain a single JSON value")
+
+// decodeJSON decodes the single JSON value of the body into dst,
+// the unknown fields are not allowed.
+func decodeJSON(body io.Reader, dst interface{}) error {
+	dec := json.NewDecoder(body)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(dst); err != nil {
+		return err
+	}
+
+	// the body must contain a single JSON value, only the whitespaces may follow.
+	if err := dec.Decode(&struct{}{}); err != io.EOF {
+		if errors.Is(err, errBodyTooLarge) {
+			return err
+		}
+		return errTrailingData
+	}
+
+	return nil
+}
+
+// decodeError converts the decoding error into the HTTP error.
+func decodeError(err error, maxBodySize int64) error {
+	var (
+		syntaxErr *json.SyntaxError
+		typeErr   *json.UnmarshalTypeError
+	)
+
+	switch {
+	case errors.Is(err, errBodyTooLarge):
+		detail := fmt.Sprintf("request body must not be larger than %d bytes", maxBodySize)
+		return mux.WrapError(err, http.StatusRequestEntityTooLarge, "body_too_large", detail)
+	case errors.Is(err, errTrailingData):
+		return mux.WrapError(err, http.StatusBadRequest, "invalid_body", err.Error())
+	case errors.Is(err, io.EOF):
+		return mux.WrapError(err, http.StatusBadRequest, "invalid_body", "request body must not be empty")
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return mux.WrapError(err, http.StatusBadRequest, "invalid_body", "request body contains malformed JSON")
+	case errors.As(err, &syntaxErr):
+		detail := fmt.Sprintf("request body contains malformed JSON at position %d", syntaxErr.Offset)
+		return mux.WrapError(err, http.StatusBadRequest, "invalid_body", detail)
+	case errors.As(err, &typeErr):
+		field := typeErr.Field
+		if field == "" {
+			return mux.WrapError(err, http.StatusBadRequest, "invalid_body", "request body must be "+jsonType(typeErr.Type))
+		}
+
+		e := mux.WrapError(err, http.StatusBadRequest, "invalid_body", "one or more fields have invalid types")
+		e.Fields = map[string][]string{field: {"must be " + jsonType(typeErr.Type)}}
+		return e
+	case isUnknownField(err):
+		field := strings.Trim(strings.TrimPrefix(err.Error(), unknownFieldPrefix), `"`)
+
+		e := mux.WrapError(err, http.StatusBadRequest, "invalid_body", "one or more fields are unknown")
+		e.Fields = map[string][]string{field: {"is unknown"}}
+		return e
+	default:
+		return mux.WrapError(err, http.StatusBadRequest, "invalid_body", "request body can not be decoded")
+	}
+}
+
+// unknownFieldPrefix is the prefix of the error that encoding/json returns for
+// the unknown field when DisallowUnknownFields is set. The error has no type,
+// so it is matched by its text, TestDecode pins the text.
+const unknownFieldPrefix = "json: unknown field "
+
+// isUnknownField reports whether the JSON decoding error is an unknown field.
+func isUnknownField(err error) bool {
+	return strings.HasPrefix(err.Error(), unknownFieldPrefix)
+}
+
+// jsonType describes the JSON type of the Go type.
+func jsonType(t reflect.Type) string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	case reflect.Map, reflect.Struct:
+		return "an object"
+	default:
+		return "a valid value"
+	}
+}
+
+// limitedReader reads at most n bytes, and fails with errBodyTooLarge after that.
+type limitedReader struct {
+	r io.Reader
+	n int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.n < 0 {
+		return 0, errBodyTooLarge
+	}
+
+	// reads one more byte to know whether the body exceeds the limit.
+	if int64(len(p)) > l.n+1 {
+		p = p[:l.n+1]
+	}
+
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	if l.n < 0 {
+		return 0, errBodyTooLarge
+	}
+
+	return n, err
+}
@@ -0,0 +1,135 @@
+package serialize
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/josestg/justforfun/pkg/mux"
+
+	"github.com/josestg/justforfun/pkg/validate"
+)
+
+type order struct {
+	Name string `json:"name" xml:"name"`
+	Qty  int    `json:"qty" xml:"qty"`
+}
+
+func (o *order) Schema() validate.Schema {
+	return validate.Schema{
+		"name": validate.Field(o.Name, validate.RuleFunc(func(_ context.Context, v interface{}) error {
+			if v.(string) == "" {
+				return errors.New("is required")
+			}
+			return nil
+		})),
+	}
+}
+
+// unreadable fails the test if the body is read.
+type unreadable struct{ t *testing.T }
+
+func (u unreadable) Read([]byte) (int, error) {
+	u.t.Fatalf("expecting the body is not read")
+	return 0, io.EOF
+}
+
+func TestDecode(t *testing.T) {
+	tests := map[string]struct {
+		contentType string
+		body        string
+		dst         func() interface{}
+		status      int
+		fields      map[string][]string
+	}{
+		"missing content type":   {"", `{"name":"a"}`, newOrder, http.StatusUnsupportedMediaType, nil},
+		"unsupported media type": {"text/plain", `name=a`, newOrder, http.StatusUnsupportedMediaType, nil},
+		"too large":              {"application/json", `{"name":"` + strings.Repeat("a", 64) + `"}`, newOrder, http.StatusRequestEntityTooLarge, nil},
+		"too large non-json":     {"application/xml", `<order><name>` + strings.Repeat("a", 64) + `</name></order>`, newOrder, http.StatusRequestEntityTooLarge, nil},
+		"empty":                  {"application/json", ``, newOrder, http.StatusBadRequest, nil},
+		"empty non-json":         {"application/cbor", ``, newOrder, http.StatusBadRequest, nil},
+		"truncated":              {"application/json", `{"name":`, newOrder, http.StatusBadRequest, nil},
+		"malformed":              {"application/json", `{"name" "a"}`, newOrder, http.StatusBadRequest, nil},
+		"malformed xml":          {"application/xml", `<order><name>`, newOrder, http.StatusBadRequest, nil},
+		"top-level type":         {"application/json", `[1]`, newOrder, http.StatusBadRequest, nil},
+		"field type":             {"application/json", `{"name":"a","qty":"2"}`, newOrder, http.StatusBadRequest, map[string][]string{"qty": {"must be an integer"}}},
+		"unknown field":          {"application/json", `{"name":"a","color":"red"}`, newOrder, http.StatusBadRequest, map[string][]string{"color": {"is unknown"}}},
+		"trailing data":          {"application/json", `{"name":"a"} {}`, newOrder, http.StatusBadRequest, nil},
+		"invalid schema":         {"application/json", `{"qty":1}`, newOrder, http.StatusUnprocessableEntity, nil},
+		"json":                   {"application/json; charset=utf-8", `{"name":"a","qty":2}`, newOrder, 0, nil},
+		"structured json":        {"application/merge-patch+json", `{"name":"a"}`, newOrder, 0, nil},
+		"xml":                    {"application/xml", `<order><name>a</name></order>`, newOrder, 0, nil},
+		"cbor":                   {"application/cbor", "\xa2\x64name\x61a\x63qty\x02", newOrder, 0, nil},
+		"csv":                    {"text/csv", "name,qty\na,2\n", func() interface{} { return &[]order{} }, 0, nil},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				r.Header.Set("Content-Type", tt.contentType)
+			}
+
+			err := Decode(r, tt.dst(), WithMaxBodySize(32))
+			if tt.status == 0 {
+				if err != nil {
+					t.Fatalf("expecting nil error but got %v", err)
+				}
+				return
+			}
+
+			if tt.status == http.StatusUnprocessableEntity {
+				var validationErr validate.Errors
+				if !errors.As(err, &validationErr) || len(validationErr["name"]) == 0 {
+					t.Fatalf("expecting the validation error of name but got %v", err)
+				}
+				return
+			}
+
+			var httpErr *mux.Error
+			if !errors.As(err, &httpErr) || httpErr.Status != tt.status {
+				t.Fatalf("expecting status %d but got %v", tt.status, err)
+			}
+
+			if tt.fields != nil && !reflect.DeepEqual(httpErr.Fields, tt.fields) {
+				t.Fatalf("expecting fields %v but got %v", tt.fields, httpErr.Fields)
+			}
+		})
+	}
+}
+
+func TestDecode_UnsupportedTarget(t *testing.T) {
+	tests := map[string]struct {
+		contentType string
+		dst         interface{}
+		supported   string
+	}{
+		"csv into a struct": {"text/csv", &order{}, "application/json, application/xml, application/cbor"},
+		"xml into a map":    {"application/xml", &map[string]interface{}{}, "application/json, application/cbor"},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/orders", unreadable{t})
+			r.Header.Set("Content-Type", tt.contentType)
+
+			err := Decode(r, tt.dst)
+
+			var httpErr *mux.Error
+			if !errors.As(err, &httpErr) || httpErr.Status != http.StatusUnsupportedMediaType {
+				t.Fatalf("expecting status 415 but got %v", err)
+			}
+
+			if want := "Content-Type must be one of " + tt.supported; httpErr.Detail != want {
+				t.Fatalf("expecting detail %q but got %q", want, httpErr.Detail)
+			}
+		})
+	}
+}
+
+func newOrder() interface{} { return &order{} }
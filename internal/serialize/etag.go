@@ -0,0 +1,172 @@
+package serialize
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/josestg/justforfun/pkg/mux"
+)
+
+// Option is an option type that can be used to customize the RestAPI response.
+type Option func(o *options)
+
+// options holds the RestAPI response options.
+type options struct {
+	request      *http.Request
+	etag         string
+	lastModified time.Time
+	codecs       []Codec
+}
+
+// WithRequest enables the conditional GET and the content negotiation for the
+// given request. The response has an ETag computed by ETag unless WithETag is
+// used, and the 304 Not Modified is written if the If-None-Match or
+// If-Modified-Since of the request matches the representation.
+func WithRequest(r *http.Request) Option {
+	return func(o *options) {
+		o.request = r
+	}
+}
+
+// WithETag sets the ETag supplied by the handler, for example the row version.
+// The etag is quoted if it is not quoted yet.
+func WithETag(etag string) Option {
+	return func(o *options) {
+		o.etag = quoteETag(etag)
+	}
+}
+
+// WithCodecs sets the codecs the response can be encoded by, in the order of
+// preference. The default is DefaultCodecs.
+func WithCodecs(codecs ...Codec) Option {
+	return func(o *options) {
+		o.codecs = codecs
+	}
+}
+
+// WithLastModified sets the Last-Modified of the representation.
+func WithLastModified(t time.Time) Option {
+	return func(o *options) {
+		o.lastModified = t
+	}
+}
+
+// ETag computes the strong ETag of the representation of the data that
+// RestAPI negotiates for the request, see WithCodecs. It can be used to check
+// the preconditions of the writes against the representation the client has.
+//
+// The ETag is computed from the encoded bytes, so each representation has its
+// own ETag, and the write request must accept the media type of the
+// representation its If-Match comes from. Without the request, the ETag is of
+// the JSON representation.
+func ETag(r *http.Request, data interface{}, opts ...Option) (string, error) {
+	o := options{codecs: DefaultCodecs}
+	for _, fn := range opts {
+		fn(&o)
+	}
+	o.request = r
+
+	_, body, err := encode(o, data)
+	if err != nil {
+		return "", err
+	}
+
+	return computeETag(body), nil
+}
+
+// Precondition checks the If-Match and If-Unmodified-Since of the write request
+// against the current representation. It returns a 412 Precondition Failed error
+// if the client has an outdated representation. The empty etag means the
+// resource does not exist, and the zero lastModified is ignored.
+func Precondition(r *http.Request, etag string, lastModified time.Time) error {
+	etag = quoteETag(etag)
+
+	if ifMatch := r.Header.Get("If-Match"); ifMatch != "" {
+		if !matchETag(ifMatch, etag, false) {
+			return preconditionFailed()
+		}
+		return nil
+	}
+
+	if since, err := http.ParseTime(r.Header.Get("If-Unmodified-Since")); err == nil && !lastModified.IsZero() {
+		if lastModified.Truncate(time.Second).After(since) {
+			return preconditionFailed()
+		}
+	}
+
+	return nil
+}
+
+// preconditionFailed creates the 412 Precondition Failed error.
+func preconditionFailed() error {
+	return mux.NewError(
+		http.StatusPreconditionFailed,
+		"precondition_failed",
+		"the resource has been modified, please fetch the latest representation",
+	)
+}
+
+// notModified reports whether the conditional GET request matches the
+// representation, the If-None-Match takes precedence over the If-Modified-Since.
+func notModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		return matchETag(ifNoneMatch, etag, true)
+	}
+
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil || lastModified.IsZero() {
+		return false
+	}
+
+	return !lastModified.Truncate(time.Second).After(since)
+}
+
+// matchETag reports whether the list of the If-Match or If-None-Match header
+// matches the etag. The weak comparison ignores the W/ prefix.
+func matchETag(list, etag string, weak bool) bool {
+	if etag == "" {
+		return false
+	}
+
+	if strings.TrimSpace(list) == "*" {
+		return true
+	}
+
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if weak {
+			if strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+			continue
+		}
+
+		// the strong comparison never matches the weak ETag.
+		if !strings.HasPrefix(candidate, "W/") && !strings.HasPrefix(etag, "W/") && candidate == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// computeETag computes the strong ETag from the body.
+func computeETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// quoteETag quotes the etag if it is not quoted yet.
+func quoteETag(etag string) string {
+	if etag == "" || strings.HasSuffix(etag, `"`) {
+		return etag
+	}
+	return `"` + etag + `"`
+}
@@ -0,0 +1,230 @@
+package serialize
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/josestg/justforfun/pkg/mux"
+)
+
+func TestMatchETag(t *testing.T) {
+	tests := map[string]struct {
+		list string
+		etag string
+		weak bool
+		want bool
+	}{
+		"strong equal":               {`"a"`, `"a"`, false, true},
+		"strong not equal":           {`"a"`, `"b"`, false, false},
+		"strong weak candidate":      {`W/"a"`, `"a"`, false, false},
+		"strong weak etag":           {`"a"`, `W/"a"`, false, false},
+		"weak equal":                 {`"a"`, `"a"`, true, true},
+		"weak weak candidate":        {`W/"a"`, `"a"`, true, true},
+		"weak weak etag":             {`"a"`, `W/"a"`, true, true},
+		"weak not equal":             {`W/"a"`, `"b"`, true, false},
+		"list":                       {`"x", "y" ,"a"`, `"a"`, false, true},
+		"list not matched":           {`"x", "y"`, `"a"`, true, false},
+		"any":                        {`*`, `"a"`, false, true},
+		"any with spaces":            {` * `, `"a"`, true, true},
+		"any without representation": {`*`, ``, false, false},
+		"empty etag":                 {`""`, ``, true, false},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := matchETag(tt.list, tt.etag, tt.weak); got != tt.want {
+				t.Fatalf("expecting %v but got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestNotModified(t *testing.T) {
+	modified := time.Date(2021, 12, 15, 10, 0, 0, 500, time.UTC)
+	before := modified.Add(-time.Hour).Format(http.TimeFormat)
+	after := modified.Add(time.Hour).Format(http.TimeFormat)
+
+	tests := map[string]struct {
+		method string
+		header map[string]string
+		want   bool
+	}{
+		"no validators":             {http.MethodGet, nil, false},
+		"if-none-match":             {http.MethodGet, map[string]string{"If-None-Match": `"a"`}, true},
+		"if-none-match weak":        {http.MethodGet, map[string]string{"If-None-Match": `W/"a"`}, true},
+		"if-none-match any":         {http.MethodGet, map[string]string{"If-None-Match": `*`}, true},
+		"if-none-match list":        {http.MethodHead, map[string]string{"If-None-Match": `"x", "a"`}, true},
+		"if-none-match not matched": {http.MethodGet, map[string]string{"If-None-Match": `"b"`}, false},
+		"if-modified-since":         {http.MethodGet, map[string]string{"If-Modified-Since": after}, true},
+		"if-modified-since exact":   {http.MethodGet, map[string]string{"If-Modified-Since": modified.Format(http.TimeFormat)}, true},
+		"modified since":            {http.MethodGet, map[string]string{"If-Modified-Since": before}, false},
+		"malformed date":            {http.MethodGet, map[string]string{"If-Modified-Since": "yesterday"}, false},
+		"if-none-match precedence":  {http.MethodGet, map[string]string{"If-None-Match": `"b"`, "If-Modified-Since": after}, false},
+		"if-none-match over stale":  {http.MethodGet, map[string]string{"If-None-Match": `"a"`, "If-Modified-Since": before}, true},
+		"not a read":                {http.MethodPost, map[string]string{"If-None-Match": `"a"`}, false},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/items/1", nil)
+			for k, v := range tt.header {
+				r.Header.Set(k, v)
+			}
+
+			if got := notModified(r, `"a"`, modified); got != tt.want {
+				t.Fatalf("expecting %v but got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestPrecondition(t *testing.T) {
+	modified := time.Date(2021, 12, 15, 10, 0, 0, 0, time.UTC)
+	before := modified.Add(-time.Hour).Format(http.TimeFormat)
+	after := modified.Add(time.Hour).Format(http.TimeFormat)
+
+	tests := map[string]struct {
+		header map[string]string
+		etag   string
+		failed bool
+	}{
+		"no validators":              {nil, "a", false},
+		"if-match":                   {map[string]string{"If-Match": `"a"`}, "a", false},
+		"if-match quoted etag":       {map[string]string{"If-Match": `"a"`}, `"a"`, false},
+		"if-match list":              {map[string]string{"If-Match": `"x", "a"`}, "a", false},
+		"if-match outdated":          {map[string]string{"If-Match": `"b"`}, "a", true},
+		"if-match weak":              {map[string]string{"If-Match": `W/"a"`}, "a", true},
+		"if-match any":               {map[string]string{"If-Match": `*`}, "a", false},
+		"if-match any missing":       {map[string]string{"If-Match": `*`}, "", true},
+		"if-unmodified-since":        {map[string]string{"If-Unmodified-Since": after}, "a", false},
+		"if-unmodified-since stale":  {map[string]string{"If-Unmodified-Since": before}, "a", true},
+		"if-match precedence":        {map[string]string{"If-Match": `"a"`, "If-Unmodified-Since": before}, "a", false},
+		"if-match precedence failed": {map[string]string{"If-Match": `"b"`, "If-Unmodified-Since": after}, "a", true},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPut, "/items/1", nil)
+			for k, v := range tt.header {
+				r.Header.Set(k, v)
+			}
+
+			err := Precondition(r, tt.etag, modified)
+			if !tt.failed {
+				if err != nil {
+					t.Fatalf("expecting nil error but got %v", err)
+				}
+				return
+			}
+
+			var httpErr *mux.Error
+			if !errors.As(err, &httpErr) || httpErr.Status != http.StatusPreconditionFailed {
+				t.Fatalf("expecting status 412 but got %v", err)
+			}
+		})
+	}
+}
+
+func TestRestAPI_NotModified(t *testing.T) {
+	modified := time.Date(2021, 12, 15, 10, 0, 0, 0, time.UTC)
+	data := map[string]interface{}{"id": 1, "name": "a"}
+	etag, err := ETag(nil, data)
+	if err != nil {
+		t.Fatalf("expecting nil error but got %v", err)
+	}
+
+	tests := map[string]struct {
+		options []Option
+		header  map[string]string
+	}{
+		"computed etag": {
+			header: map[string]string{"If-None-Match": `"outdated", W/` + etag},
+		},
+		"supplied etag": {
+			options: []Option{WithETag("v2")},
+			header:  map[string]string{"If-None-Match": `"v1", "v2"`},
+		},
+		"last modified": {
+			options: []Option{WithLastModified(modified)},
+			header:  map[string]string{"If-Modified-Since": modified.Format(http.TimeFormat)},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/items/1", nil)
+			for k, v := range tt.header {
+				r.Header.Set(k, v)
+			}
+
+			opts := append([]Option{WithRequest(r)}, tt.options...)
+
+			ctx, state := withState(r.Context())
+			rec := httptest.NewRecorder()
+			if err := RestAPI(ctx, rec, data, http.StatusOK, opts...); err != nil {
+				t.Fatalf("expecting nil error but got %v", err)
+			}
+
+			if rec.Code != http.StatusNotModified || state.StatusCode != http.StatusNotModified {
+				t.Fatalf("expecting status 304 but got %d and state %d", rec.Code, state.StatusCode)
+			}
+
+			if rec.Body.Len() != 0 {
+				t.Fatalf("expecting the empty body but got %s", rec.Body.String())
+			}
+
+			if got := rec.Header().Get("Content-Type"); got != "" {
+				t.Fatalf("expecting no Content-Type but got %s", got)
+			}
+
+			// the validators are still sent, so the client can refresh its cache.
+			if rec.Header().Get("ETag") == "" {
+				t.Fatalf("expecting the ETag is sent")
+			}
+		})
+	}
+}
+
+func TestRestAPI_Modified(t *testing.T) {
+	data := map[string]interface{}{"id": 1, "name": "a"}
+	etag, err := ETag(nil, data)
+	if err != nil {
+		t.Fatalf("expecting nil error but got %v", err)
+	}
+
+	tests := map[string]struct {
+		method string
+		status int
+		header string
+	}{
+		"outdated":        {http.MethodGet, http.StatusOK, `"outdated"`},
+		"created":         {http.MethodPost, http.StatusCreated, etag},
+		"not ok":          {http.MethodGet, http.StatusAccepted, etag},
+		"without request": {"", http.StatusOK, etag},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var opts []Option
+			r := httptest.NewRequest(http.MethodGet, "/items/1", nil)
+			if tt.method != "" {
+				r.Method = tt.method
+				opts = append(opts, WithRequest(r))
+			}
+			r.Header.Set("If-None-Match", tt.header)
+
+			ctx, _ := withState(r.Context())
+			rec := httptest.NewRecorder()
+			if err := RestAPI(ctx, rec, data, tt.status, opts...); err != nil {
+				t.Fatalf("expecting nil error but got %v", err)
+			}
+
+			if rec.Code != tt.status || rec.Body.Len() == 0 {
+				t.Fatalf("expecting status %d with the body but got %d", tt.status, rec.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package serialize
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/josestg/justforfun/pkg/mux"
+)
+
+// ProblemContentType is the media type of the RFC 7807 problem details.
+const ProblemContentType = "application/problem+json"
+
+// ProblemDetails represents the RFC 7807 problem details.
+// see: https://datatracker.ietf.org/doc/html/rfc7807.
+type ProblemDetails struct {
+	Type     string              `json:"type"`
+	Title    string              `json:"title"`
+	Status   int                 `json:"status"`
+	Detail   string              `json:"detail,omitempty"`
+	Instance string              `json:"instance,omitempty"`
+	Code     string              `json:"code,omitempty"`
+	Errors   map[string][]string `json:"errors,omitempty"`
+}
+
+// NewProblemDetails creates the problem details from the given error.
+// The internal cause of the error is never included, and neither is the detail
+// of the 500 Internal Server Error, since it is often built from the cause.
+func NewProblemDetails(err error, instance string) *ProblemDetails {
+	e := mux.ToError(err)
+
+	detail := e.Detail
+	if e.Status == http.StatusInternalServerError {
+		detail = ""
+	}
+
+	return &ProblemDetails{
+		Type:     "about:blank",
+		Title:    http.StatusText(e.Status),
+		Status:   e.Status,
+		Detail:   detail,
+		Instance: instance,
+		Code:     e.Code,
+		Errors:   e.Fields,
+	}
+}
+
+// Problem encodes the given error as the RFC 7807 problem details and write it the given w.
+// The error is recorded into the request state, so the middleware can log the internal cause.
+func Problem(ctx context.Context, w http.ResponseWriter, err error, instance string) error {
+	// If the context is missing this value, this is a serious problem,
+	// because Mux Handle is never executed.
+	v, stateErr := mux.GetState(ctx)
+	if stateErr != nil {
+		return mux.NewShutdownError(stateErr.Error())
+	}
+
+	problem := NewProblemDetails(err, instance)
+
+	// Add status code and the error into ContextValue.
+	// So, the next/after middleware can use it.
+	v.StatusCode = problem.Status
+	v.Err = err
+
+	jsonData, err := json.Marshal(problem)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(problem.Status)
+
+	if _, err := w.Write(jsonData); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ProblemErrorHandler is a mux.ErrorHandler that writes the errors as
+// the RFC 7807 problem details.
+func ProblemErrorHandler(w http.ResponseWriter, r *http.Request, err error) {
+	// the response can not be fixed if writing fails, the client may have gone.
+	_ = Problem(r.Context(), w, err, r.URL.Path)
+}
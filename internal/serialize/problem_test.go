@@ -0,0 +1,123 @@
+package serialize
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/josestg/justforfun/pkg/mux"
+	"github.com/josestg/justforfun/pkg/validate"
+)
+
+// withState returns the context with a mux.State, as the router does.
+func withState(ctx context.Context) (context.Context, *mux.State) {
+	state := &mux.State{StatusCode: http.StatusOK}
+	return context.WithValue(ctx, mux.StateKey, state), state
+}
+
+func TestProblemErrorHandler(t *testing.T) {
+	secret := errors.New("pq: password authentication failed for user admin")
+
+	tests := map[string]struct {
+		err  error
+		want ProblemDetails
+	}{
+		"client error": {
+			err:  mux.NewError(http.StatusNotFound, "user_not_found", "the user does not exist"),
+			want: ProblemDetails{Status: http.StatusNotFound, Title: "Not Found", Code: "user_not_found", Detail: "the user does not exist"},
+		},
+		"wrapped client error": {
+			err:  fmt.Errorf("finding user: %w", mux.WrapError(secret, http.StatusConflict, "user_exists", "the email is taken")),
+			want: ProblemDetails{Status: http.StatusConflict, Title: "Conflict", Code: "user_exists", Detail: "the email is taken"},
+		},
+		"validation error": {
+			err: validate.Errors{"name": {"is required"}},
+			want: ProblemDetails{
+				Status: http.StatusUnprocessableEntity,
+				Title:  "Unprocessable Entity",
+				Code:   "validation_failed",
+				Detail: "one or more fields are invalid",
+				Errors: map[string][]string{"name": {"is required"}},
+			},
+		},
+		"unknown error": {
+			err:  secret,
+			want: ProblemDetails{Status: http.StatusInternalServerError, Title: "Internal Server Error", Code: "internal_error"},
+		},
+		"internal error detail": {
+			err:  mux.WrapError(secret, http.StatusInternalServerError, "db_failed", secret.Error()),
+			want: ProblemDetails{Status: http.StatusInternalServerError, Title: "Internal Server Error", Code: "db_failed"},
+		},
+		"missing status": {
+			err:  &mux.Error{Code: "broken", Detail: secret.Error()},
+			want: ProblemDetails{Status: http.StatusInternalServerError, Title: "Internal Server Error", Code: "broken"},
+		},
+		"server error detail": {
+			err:  mux.WrapError(secret, http.StatusServiceUnavailable, "overloaded", "please try again later"),
+			want: ProblemDetails{Status: http.StatusServiceUnavailable, Title: "Service Unavailable", Code: "overloaded", Detail: "please try again later"},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/v1/users/42?q=1", nil)
+			ctx, state := withState(r.Context())
+			r = r.WithContext(ctx)
+
+			rec := httptest.NewRecorder()
+			ProblemErrorHandler(rec, r, tt.err)
+
+			if rec.Code != tt.want.Status || state.StatusCode != tt.want.Status {
+				t.Fatalf("expecting status %d but got %d and state %d", tt.want.Status, rec.Code, state.StatusCode)
+			}
+
+			if !reflect.DeepEqual(state.Err, tt.err) {
+				t.Fatalf("expecting the error is recorded into the state but got %v", state.Err)
+			}
+
+			if got := rec.Header().Get("Content-Type"); got != ProblemContentType {
+				t.Fatalf("expecting Content-Type %s but got %s", ProblemContentType, got)
+			}
+
+			if got := rec.Header().Get("X-Content-Type-Options"); got != "nosniff" {
+				t.Fatalf("expecting X-Content-Type-Options nosniff but got %q", got)
+			}
+
+			// the internal cause never leaks into the response.
+			if strings.Contains(rec.Body.String(), "password") {
+				t.Fatalf("expecting the cause is hidden but got %s", rec.Body.String())
+			}
+
+			var got ProblemDetails
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatalf("expecting nil error but got %v", err)
+			}
+
+			want := tt.want
+			want.Type = "about:blank"
+			want.Instance = "/v1/users/42"
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("expecting %+v but got %+v", want, got)
+			}
+		})
+	}
+}
+
+func TestProblem_MissingState(t *testing.T) {
+	rec := httptest.NewRecorder()
+	err := Problem(httptest.NewRequest(http.MethodGet, "/", nil).Context(), rec, errors.New("boom"), "/")
+
+	if !mux.IsShutdownError(err) {
+		t.Fatalf("expecting the shutdown error but got %v", err)
+	}
+
+	if rec.Body.Len() != 0 {
+		t.Fatalf("expecting nothing is written but got %s", rec.Body.String())
+	}
+}
@@ -8,8 +8,18 @@ import (
 	"github.com/josestg/justforfun/pkg/mux"
 )
 
-// RestAPI encodes the given data to JSON and write it the given w.
-func RestAPI(ctx context.Context, w http.ResponseWriter, data interface{}, status int) error {
+// RestAPI encodes the given data and write it the given w.
+//
+// The conditional GET and the content negotiation are enabled by the
+// WithRequest option, for example:
+//
+//	serialize.RestAPI(ctx, w, report, http.StatusOK, serialize.WithRequest(r))
+//
+// The data is encoded by the codec the request prefers in its Accept header,
+// see WithCodecs. If none of the acceptable codecs supports the data, for
+// example a CSV request of a single object, RestAPI returns a 406 Not
+// Acceptable error. Without the request, the data is encoded to JSON.
+func RestAPI(ctx context.Context, w http.ResponseWriter, data interface{}, status int, opts ...Option) error {
 	// If the context is missing this value, this is a serious problem,
 	// because Mux Handle is never executed.
 	v, err := mux.GetState(ctx)
@@ -27,22 +37,65 @@ func RestAPI(ctx context.Context, w http.ResponseWriter, data interface{}, statu
 		return nil
 	}
 
-	// Encode the data to JSON.
-	jsonData, err := json.Marshal(data)
+	o := options{codecs: DefaultCodecs}
+	for _, fn := range opts {
+		fn(&o)
+	}
+
+	// Encode the data by the negotiated codec.
+	if o.request != nil {
+		w.Header().Add("Vary", "Accept")
+	}
+
+	codec, body, err := encode(o, data)
 	if err != nil {
 		return err
 	}
 
+	// The validators only describe the successful representation.
+	if status >= 200 && status < 300 {
+		if o.request != nil && o.etag == "" {
+			// the representations differ by the Accept, so each of them
+			// has its own ETag, see ETag.
+			o.etag = computeETag(body)
+		}
+
+		if o.etag != "" {
+			w.Header().Set("ETag", o.etag)
+		}
+
+		if !o.lastModified.IsZero() {
+			w.Header().Set("Last-Modified", o.lastModified.UTC().Format(http.TimeFormat))
+		}
+
+		if o.request != nil && status == http.StatusOK && notModified(o.request, o.etag, o.lastModified) {
+			v.StatusCode = http.StatusNotModified
+			w.WriteHeader(http.StatusNotModified)
+			return nil
+		}
+	}
+
 	// Set the content type and headers once we know marshaling has succeeded.
-	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Type", codec.MediaType)
 
 	// WriteTo the status code to the response.
 	w.WriteHeader(status)
 
 	// Send the result back to the client.
-	if _, err := w.Write(jsonData); err != nil {
+	if _, err := w.Write(body); err != nil {
 		return err
 	}
 
 	return nil
 }
+
+// encode encodes the data by the codec negotiated for the request,
+// the data is encoded to JSON without the request.
+func encode(o options, data interface{}) (Codec, []byte, error) {
+	if o.request == nil {
+		body, err := json.Marshal(data)
+		return JSON, body, err
+	}
+
+	return negotiate(o.codecs, o.request.Header.Get("Accept"), data)
+}
@@ -0,0 +1,35 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/josestg/justforfun/pkg/pqx"
+
+	dHealth "github.com/josestg/justforfun/internal/domain/health"
+)
+
+// Postgres creates a checker that makes one round trip to the database.
+func Postgres(db *sql.DB) dHealth.Checker {
+	return dHealth.CheckerFunc(func(ctx context.Context) error {
+		return pqx.Ping(ctx, db)
+	})
+}
+
+// DiskSpace creates a checker that fails if the file system of the path has
+// less than minFree bytes available.
+func DiskSpace(path string, minFree uint64) dHealth.Checker {
+	return dHealth.CheckerFunc(func(_ context.Context) error {
+		free, err := diskFree(path)
+		if err != nil {
+			return err
+		}
+
+		if free < minFree {
+			return fmt.Errorf("%d bytes available on %s, the minimum is %d bytes", free, path, minFree)
+		}
+
+		return nil
+	})
+}
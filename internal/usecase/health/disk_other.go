@@ -0,0 +1,14 @@
+//go:build !linux && !darwin
+// +build !linux,!darwin
+
+package health
+
+import (
+	"errors"
+	"runtime"
+)
+
+// diskFree is not supported on this platform.
+func diskFree(_ string) (uint64, error) {
+	return 0, errors.New("disk space check is not supported on " + runtime.GOOS)
+}
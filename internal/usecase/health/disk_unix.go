@@ -0,0 +1,21 @@
+//go:build linux || darwin
+// +build linux darwin
+
+package health
+
+import (
+	"syscall"
+
+	"github.com/josestg/justforfun/pkg/xerrs"
+)
+
+// diskFree returns the bytes available to the unprivileged users on the file
+// system of the path.
+func diskFree(path string) (uint64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, xerrs.Wrap(err, "statfs "+path)
+	}
+
+	return uint64(stat.Bavail) * uint64(stat.Bsize), nil
+}
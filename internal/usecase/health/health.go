@@ -2,31 +2,218 @@ package health
 
 import (
 	"context"
+	"errors"
+	"sync"
+	"time"
 
 	"github.com/josestg/justforfun/internal/domain/sys"
 
 	dHealth "github.com/josestg/justforfun/internal/domain/health"
 )
 
+// serverCheck is the name of the check of the server readiness.
+const serverCheck = "server"
+
+// errNotReady is an error when the server is not serving or is shutting down.
+var errNotReady = errors.New("the server is not ready")
+
+// Option is option type for customize the UseCase.
+type Option func(u *UseCase)
+
+// WithInfo sets the system info of the reports.
+func WithInfo(info sys.Info) Option {
+	return func(u *UseCase) {
+		u.info = info
+	}
+}
+
+// WithSupport sets the support system info of the reports.
+func WithSupport(support sys.Support) Option {
+	return func(u *UseCase) {
+		u.support = support
+	}
+}
+
+// WithReadiness sets the function that tells whether the server is ready,
+// for example mux.Server.Ready. The server that is not ready is unhealthy.
+func WithReadiness(ready func() bool) Option {
+	return func(u *UseCase) {
+		u.ready = ready
+	}
+}
+
+// WithCacheTTL sets how long the result of each checker is reused.
+// Zero means the dependencies are checked on every readiness report.
+func WithCacheTTL(d time.Duration) Option {
+	return func(u *UseCase) {
+		u.cacheTTL = d
+	}
+}
+
+// WithCheckTimeout sets the time limit of each checker.
+func WithCheckTimeout(d time.Duration) Option {
+	return func(u *UseCase) {
+		u.checkTimeout = d
+	}
+}
+
+// WithCritical registers the checker of a critical dependency,
+// the instance is unhealthy if it fails.
+func WithCritical(name string, checker dHealth.Checker) Option {
+	return func(u *UseCase) {
+		u.dependencies = append(u.dependencies, &dependency{name: name, critical: true, checker: checker})
+	}
+}
+
+// WithOptional registers the checker of a non-critical dependency,
+// the instance is degraded if it fails.
+func WithOptional(name string, checker dHealth.Checker) Option {
+	return func(u *UseCase) {
+		u.dependencies = append(u.dependencies, &dependency{name: name, critical: false, checker: checker})
+	}
+}
+
 // UseCase implements the health check use case interface.
 type UseCase struct {
-	// todo: injects some dependencies here...
+	info         sys.Info
+	support      sys.Support
+	ready        func() bool
+	cacheTTL     time.Duration
+	checkTimeout time.Duration
+	dependencies []*dependency
 }
 
 // implementation checks.
 var _ dHealth.UseCase = &UseCase{}
 
 // NewUseCase creates a new health check use case.
-func NewUseCase() *UseCase {
-	return &UseCase{}
+func NewUseCase(options ...Option) *UseCase {
+	u := UseCase{
+		info:         sys.NewInfo("unknown", "unknown"),
+		cacheTTL:     5 * time.Second,
+		checkTimeout: 2 * time.Second,
+	}
+
+	for _, fn := range options {
+		fn(&u)
+	}
+
+	return &u
 }
 
-func (u *UseCase) HealthReport(_ context.Context) (*dHealth.Report, error) {
+func (u *UseCase) Liveness(_ context.Context) (*dHealth.Report, error) {
+	report := dHealth.Report{
+		Status:  dHealth.StatusHealthy,
+		Info:    u.info,
+		Support: u.support,
+	}
 
+	return &report, nil
+}
+
+func (u *UseCase) Readiness(_ context.Context) (*dHealth.Report, error) {
 	report := dHealth.Report{
-		Info:    sys.NewInfo("example-id", "develop"),
-		Support: sys.Support{},
+		Status:  dHealth.StatusHealthy,
+		Info:    u.info,
+		Support: u.support,
+		Checks:  make(map[string]dHealth.Check, len(u.dependencies)+1),
+	}
+
+	if u.ready != nil {
+		check := dHealth.Check{Status: dHealth.StatusHealthy, Critical: true, CheckedAt: time.Now()}
+		if !u.ready() {
+			check.Status = dHealth.StatusUnhealthy
+			check.Error = errNotReady.Error()
+		}
+		report.Checks[serverCheck] = check
+	}
+
+	checks := make([]dHealth.Check, len(u.dependencies))
+
+	var wg sync.WaitGroup
+	for i, d := range u.dependencies {
+		wg.Add(1)
+		go func(i int, d *dependency) {
+			defer wg.Done()
+			checks[i] = d.check(u.cacheTTL, u.checkTimeout)
+		}(i, d)
 	}
+	wg.Wait()
 
+	for i, d := range u.dependencies {
+		report.Checks[d.name] = checks[i]
+	}
+
+	report.Status = rollup(report.Checks)
 	return &report, nil
 }
+
+// rollup returns the status of the instance, it is unhealthy if a critical
+// dependency fails, or degraded if a non-critical dependency fails.
+func rollup(checks map[string]dHealth.Check) dHealth.Status {
+	status := dHealth.StatusHealthy
+	for _, c := range checks {
+		if c.Status == dHealth.StatusHealthy {
+			continue
+		}
+
+		if c.Critical {
+			return dHealth.StatusUnhealthy
+		}
+		status = dHealth.StatusDegraded
+	}
+
+	return status
+}
+
+// dependency is a registered checker with its cached result.
+type dependency struct {
+	name     string
+	critical bool
+	checker  dHealth.Checker
+
+	mu      sync.Mutex
+	last    dHealth.Check
+	expires time.Time
+}
+
+// check runs the checker, or returns the cached result if it has not expired.
+// The concurrent callers wait for the running check instead of running it
+// again, so a burst of probes does not hit the dependency.
+//
+// The checker runs with its own context, the canceled request must not be
+// cached as a failure of the dependency.
+func (d *dependency) check(ttl, timeout time.Duration) dHealth.Check {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now()
+	if now.Before(d.expires) {
+		return d.last
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	err := d.checker.Check(ctx)
+	latency := time.Since(now)
+
+	c := dHealth.Check{
+		Status:    dHealth.StatusHealthy,
+		Critical:  d.critical,
+		LatencyMS: float64(latency) / float64(time.Millisecond),
+		CheckedAt: now,
+	}
+
+	if err != nil {
+		c.Status = dHealth.StatusDegraded
+		if d.critical {
+			c.Status = dHealth.StatusUnhealthy
+		}
+		c.Error = err.Error()
+	}
+
+	d.last = c
+	d.expires = now.Add(ttl)
+	return c
+}
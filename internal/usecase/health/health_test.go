@@ -0,0 +1,232 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/josestg/justforfun/internal/domain/sys"
+
+	dHealth "github.com/josestg/justforfun/internal/domain/health"
+)
+
+// counter is a checker that counts its calls and returns err.
+type counter struct {
+	calls int32
+	err   error
+}
+
+func (c *counter) Check(_ context.Context) error {
+	atomic.AddInt32(&c.calls, 1)
+	return c.err
+}
+
+func (c *counter) Calls() int {
+	return int(atomic.LoadInt32(&c.calls))
+}
+
+func TestUseCase_Liveness(t *testing.T) {
+	info := sys.NewInfo("instance-1", "test")
+	failing := &counter{err: errors.New("down")}
+	u := NewUseCase(WithInfo(info), WithCritical("db", failing), WithReadiness(func() bool { return false }))
+
+	report, err := u.Liveness(context.Background())
+	if err != nil {
+		t.Fatalf("expecting nil error but got %v", err)
+	}
+
+	if report.Status != dHealth.StatusHealthy || report.Info != info || len(report.Checks) != 0 {
+		t.Fatalf("expecting the healthy report without checks but got %+v", report)
+	}
+
+	if failing.Calls() != 0 {
+		t.Fatalf("expecting the liveness does not check the dependencies")
+	}
+}
+
+func TestRollup(t *testing.T) {
+	healthy := dHealth.Check{Status: dHealth.StatusHealthy, Critical: true}
+	degraded := dHealth.Check{Status: dHealth.StatusDegraded}
+	unhealthy := dHealth.Check{Status: dHealth.StatusUnhealthy, Critical: true}
+
+	tests := map[string]struct {
+		checks map[string]dHealth.Check
+		want   dHealth.Status
+	}{
+		"no checks":         {nil, dHealth.StatusHealthy},
+		"all healthy":       {map[string]dHealth.Check{"a": healthy, "b": healthy}, dHealth.StatusHealthy},
+		"optional fails":    {map[string]dHealth.Check{"a": healthy, "b": degraded}, dHealth.StatusDegraded},
+		"critical fails":    {map[string]dHealth.Check{"a": unhealthy, "b": healthy}, dHealth.StatusUnhealthy},
+		"critical wins":     {map[string]dHealth.Check{"a": degraded, "b": unhealthy}, dHealth.StatusUnhealthy},
+		"optional only":     {map[string]dHealth.Check{"a": degraded, "b": degraded}, dHealth.StatusDegraded},
+		"critical degraded": {map[string]dHealth.Check{"a": {Status: dHealth.StatusDegraded, Critical: true}}, dHealth.StatusUnhealthy},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := rollup(tt.checks); got != tt.want {
+				t.Fatalf("expecting %s but got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestUseCase_Readiness(t *testing.T) {
+	down := errors.New("connection refused")
+
+	tests := map[string]struct {
+		ready    bool
+		critical error
+		optional error
+		want     dHealth.Status
+	}{
+		"healthy":          {true, nil, nil, dHealth.StatusHealthy},
+		"optional fails":   {true, nil, down, dHealth.StatusDegraded},
+		"critical fails":   {true, down, nil, dHealth.StatusUnhealthy},
+		"server not ready": {false, nil, nil, dHealth.StatusUnhealthy},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			u := NewUseCase(
+				WithReadiness(func() bool { return tt.ready }),
+				WithCritical("postgres", &counter{err: tt.critical}),
+				WithOptional("disk", &counter{err: tt.optional}),
+			)
+
+			// the canceled request does not fail the checks.
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			report, err := u.Readiness(ctx)
+			if err != nil {
+				t.Fatalf("expecting nil error but got %v", err)
+			}
+
+			if report.Status != tt.want {
+				t.Fatalf("expecting %s but got %s", tt.want, report.Status)
+			}
+
+			if len(report.Checks) != 3 {
+				t.Fatalf("expecting the server and the 2 dependency checks but got %+v", report.Checks)
+			}
+
+			checks := map[string]struct {
+				err      error
+				critical bool
+				failed   dHealth.Status
+			}{
+				"postgres": {tt.critical, true, dHealth.StatusUnhealthy},
+				"disk":     {tt.optional, false, dHealth.StatusDegraded},
+			}
+
+			for name, want := range checks {
+				c := report.Checks[name]
+				if c.Critical != want.critical || c.CheckedAt.IsZero() {
+					t.Fatalf("%s: expecting critical %v and the check time but got %+v", name, want.critical, c)
+				}
+
+				if want.err == nil && (c.Status != dHealth.StatusHealthy || c.Error != "") {
+					t.Fatalf("%s: expecting healthy but got %+v", name, c)
+				}
+
+				if want.err != nil && (c.Status != want.failed || c.Error != want.err.Error()) {
+					t.Fatalf("%s: expecting %s with the error but got %+v", name, want.failed, c)
+				}
+			}
+
+			if server := report.Checks[serverCheck]; !server.Critical || (server.Error != "") == tt.ready {
+				t.Fatalf("expecting the server check of readiness %v but got %+v", tt.ready, server)
+			}
+		})
+	}
+}
+
+func TestUseCase_CacheTTL(t *testing.T) {
+	t.Run("cached", func(t *testing.T) {
+		c := &counter{}
+		u := NewUseCase(WithCacheTTL(time.Minute), WithCritical("db", c))
+
+		// a burst of probes runs the checker once.
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, _ = u.Readiness(context.Background())
+			}()
+		}
+		wg.Wait()
+
+		if c.Calls() != 1 {
+			t.Fatalf("expecting 1 call but got %d", c.Calls())
+		}
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		c := &counter{}
+		u := NewUseCase(WithCacheTTL(10*time.Millisecond), WithCritical("db", c))
+
+		_, _ = u.Readiness(context.Background())
+		_, _ = u.Readiness(context.Background())
+		if c.Calls() != 1 {
+			t.Fatalf("expecting 1 call before the TTL but got %d", c.Calls())
+		}
+
+		time.Sleep(20 * time.Millisecond)
+		_, _ = u.Readiness(context.Background())
+		if c.Calls() != 2 {
+			t.Fatalf("expecting 2 calls after the TTL but got %d", c.Calls())
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		c := &counter{}
+		u := NewUseCase(WithCacheTTL(0), WithCritical("db", c))
+
+		_, _ = u.Readiness(context.Background())
+		_, _ = u.Readiness(context.Background())
+		if c.Calls() != 2 {
+			t.Fatalf("expecting 2 calls but got %d", c.Calls())
+		}
+	})
+}
+
+func TestUseCase_CheckTimeout(t *testing.T) {
+	hanging := dHealth.CheckerFunc(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	u := NewUseCase(
+		WithCheckTimeout(10*time.Millisecond),
+		WithCritical("db", hanging),
+		WithOptional("cache", hanging),
+	)
+
+	start := time.Now()
+	report, err := u.Readiness(context.Background())
+	if err != nil {
+		t.Fatalf("expecting nil error but got %v", err)
+	}
+
+	// the checkers run concurrently, so the report waits for one timeout.
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("expecting the checks are timed out but took %v", elapsed)
+	}
+
+	if report.Status != dHealth.StatusUnhealthy {
+		t.Fatalf("expecting unhealthy but got %s", report.Status)
+	}
+
+	for name, want := range map[string]dHealth.Status{"db": dHealth.StatusUnhealthy, "cache": dHealth.StatusDegraded} {
+		c := report.Checks[name]
+		if c.Status != want || !strings.Contains(c.Error, context.DeadlineExceeded.Error()) || c.LatencyMS < 10 {
+			t.Fatalf("%s: expecting %s by the deadline but got %+v", name, want, c)
+		}
+	}
+}
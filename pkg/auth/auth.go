@@ -0,0 +1,205 @@
+// Package auth provides the JWT bearer authentication middleware.
+// see: https://datatracker.ietf.org/doc/html/rfc6750.
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/josestg/justforfun/pkg/jwt"
+	"github.com/josestg/justforfun/pkg/mux"
+)
+
+// Header names of the bearer authentication.
+const (
+	AuthorizationHeader = "Authorization"
+	AuthenticateHeader  = "WWW-Authenticate"
+)
+
+// bearerScheme is the authorization scheme of the token, it is case-insensitive.
+const bearerScheme = "bearer"
+
+// Error codes of the responses, the codes are defined by RFC 6750 section 3.1
+// except the missing credentials and the insufficient role.
+const (
+	errMissingCredentials = "missing_credentials"
+	errInvalidToken       = "invalid_token"
+	errInsufficientScope  = "insufficient_scope"
+	errInsufficientRole   = "insufficient_role"
+)
+
+// Option is an option type that can be used to customize the Authenticator.
+type Option func(a *Authenticator)
+
+// WithCookie makes the Authenticator read the token from the cookie with the
+// given name when the request has no Authorization header.
+func WithCookie(name string) Option {
+	return func(a *Authenticator) {
+		a.cookie = name
+	}
+}
+
+// WithRealm sets the realm of the WWW-Authenticate challenge.
+func WithRealm(realm string) Option {
+	return func(a *Authenticator) {
+		a.realm = realm
+	}
+}
+
+// Authenticator authenticates the requests by their JWT bearer tokens.
+type Authenticator struct {
+	selector  jwt.VerifierSelector
+	newClaims func() interface{}
+	cookie    string
+	realm     string
+}
+
+// New creates a new Authenticator.
+//
+// The selector chooses the verifier of the token signature, and newClaims
+// returns a pointer to a new caller-defined claims value that the token
+// payload is decoded into, for example:
+//
+// 	auth.New(selector, func() interface{} { return new(auth.Claims) })
+//
+// If the claims implement jwt.Valid, such as the types that embed
+// jwt.StandardClaims, the expired or not active tokens are rejected.
+func New(selector jwt.VerifierSelector, newClaims func() interface{}, options ...Option) *Authenticator {
+	a := Authenticator{
+		selector:  selector,
+		newClaims: newClaims,
+	}
+
+	for _, fn := range options {
+		fn(&a)
+	}
+
+	return &a
+}
+
+// Authenticate decodes the token of the request into a new claims value.
+func (a *Authenticator) Authenticate(r *http.Request) (interface{}, error) {
+	token := a.token(r)
+	if token == "" {
+		return nil, unauthorized(errMissingCredentials, "missing bearer token", nil)
+	}
+
+	claims := a.newClaims()
+	if err := jwt.Decode(a.selector, token, claims); err != nil {
+		detail := "invalid token"
+		switch {
+		case errors.Is(err, jwt.ErrExpired):
+			detail = "token is expired"
+		case errors.Is(err, jwt.ErrNotBefore):
+			detail = "token is not active yet"
+		}
+
+		return nil, unauthorized(errInvalidToken, detail, err)
+	}
+
+	return claims, nil
+}
+
+// Middleware creates a middleware that rejects the requests without a valid
+// token with 401 Unauthorized and a WWW-Authenticate challenge, and stores the
+// claims of the valid token in the request context, see ClaimsFromContext.
+func (a *Authenticator) Middleware() mux.Middleware {
+	return func(handler mux.Handler) mux.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) error {
+			claims, err := a.Authenticate(r)
+			if err != nil {
+				var httpErr *mux.Error
+				if errors.As(err, &httpErr) {
+					w.Header().Set(AuthenticateHeader, challenge(a.realm, httpErr.Code, httpErr.Detail, ""))
+				}
+				return err
+			}
+
+			ctx := ContextWithClaims(r.Context(), claims)
+			return handler.ServeHTTP(w, r.WithContext(ctx))
+		}
+
+		return mux.HandlerFunc(fn)
+	}
+}
+
+// token returns the bearer token of the Authorization header,
+// or of the cookie if the header is missing.
+func (a *Authenticator) token(r *http.Request) string {
+	if h := r.Header.Get(AuthorizationHeader); h != "" {
+		i := strings.IndexByte(h, ' ')
+		if i < 0 || !strings.EqualFold(h[:i], bearerScheme) {
+			return ""
+		}
+		return strings.TrimSpace(h[i+1:])
+	}
+
+	if a.cookie != "" {
+		if c, err := r.Cookie(a.cookie); err == nil {
+			return c.Value
+		}
+	}
+
+	return ""
+}
+
+// unauthorized creates the 401 Unauthorized error.
+func unauthorized(code, detail string, cause error) error {
+	err := mux.NewError(http.StatusUnauthorized, code, detail)
+	err.Cause = cause
+	return err
+}
+
+// challenge formats the WWW-Authenticate header value with the non-empty
+// attributes. The missing credentials has no error attributes, as RFC 6750
+// section 3.1 requires.
+func challenge(realm, code, detail, scope string) string {
+	attrs := make([]string, 0, 4)
+	if realm != "" {
+		attrs = append(attrs, fmt.Sprintf("realm=%q", realm))
+	}
+
+	if code != "" && code != errMissingCredentials {
+		attrs = append(attrs, fmt.Sprintf("error=%q", code), fmt.Sprintf("error_description=%q", detail))
+	}
+
+	if scope != "" {
+		attrs = append(attrs, fmt.Sprintf("scope=%q", scope))
+	}
+
+	if len(attrs) == 0 {
+		return "Bearer"
+	}
+	return "Bearer " + strings.Join(attrs, ", ")
+}
+
+// contextKey is the type of the context keys of this package.
+type contextKey int
+
+const claimsKey = contextKey(0)
+
+// ContextWithClaims returns a copy of ctx that holds the claims.
+func ContextWithClaims(ctx context.Context, claims interface{}) context.Context {
+	return context.WithValue(ctx, claimsKey, claims)
+}
+
+// ClaimsFromContext returns the claims stored by the Authenticator middleware,
+// or nil if the request is not authenticated. The claims have the type
+// returned by the newClaims function of the Authenticator.
+func ClaimsFromContext(ctx context.Context) interface{} {
+	return ctx.Value(claimsKey)
+}
+
+// Subject returns the subject of the claims in ctx if the claims implement
+// SubjectClaims, otherwise empty. It can be used as the rate limit key:
+//
+// 	ratelimit.FromContext("sub", auth.Subject)
+func Subject(ctx context.Context) string {
+	if c, ok := ClaimsFromContext(ctx).(SubjectClaims); ok {
+		return c.SubjectID()
+	}
+	return ""
+}
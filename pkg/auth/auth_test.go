@@ -0,0 +1,201 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"errors"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/josestg/justforfun/pkg/jwt"
+	"github.com/josestg/justforfun/pkg/mux"
+)
+
+type testKeys struct {
+	signer   *jwt.RSASigner
+	selector jwt.VerifierSelector
+}
+
+func newTestKeys(t *testing.T) *testKeys {
+	t.Helper()
+
+	private, err := rsa.GenerateKey(rand.New(rand.NewSource(1)), 2048)
+	if err != nil {
+		t.Fatalf("expecting nil but got %v", err)
+	}
+
+	verifier := jwt.NewRSAVerifier(crypto.SHA256, &private.PublicKey)
+	return &testKeys{
+		signer: jwt.NewRSASigner("kid-1", crypto.SHA256, private),
+		selector: func(header jwt.Header) (jwt.Verifier, error) {
+			if header["kid"] != "kid-1" {
+				return nil, errors.New("unknown kid")
+			}
+			return verifier, nil
+		},
+	}
+}
+
+func (k *testKeys) token(t *testing.T, claims Claims) string {
+	t.Helper()
+
+	token, err := jwt.Encode(k.signer, jwt.Header{}, claims)
+	if err != nil {
+		t.Fatalf("expecting nil but got %v", err)
+	}
+	return token
+}
+
+func newTestRouter(a *Authenticator, guards ...mux.Middleware) *mux.Router {
+	router := mux.NewRouter(nil)
+	router.Handle("GET /me", mux.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		_, _ = w.Write([]byte(Subject(r.Context())))
+		return nil
+	}), append([]mux.Middleware{a.Middleware()}, guards...)...)
+	return router
+}
+
+func TestAuthenticator_Middleware(t *testing.T) {
+	keys := newTestKeys(t)
+	a := New(keys.selector, func() interface{} { return new(Claims) }, WithCookie("session"), WithRealm("api"))
+	router := newTestRouter(a)
+
+	valid := keys.token(t, Claims{StandardClaims: jwt.StandardClaims{
+		Subject:   "user-1",
+		ExpiresAt: jwt.NewTime(time.Now().Add(time.Hour)),
+	}})
+
+	expired := keys.token(t, Claims{StandardClaims: jwt.StandardClaims{
+		Subject:   "user-1",
+		ExpiresAt: jwt.NewTime(time.Now().Add(-time.Hour)),
+	}})
+
+	tests := []struct {
+		name      string
+		header    string
+		cookie    string
+		status    int
+		body      string
+		challenge string
+	}{
+		{"bearer header", "Bearer " + valid, "", http.StatusOK, "user-1", ""},
+		{"lowercase scheme", "bearer " + valid, "", http.StatusOK, "user-1", ""},
+		{"cookie", "", valid, http.StatusOK, "user-1", ""},
+		{"missing", "", "", http.StatusUnauthorized, "", `Bearer realm="api"`},
+		{"other scheme", "Basic dXNlcjpwYXNz", valid, http.StatusUnauthorized, "", `Bearer realm="api"`},
+		{"expired", "Bearer " + expired, "", http.StatusUnauthorized, "", `Bearer realm="api", error="invalid_token", error_description="token is expired"`},
+		{"malformed", "Bearer abc", "", http.StatusUnauthorized, "", `Bearer realm="api", error="invalid_token", error_description="invalid token"`},
+		{"bad signature", "Bearer " + valid[:len(valid)-4] + "AAAA", "", http.StatusUnauthorized, "", `Bearer realm="api", error="invalid_token", error_description="invalid token"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/me", nil)
+			if tt.header != "" {
+				req.Header.Set(AuthorizationHeader, tt.header)
+			}
+
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: "session", Value: tt.cookie})
+			}
+
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("expecting status %d but got %d", tt.status, rec.Code)
+			}
+
+			if tt.status == http.StatusOK && rec.Body.String() != tt.body {
+				t.Fatalf("expecting body %q but got %q", tt.body, rec.Body.String())
+			}
+
+			if got := rec.Header().Get(AuthenticateHeader); got != tt.challenge {
+				t.Fatalf("expecting challenge %q but got %q", tt.challenge, got)
+			}
+		})
+	}
+}
+
+func TestRequireScope(t *testing.T) {
+	keys := newTestKeys(t)
+	a := New(keys.selector, func() interface{} { return new(Claims) })
+	router := newTestRouter(a, RequireScope("users:read", "users:write"))
+
+	tests := []struct {
+		scope  string
+		status int
+	}{
+		{"users:read users:write profile", http.StatusOK},
+		{"users:read", http.StatusForbidden},
+		{"", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/me", nil)
+		req.Header.Set(AuthorizationHeader, "Bearer "+keys.token(t, Claims{Scope: tt.scope}))
+
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		if rec.Code != tt.status {
+			t.Errorf("%q: expecting status %d but got %d", tt.scope, tt.status, rec.Code)
+		}
+
+		want := ""
+		if tt.status == http.StatusForbidden {
+			want = `Bearer error="insufficient_scope", error_description="token does not grant the scope users:write", scope="users:read users:write"`
+			if tt.scope == "" {
+				want = `Bearer error="insufficient_scope", error_description="token does not grant the scope users:read", scope="users:read users:write"`
+			}
+		}
+
+		if got := rec.Header().Get(AuthenticateHeader); got != want {
+			t.Errorf("%q: expecting challenge %q but got %q", tt.scope, want, got)
+		}
+	}
+}
+
+func TestRequireRole(t *testing.T) {
+	keys := newTestKeys(t)
+	a := New(keys.selector, func() interface{} { return new(Claims) })
+	router := newTestRouter(a, RequireRole("admin", "owner"))
+
+	tests := []struct {
+		roles  []string
+		status int
+	}{
+		{[]string{"member", "owner"}, http.StatusOK},
+		{[]string{"member"}, http.StatusForbidden},
+		{nil, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/me", nil)
+		req.Header.Set(AuthorizationHeader, "Bearer "+keys.token(t, Claims{Roles: tt.roles}))
+
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		if rec.Code != tt.status {
+			t.Errorf("%v: expecting status %d but got %d", tt.roles, tt.status, rec.Code)
+		}
+	}
+}
+
+func TestRequireScope_Unauthenticated(t *testing.T) {
+	router := mux.NewRouter(nil)
+	router.Handle("GET /me", mux.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		return nil
+	}), RequireScope("users:read"))
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/me", nil))
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expecting status %d but got %d", http.StatusUnauthorized, rec.Code)
+	}
+}
@@ -0,0 +1,114 @@
+package auth
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/josestg/justforfun/pkg/jwt"
+	"github.com/josestg/justforfun/pkg/mux"
+)
+
+// SubjectClaims is implemented by the claims that identify their subject.
+type SubjectClaims interface {
+	// SubjectID returns the subject of the claims.
+	SubjectID() string
+}
+
+// ScopeClaims is implemented by the claims that grant the scopes, see RequireScope.
+type ScopeClaims interface {
+	// HasScope reports whether the scope is granted.
+	HasScope(scope string) bool
+}
+
+// RoleClaims is implemented by the claims that grant the roles, see RequireRole.
+type RoleClaims interface {
+	// HasRole reports whether the role is granted.
+	HasRole(role string) bool
+}
+
+// Claims is the claims with the standard claims, the space-separated OAuth 2.0
+// scopes and the roles. It can be used as the Authenticator claims, or the
+// caller can define its own claims type.
+type Claims struct {
+	jwt.StandardClaims
+	Scope string   `json:"scope,omitempty"`
+	Roles []string `json:"roles,omitempty"`
+}
+
+func (c *Claims) SubjectID() string { return c.Subject }
+
+func (c *Claims) HasScope(scope string) bool {
+	for _, s := range strings.Fields(c.Scope) {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Claims) HasRole(role string) bool {
+	for _, r := range c.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// RequireScope creates a route middleware that allows the requests whose
+// claims grant all the given scopes, otherwise it responds with 403 Forbidden.
+// The route must be authenticated by the Authenticator middleware first.
+func RequireScope(scopes ...string) mux.Middleware {
+	required := strings.Join(scopes, " ")
+
+	return func(handler mux.Handler) mux.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) error {
+			claims := ClaimsFromContext(r.Context())
+			if claims == nil {
+				w.Header().Set(AuthenticateHeader, challenge("", errMissingCredentials, "", ""))
+				return unauthorized(errMissingCredentials, "missing bearer token", nil)
+			}
+
+			granted, ok := claims.(ScopeClaims)
+			for _, scope := range scopes {
+				if !ok || !granted.HasScope(scope) {
+					detail := "token does not grant the scope " + scope
+					w.Header().Set(AuthenticateHeader, challenge("", errInsufficientScope, detail, required))
+					return mux.NewError(http.StatusForbidden, errInsufficientScope, detail)
+				}
+			}
+
+			return handler.ServeHTTP(w, r)
+		}
+
+		return mux.HandlerFunc(fn)
+	}
+}
+
+// RequireRole creates a route middleware that allows the requests whose
+// claims grant any of the given roles, otherwise it responds with 403 Forbidden.
+// The route must be authenticated by the Authenticator middleware first.
+func RequireRole(roles ...string) mux.Middleware {
+	return func(handler mux.Handler) mux.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) error {
+			claims := ClaimsFromContext(r.Context())
+			if claims == nil {
+				w.Header().Set(AuthenticateHeader, challenge("", errMissingCredentials, "", ""))
+				return unauthorized(errMissingCredentials, "missing bearer token", nil)
+			}
+
+			if granted, ok := claims.(RoleClaims); ok {
+				for _, role := range roles {
+					if granted.HasRole(role) {
+						return handler.ServeHTTP(w, r)
+					}
+				}
+			}
+
+			detail := "token does not grant any of the roles " + strings.Join(roles, ", ")
+			return mux.NewError(http.StatusForbidden, errInsufficientRole, detail)
+		}
+
+		return mux.HandlerFunc(fn)
+	}
+}
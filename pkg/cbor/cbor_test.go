@@ -0,0 +1,311 @@
+package cbor
+
+import (
+	"encoding/hex"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// The examples of RFC 8949 appendix A.
+func TestMarshal_Examples(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  string
+	}{
+		{0, "00"},
+		{1, "01"},
+		{10, "0a"},
+		{23, "17"},
+		{24, "1818"},
+		{100, "1864"},
+		{1000, "1903e8"},
+		{1000000, "1a000f4240"},
+		{int64(1000000000000), "1b000000e8d4a51000"},
+		{uint64(18446744073709551615), "1bffffffffffffffff"},
+		{-1, "20"},
+		{-10, "29"},
+		{-100, "3863"},
+		{-1000, "3903e7"},
+		{0.0, "f90000"},
+		{math.Copysign(0, -1), "f98000"},
+		{1.0, "f93c00"},
+		{1.1, "fb3ff199999999999a"},
+		{1.5, "f93e00"},
+		{65504.0, "f97bff"},
+		{100000.0, "fa47c35000"},
+		{3.4028234663852886e+38, "fa7f7fffff"},
+		{1.0e+300, "fb7e37e43c8800759c"},
+		{5.960464477539063e-8, "f90001"},
+		{0.00006103515625, "f90400"},
+		{-4.0, "f9c400"},
+		{-4.1, "fbc010666666666666"},
+		{math.Inf(1), "f97c00"},
+		{math.NaN(), "f97e00"},
+		{math.Inf(-1), "f9fc00"},
+		{false, "f4"},
+		{true, "f5"},
+		{nil, "f6"},
+		{[]byte{}, "40"},
+		{[]byte{1, 2, 3, 4}, "4401020304"},
+		{"", "60"},
+		{"a", "6161"},
+		{"IETF", "6449455446"},
+		{"\"\\", "62225c"},
+		{"ü", "62c3bc"},
+		{"水", "63e6b0b4"},
+		{[]int{}, "80"},
+		{[]int{1, 2, 3}, "83010203"},
+		{[]interface{}{1, []int{2, 3}, []int{4, 5}}, "8301820203820405"},
+		{map[string]int{}, "a0"},
+		{map[int]int{1: 2, 3: 4}, "a201020304"},
+		{map[string]interface{}{"a": 1, "b": []int{2, 3}}, "a26161016162820203"},
+		{[]interface{}{"a", map[string]string{"b": "c"}}, "826161a161626163"},
+		{map[string]string{"a": "A", "b": "B", "c": "C", "d": "D", "e": "E"}, "a56161614161626142616361436164614461656145"},
+		{time.Date(2013, 3, 21, 20, 4, 0, 0, time.UTC), "c074323031332d30332d32315432303a30343a30305a"},
+	}
+
+	for _, tt := range tests {
+		b, err := Marshal(tt.value)
+		if err != nil {
+			t.Fatalf("marshaling %#v: %v", tt.value, err)
+		}
+
+		if got := hex.EncodeToString(b); got != tt.want {
+			t.Errorf("marshaling %#v: expecting %s but got %s", tt.value, tt.want, got)
+		}
+	}
+}
+
+type inner struct {
+	Name string `json:"name"`
+}
+
+type record struct {
+	inner
+	ID      int64             `json:"id"`
+	Tags    []string          `json:"tags,omitempty"`
+	Data    []byte            `json:"data"`
+	Secret  string            `json:"-"`
+	Labels  map[string]string `json:"labels,omitempty"`
+	private int
+}
+
+func TestMarshal_Struct(t *testing.T) {
+	b, err := Marshal(record{inner: inner{Name: "a"}, ID: 1, Data: []byte{1}, Secret: "s", private: 1})
+	if err != nil {
+		t.Fatalf("expecting nil error but got %v", err)
+	}
+
+	// {"id": 1, "data": h'01', "name": "a"}, sorted by the encoded keys.
+	if got, want := hex.EncodeToString(b), "a36269640164646174614101646e616d656161"; got != want {
+		t.Fatalf("expecting %s but got %s", want, got)
+	}
+}
+
+func TestMarshal_UnsupportedType(t *testing.T) {
+	_, err := Marshal(map[string]interface{}{"fn": func() {}})
+	if !errors.Is(err, ErrUnsupportedType) {
+		t.Fatalf("expecting ErrUnsupportedType but got %v", err)
+	}
+}
+
+func TestUnmarshal_Examples(t *testing.T) {
+	tests := []struct {
+		data string
+		want interface{}
+	}{
+		{"00", int64(0)},
+		{"1bffffffffffffffff", uint64(18446744073709551615)},
+		{"3903e7", int64(-1000)},
+		{"f90001", 5.960464477539063e-8},
+		{"f97bff", 65504.0},
+		{"f9c400", -4.0},
+		{"fa47c35000", 100000.0},
+		{"fb3ff199999999999a", 1.1},
+		{"f97c00", math.Inf(1)},
+		{"f7", nil},
+		{"4401020304", []byte{1, 2, 3, 4}},
+		{"62c3bc", "ü"},
+		{"8301820203820405", []interface{}{int64(1), []interface{}{int64(2), int64(3)}, []interface{}{int64(4), int64(5)}}},
+		{"a201020304", map[string]interface{}{"1": int64(2), "3": int64(4)}},
+		{"c074323031332d30332d32315432303a30343a30305a", "2013-03-21T20:04:00Z"},
+		{"c11a514b67b0", time.Unix(1363896240, 0).UTC()},
+		{"c1fb41d452d9ec200000", time.Unix(1363896240, 500000000).UTC()},
+		{"5f42010243030405ff", []byte{1, 2, 3, 4, 5}},
+		{"7f657374726561646d696e67ff", "streaming"},
+		{"9fff", []interface{}{}},
+		{"9f018202039f0405ffff", []interface{}{int64(1), []interface{}{int64(2), int64(3)}, []interface{}{int64(4), int64(5)}}},
+		{"bf61610161629f0203ffff", map[string]interface{}{"a": int64(1), "b": []interface{}{int64(2), int64(3)}}},
+	}
+
+	for _, tt := range tests {
+		data, _ := hex.DecodeString(tt.data)
+
+		var got interface{}
+		if err := Unmarshal(data, &got); err != nil {
+			t.Fatalf("unmarshaling %s: %v", tt.data, err)
+		}
+
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("unmarshaling %s: expecting %#v but got %#v", tt.data, tt.want, got)
+		}
+	}
+}
+
+func TestUnmarshal_Struct(t *testing.T) {
+	want := record{inner: inner{Name: "a"}, ID: -7, Tags: []string{"x"}, Data: []byte{1, 2}, Labels: map[string]string{"k": "v"}}
+
+	b, err := Marshal(want)
+	if err != nil {
+		t.Fatalf("expecting nil error but got %v", err)
+	}
+
+	var got record
+	if err := Unmarshal(b, &got); err != nil {
+		t.Fatalf("expecting nil error but got %v", err)
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expecting %+v but got %+v", want, got)
+	}
+}
+
+func TestUnmarshal_RoundTrip(t *testing.T) {
+	type values struct {
+		Inf      float64   `json:"inf"`
+		NegInf   float32   `json:"neg_inf"`
+		NaN      float64   `json:"nan"`
+		Text     string    `json:"text"`
+		Bytes    [2]byte   `json:"bytes"`
+		Created  time.Time `json:"created"`
+		Optional *int      `json:"optional"`
+		Counts   map[int]uint8
+	}
+
+	two := 2
+	want := values{
+		Inf:      math.Inf(1),
+		NegInf:   float32(math.Inf(-1)),
+		NaN:      math.NaN(),
+		Text:     "hi",
+		Bytes:    [2]byte{1, 2},
+		Created:  time.Date(2021, 12, 15, 10, 0, 0, 500, time.UTC),
+		Optional: &two,
+		Counts:   map[int]uint8{-1: 1, 2: 3},
+	}
+
+	b, err := Marshal(want)
+	if err != nil {
+		t.Fatalf("expecting nil error but got %v", err)
+	}
+
+	var got values
+	if err := Unmarshal(b, &got); err != nil {
+		t.Fatalf("expecting nil error but got %v", err)
+	}
+
+	if !math.IsNaN(got.NaN) {
+		t.Fatalf("expecting NaN but got %v", got.NaN)
+	}
+
+	// NaN is never equal to itself.
+	got.NaN, want.NaN = 0, 0
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expecting %+v but got %+v", want, got)
+	}
+
+	t.Run("bytes into string", func(t *testing.T) {
+		b, err := Marshal(map[string]interface{}{"text": []byte("hi"), "created": 1})
+		if err != nil {
+			t.Fatalf("expecting nil error but got %v", err)
+		}
+
+		var got struct {
+			Text    string      `json:"text"`
+			Created interface{} `json:"CREATED"`
+		}
+		if err := Unmarshal(b, &got); err != nil {
+			t.Fatalf("expecting nil error but got %v", err)
+		}
+
+		if got.Text != "hi" || got.Created != int64(1) {
+			t.Fatalf("expecting the raw bytes and the case-insensitive field but got %+v", got)
+		}
+	})
+
+	t.Run("epoch time", func(t *testing.T) {
+		data, _ := hex.DecodeString("c11a514b67b0")
+
+		var got time.Time
+		if err := Unmarshal(data, &got); err != nil || !got.Equal(time.Unix(1363896240, 0)) {
+			t.Fatalf("expecting the epoch time but got %v and error %v", got, err)
+		}
+	})
+}
+
+func TestUnmarshal_TypeMismatch(t *testing.T) {
+	tests := map[string]struct {
+		data string
+		dst  interface{}
+	}{
+		"text into integer":      {"6161", new(int)},
+		"negative into unsigned": {"20", new(uint)},
+		"integer overflow":       {"190100", new(uint8)},
+		"float into integer":     {"f93e00", new(int)},
+		"array into struct":      {"8101", new(inner)},
+		"map into slice":         {"a0", new([]int)},
+		"integer into time":      {"01", new(time.Time)},
+		"invalid time":           {"c06161", new(time.Time)},
+		"field type":             {"a1646e616d6501", new(inner)},
+		"non-integer map key":    {"a1616101", new(map[int]int)},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			data, _ := hex.DecodeString(tt.data)
+			if err := Unmarshal(data, tt.dst); !errors.Is(err, ErrTypeMismatch) {
+				t.Fatalf("expecting ErrTypeMismatch but got %v", err)
+			}
+		})
+	}
+}
+
+func TestUnmarshal_Malformed(t *testing.T) {
+	tests := []string{
+		"",                   // empty.
+		"18",                 // missing argument.
+		"1c",                 // reserved additional information.
+		"62c3",               // truncated text.
+		"9b00000000ffffffff", // length larger than the data.
+		"8201",               // missing array element.
+		"9f01",               // missing break.
+		"ff",                 // unexpected break.
+		"5f6161ff",           // text chunk in byte string.
+		"0101",               // trailing data.
+		"3bffffffffffffffff", // negative integer overflows.
+		"c16161",             // text epoch time.
+		"c1f97c00",           // infinite epoch time.
+	}
+
+	for _, data := range tests {
+		b, _ := hex.DecodeString(data)
+
+		var v interface{}
+		if err := Unmarshal(b, &v); !errors.Is(err, ErrMalformed) {
+			t.Errorf("unmarshaling %q: expecting ErrMalformed but got %v", data, err)
+		}
+	}
+
+	deep := make([]byte, maxDepth+2)
+	for i := range deep {
+		deep[i] = 0x81
+	}
+
+	var v interface{}
+	if err := Unmarshal(deep, &v); !errors.Is(err, ErrMalformed) {
+		t.Fatalf("expecting ErrMalformed of the deep nesting but got %v", err)
+	}
+}
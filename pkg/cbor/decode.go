@@ -0,0 +1,606 @@
+package cbor
+
+import (
+	"encoding"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxDepth is the maximum nesting depth of the arrays, maps and tags.
+const maxDepth = 64
+
+// Unmarshal decodes the CBOR data into v, which must be a non-nil pointer.
+//
+// The data item is decoded into the generic values first: the integers, the
+// floats, the strings, []byte, []interface{} and map[string]interface{}, where
+// the non-text map keys are formatted as text. The epoch date/time (tag 1) is
+// decoded as time.Time, the contents of the other tags are kept as is. Then,
+// the generic values are assigned to v the way encoding/json does, so the json
+// tags of v are honored, but without converting them to JSON: the infinities
+// and NaN are kept, the byte strings are assigned to the []byte and string
+// values as raw bytes, and the text strings are unmarshaled by the
+// encoding.TextUnmarshaler values such as time.Time. The value that can not be
+// assigned fails with ErrTypeMismatch.
+func Unmarshal(data []byte, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("%w: non-pointer %T", ErrUnsupportedType, v)
+	}
+
+	d := decoder{data: data}
+	item, err := d.value(0)
+	if err != nil {
+		return err
+	}
+
+	if d.off != len(d.data) {
+		return fmt.Errorf("%w: trailing data at offset %d", ErrMalformed, d.off)
+	}
+
+	return assign(item, rv.Elem())
+}
+
+// decoder decodes a single data item of data, starting at off.
+type decoder struct {
+	data []byte
+	off  int
+}
+
+// errorf creates the ErrMalformed error at the current offset.
+func (d *decoder) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s at offset %d", ErrMalformed, fmt.Sprintf(format, args...), d.off)
+}
+
+// next reads n bytes.
+func (d *decoder) next(n uint64) ([]byte, error) {
+	if n > uint64(len(d.data)-d.off) {
+		return nil, d.errorf("unexpected end of data")
+	}
+
+	b := d.data[d.off : d.off+int(n)]
+	d.off += int(n)
+	return b, nil
+}
+
+// head reads the initial byte, its additional information and the argument.
+func (d *decoder) head() (major byte, info byte, arg uint64, err error) {
+	b, err := d.next(1)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	major, info = b[0]>>5, b[0]&0x1f
+	switch {
+	case info < 24:
+		return major, info, uint64(info), nil
+	case info <= 27:
+		p, err := d.next(1 << (info - 24))
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		for _, c := range p {
+			arg = arg<<8 | uint64(c)
+		}
+		return major, info, arg, nil
+	case info == 31:
+		return major, info, 0, nil
+	default:
+		return 0, 0, 0, d.errorf("reserved additional information %d", info)
+	}
+}
+
+// length checks the definite length of the strings, arrays and maps. Each
+// array or map element takes at least one byte, so the length can not be
+// larger than the remaining data.
+func (d *decoder) length(n uint64) (int, error) {
+	if n > uint64(len(d.data)-d.off) {
+		return 0, d.errorf("length %d exceeds the data", n)
+	}
+	return int(n), nil
+}
+
+func (d *decoder) value(depth int) (interface{}, error) {
+	if depth > maxDepth {
+		return nil, d.errorf("nesting is deeper than %d", maxDepth)
+	}
+
+	major, info, arg, err := d.head()
+	if err != nil {
+		return nil, err
+	}
+
+	indefinite := info == 31
+	if indefinite && (major == majorUint || major == majorNegInt || major == majorTag) {
+		return nil, d.errorf("indefinite length of major type %d", major)
+	}
+
+	switch major {
+	case majorUint:
+		if arg > math.MaxInt64 {
+			return arg, nil
+		}
+		return int64(arg), nil
+	case majorNegInt:
+		if arg > math.MaxInt64 {
+			return nil, d.errorf("negative integer overflows int64")
+		}
+		return -1 - int64(arg), nil
+	case majorBytes, majorText:
+		b, err := d.str(major, arg, indefinite)
+		if err != nil {
+			return nil, err
+		}
+		if major == majorText {
+			return string(b), nil
+		}
+		return b, nil
+	case majorArray:
+		return d.array(arg, indefinite, depth)
+	case majorMap:
+		return d.mapping(arg, indefinite, depth)
+	case majorTag:
+		item, err := d.value(depth + 1)
+		if err != nil || arg != tagEpochTime {
+			return item, err
+		}
+		return d.epochTime(item)
+	default:
+		return d.simple(info, arg)
+	}
+}
+
+// str reads the definite string, or the chunks of the indefinite string.
+func (d *decoder) str(major byte, arg uint64, indefinite bool) ([]byte, error) {
+	if !indefinite {
+		n, err := d.length(arg)
+		if err != nil {
+			return nil, err
+		}
+		b, _ := d.next(uint64(n))
+		return append([]byte(nil), b...), nil
+	}
+
+	b := make([]byte, 0)
+	for {
+		if d.isBreak() {
+			return b, nil
+		}
+
+		m, info, n, err := d.head()
+		if err != nil {
+			return nil, err
+		}
+		if m != major || info == 31 {
+			return nil, d.errorf("invalid chunk of indefinite string")
+		}
+
+		chunk, err := d.next(n)
+		if err != nil {
+			return nil, err
+		}
+		b = append(b, chunk...)
+	}
+}
+
+func (d *decoder) array(arg uint64, indefinite bool, depth int) (interface{}, error) {
+	var items []interface{}
+	if indefinite {
+		items = make([]interface{}, 0)
+	} else {
+		n, err := d.length(arg)
+		if err != nil {
+			return nil, err
+		}
+		items = make([]interface{}, 0, n)
+	}
+
+	for i := 0; indefinite || i < cap(items); i++ {
+		if indefinite && d.isBreak() {
+			break
+		}
+
+		item, err := d.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, nil
+}
+
+func (d *decoder) mapping(arg uint64, indefinite bool, depth int) (interface{}, error) {
+	n := 0
+	if !indefinite {
+		var err error
+		if n, err = d.length(arg); err != nil {
+			return nil, err
+		}
+	}
+
+	m := make(map[string]interface{}, n)
+	for i := 0; indefinite || i < n; i++ {
+		if indefinite && d.isBreak() {
+			break
+		}
+
+		k, err := d.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+
+		v, err := d.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+
+		m[mapKey(k)] = v
+	}
+
+	return m, nil
+}
+
+// epochTime converts the content of the epoch date/time tag to time.Time.
+func (d *decoder) epochTime(item interface{}) (interface{}, error) {
+	switch sec := item.(type) {
+	case int64:
+		return time.Unix(sec, 0).UTC(), nil
+	case float64:
+		if math.IsNaN(sec) || math.IsInf(sec, 0) || math.Abs(sec) > math.MaxInt64 {
+			return nil, d.errorf("invalid epoch time %v", sec)
+		}
+		whole, frac := math.Modf(sec)
+		return time.Unix(int64(whole), int64(frac*1e9)).UTC(), nil
+	default:
+		return nil, d.errorf("invalid epoch time %v", item)
+	}
+}
+
+// isBreak consumes the break stop code if it is next.
+func (d *decoder) isBreak() bool {
+	if d.off < len(d.data) && d.data[d.off] == headBreak {
+		d.off++
+		return true
+	}
+	return false
+}
+
+// simple decodes the simple values and the floats of major type 7.
+func (d *decoder) simple(info byte, arg uint64) (interface{}, error) {
+	switch info {
+	case 20:
+		return false, nil
+	case 21:
+		return true, nil
+	case 22, 23:
+		return nil, nil // null and undefined.
+	case 25:
+		return float16(uint16(arg)), nil
+	case 26:
+		return float64(math.Float32frombits(uint32(arg))), nil
+	case 27:
+		return math.Float64frombits(arg), nil
+	case 31:
+		return nil, d.errorf("unexpected break")
+	default:
+		return nil, d.errorf("unsupported simple value %d", arg)
+	}
+}
+
+// float16 converts the half-precision bits to the float.
+func float16(h uint16) float64 {
+	exp := int(h>>10) & 0x1f
+	mant := float64(h & 0x3ff)
+
+	var f float64
+	switch exp {
+	case 0:
+		f = math.Ldexp(mant, -24)
+	case 0x1f:
+		if mant != 0 {
+			return math.NaN()
+		}
+		f = math.Inf(1)
+	default:
+		f = math.Ldexp(mant+1024, exp-25)
+	}
+
+	if h&0x8000 != 0 {
+		return -f
+	}
+	return f
+}
+
+// mapKey formats the map key as text.
+func mapKey(k interface{}) string {
+	switch k := k.(type) {
+	case string:
+		return k
+	case []byte:
+		return string(k)
+	case int64:
+		return strconv.FormatInt(k, 10)
+	case uint64:
+		return strconv.FormatUint(k, 10)
+	default:
+		return fmt.Sprint(k)
+	}
+}
+
+// assign assigns the generic data item to v the way encoding/json does.
+func assign(item interface{}, v reflect.Value) error {
+	// null sets the pointers, maps, slices and interfaces to nil,
+	// and leaves the other values as is.
+	if item == nil {
+		switch v.Kind() {
+		case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface:
+			v.Set(reflect.Zero(v.Type()))
+		}
+		return nil
+	}
+
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return assign(item, v.Elem())
+	}
+
+	if t, ok := item.(time.Time); ok && v.Type() == timeType {
+		v.Set(reflect.ValueOf(t))
+		return nil
+	}
+
+	if s, ok := item.(string); ok && v.CanAddr() && v.Addr().Type().Implements(textUnmarshalerType) {
+		if err := v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s)); err != nil {
+			return fmt.Errorf("%w: %v", ErrTypeMismatch, err)
+		}
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.NumMethod() != 0 {
+			return mismatch(item, v)
+		}
+		v.Set(reflect.ValueOf(item))
+	case reflect.Bool:
+		b, ok := item.(bool)
+		if !ok {
+			return mismatch(item, v)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, ok := item.(int64)
+		if !ok || v.OverflowInt(n) {
+			return mismatch(item, v)
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		var n uint64
+		switch i := item.(type) {
+		case uint64:
+			n = i
+		case int64:
+			if i < 0 {
+				return mismatch(item, v)
+			}
+			n = uint64(i)
+		default:
+			return mismatch(item, v)
+		}
+		if v.OverflowUint(n) {
+			return mismatch(item, v)
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		switch i := item.(type) {
+		case float64:
+			f = i
+		case int64:
+			f = float64(i)
+		case uint64:
+			f = float64(i)
+		default:
+			return mismatch(item, v)
+		}
+		if v.OverflowFloat(f) {
+			return mismatch(item, v)
+		}
+		v.SetFloat(f)
+	case reflect.String:
+		switch s := item.(type) {
+		case string:
+			v.SetString(s)
+		case []byte:
+			v.SetString(string(s))
+		default:
+			return mismatch(item, v)
+		}
+	case reflect.Slice:
+		return assignSlice(item, v)
+	case reflect.Array:
+		return assignArray(item, v)
+	case reflect.Map:
+		return assignMap(item, v)
+	case reflect.Struct:
+		return assignStruct(item, v)
+	default:
+		return mismatch(item, v)
+	}
+
+	return nil
+}
+
+// assignSlice assigns the byte string to the byte slice, or the array to the slice.
+func assignSlice(item interface{}, v reflect.Value) error {
+	if b, ok := item.([]byte); ok && v.Type().Elem().Kind() == reflect.Uint8 {
+		v.SetBytes(append([]byte(nil), b...))
+		return nil
+	}
+
+	items, ok := item.([]interface{})
+	if !ok {
+		return mismatch(item, v)
+	}
+
+	slice := reflect.MakeSlice(v.Type(), len(items), len(items))
+	for i, it := range items {
+		if err := assign(it, slice.Index(i)); err != nil {
+			return err
+		}
+	}
+
+	v.Set(slice)
+	return nil
+}
+
+// assignArray assigns the byte string or the array to the array, the extra
+// elements are dropped and the missing elements are zeroed.
+func assignArray(item interface{}, v reflect.Value) error {
+	if b, ok := item.([]byte); ok && v.Type().Elem().Kind() == reflect.Uint8 {
+		for i := 0; i < v.Len(); i++ {
+			var c byte
+			if i < len(b) {
+				c = b[i]
+			}
+			v.Index(i).SetUint(uint64(c))
+		}
+		return nil
+	}
+
+	items, ok := item.([]interface{})
+	if !ok {
+		return mismatch(item, v)
+	}
+
+	for i := 0; i < v.Len(); i++ {
+		if i >= len(items) {
+			v.Index(i).Set(reflect.Zero(v.Type().Elem()))
+			continue
+		}
+
+		if err := assign(items[i], v.Index(i)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// assignMap assigns the map to the map keyed by the strings or the integers.
+func assignMap(item interface{}, v reflect.Value) error {
+	m, ok := item.(map[string]interface{})
+	if !ok {
+		return mismatch(item, v)
+	}
+
+	if v.IsNil() {
+		v.Set(reflect.MakeMapWithSize(v.Type(), len(m)))
+	}
+
+	kt, et := v.Type().Key(), v.Type().Elem()
+	for k, it := range m {
+		key := reflect.New(kt).Elem()
+		switch kt.Kind() {
+		case reflect.String:
+			key.SetString(k)
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			n, err := strconv.ParseInt(k, 10, 64)
+			if err != nil || key.OverflowInt(n) {
+				return mismatch(k, key)
+			}
+			key.SetInt(n)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			n, err := strconv.ParseUint(k, 10, 64)
+			if err != nil || key.OverflowUint(n) {
+				return mismatch(k, key)
+			}
+			key.SetUint(n)
+		default:
+			return mismatch(item, v)
+		}
+
+		elem := reflect.New(et).Elem()
+		if err := assign(it, elem); err != nil {
+			return err
+		}
+		v.SetMapIndex(key, elem)
+	}
+
+	return nil
+}
+
+// assignStruct assigns the map to the struct fields by their json tag names,
+// the names are matched case-insensitively if there is no exact match, and the
+// unknown keys are ignored.
+func assignStruct(item interface{}, v reflect.Value) error {
+	m, ok := item.(map[string]interface{})
+	if !ok {
+		return mismatch(item, v)
+	}
+
+	fields := cachedFields(v.Type())
+	for k, it := range m {
+		f, ok := findField(fields, k)
+		if !ok {
+			continue
+		}
+
+		fv, ok := settableField(v, f.index)
+		if !ok {
+			continue
+		}
+
+		if err := assign(it, fv); err != nil {
+			return fmt.Errorf("%w (field %s)", err, f.name)
+		}
+	}
+
+	return nil
+}
+
+// findField finds the field of the name, the exact match takes precedence.
+func findField(fields []field, name string) (field, bool) {
+	for _, f := range fields {
+		if f.name == name {
+			return f, true
+		}
+	}
+
+	for _, f := range fields {
+		if strings.EqualFold(f.name, name) {
+			return f, true
+		}
+	}
+
+	return field{}, false
+}
+
+// settableField returns the nested field, the nil embedded pointers are
+// allocated. It is false if the embedded pointer is of an unexported type.
+func settableField(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				if !v.CanSet() {
+					return reflect.Value{}, false
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// mismatch creates the ErrTypeMismatch error of the item that can not be assigned to v.
+func mismatch(item interface{}, v reflect.Value) error {
+	return fmt.Errorf("%w: %T into %s", ErrTypeMismatch, item, v.Type())
+}
@@ -0,0 +1,432 @@
+// Package cbor implements the Concise Binary Object Representation (CBOR)
+// as defined in RFC 8949.
+// see: https://www.rfc-editor.org/rfc/rfc8949.html.
+//
+// The Go values are mapped like encoding/json does: the structs are encoded
+// as maps keyed by the json tag names, honoring "-" and omitempty, so the same
+// types can be served as JSON or CBOR. The encoding is deterministic, the map
+// keys are sorted and the integers, lengths and floats use the shortest form.
+package cbor
+
+import (
+	"bytes"
+	"encoding"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Major types of the data item head.
+const (
+	majorUint   = 0
+	majorNegInt = 1
+	majorBytes  = 2
+	majorText   = 3
+	majorArray  = 4
+	majorMap    = 5
+	majorTag    = 6
+)
+
+// Simple values and the float heads of major type 7.
+const (
+	simpleFalse = 0xf4
+	simpleTrue  = 0xf5
+	simpleNull  = 0xf6
+	headFloat16 = 0xf9
+	headFloat32 = 0xfa
+	headFloat64 = 0xfb
+	headBreak   = 0xff
+)
+
+// Tags of the date/time.
+const (
+	// tagDateTime is the tag of the RFC 3339 date/time text string.
+	tagDateTime = 0
+
+	// tagEpochTime is the tag of the seconds since the epoch, an integer or a float.
+	tagEpochTime = 1
+)
+
+var (
+	// ErrUnsupportedType is an error when the value can not be encoded.
+	ErrUnsupportedType = errors.New("cbor: unsupported type")
+
+	// ErrMalformed is an error when the data is not a well-formed CBOR data item.
+	ErrMalformed = errors.New("cbor: malformed data")
+
+	// ErrTypeMismatch is an error when the data item can not be assigned to the value.
+	ErrTypeMismatch = errors.New("cbor: mismatched type")
+)
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// Marshal returns the CBOR encoding of v.
+func Marshal(v interface{}) ([]byte, error) {
+	var e encoder
+	if err := e.encode(reflect.ValueOf(v)); err != nil {
+		return nil, err
+	}
+	return e.buf.Bytes(), nil
+}
+
+// encoder encodes the values into buf.
+type encoder struct {
+	buf bytes.Buffer
+}
+
+// head writes the initial byte and the argument in the shortest form.
+func (e *encoder) head(major byte, n uint64) {
+	m := major << 5
+	switch {
+	case n < 24:
+		e.buf.WriteByte(m | byte(n))
+	case n <= math.MaxUint8:
+		e.buf.Write([]byte{m | 24, byte(n)})
+	case n <= math.MaxUint16:
+		e.buf.Write([]byte{m | 25, byte(n >> 8), byte(n)})
+	case n <= math.MaxUint32:
+		e.buf.Write([]byte{m | 26, byte(n >> 24), byte(n >> 16), byte(n >> 8), byte(n)})
+	default:
+		e.buf.Write([]byte{
+			m | 27,
+			byte(n >> 56), byte(n >> 48), byte(n >> 40), byte(n >> 32),
+			byte(n >> 24), byte(n >> 16), byte(n >> 8), byte(n),
+		})
+	}
+}
+
+func (e *encoder) encode(v reflect.Value) error {
+	if !v.IsValid() {
+		e.buf.WriteByte(simpleNull)
+		return nil
+	}
+
+	if v.Type() == timeType {
+		e.head(majorTag, tagDateTime)
+		e.text(v.Interface().(time.Time).Format(time.RFC3339Nano))
+		return nil
+	}
+
+	if v.Kind() != reflect.Ptr && v.Type().Implements(textMarshalerType) {
+		b, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			return fmt.Errorf("cbor: marshaling text of %s: %w", v.Type(), err)
+		}
+		e.text(string(b))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			e.buf.WriteByte(simpleTrue)
+		} else {
+			e.buf.WriteByte(simpleFalse)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if n := v.Int(); n >= 0 {
+			e.head(majorUint, uint64(n))
+		} else {
+			e.head(majorNegInt, uint64(-1-n))
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		e.head(majorUint, v.Uint())
+	case reflect.Float32, reflect.Float64:
+		e.float(v.Float())
+	case reflect.String:
+		e.text(v.String())
+	case reflect.Slice:
+		if v.IsNil() {
+			e.buf.WriteByte(simpleNull)
+			return nil
+		}
+		return e.array(v)
+	case reflect.Array:
+		return e.array(v)
+	case reflect.Map:
+		if v.IsNil() {
+			e.buf.WriteByte(simpleNull)
+			return nil
+		}
+		return e.mapping(v)
+	case reflect.Struct:
+		return e.structure(v)
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			e.buf.WriteByte(simpleNull)
+			return nil
+		}
+		return e.encode(v.Elem())
+	default:
+		return fmt.Errorf("%w: %s", ErrUnsupportedType, v.Type())
+	}
+
+	return nil
+}
+
+// text writes the text string.
+func (e *encoder) text(s string) {
+	e.head(majorText, uint64(len(s)))
+	e.buf.WriteString(s)
+}
+
+// array writes the byte string of the byte slices and arrays, otherwise the array.
+func (e *encoder) array(v reflect.Value) error {
+	if v.Type().Elem().Kind() == reflect.Uint8 {
+		e.head(majorBytes, uint64(v.Len()))
+		for i := 0; i < v.Len(); i++ {
+			e.buf.WriteByte(byte(v.Index(i).Uint()))
+		}
+		return nil
+	}
+
+	e.head(majorArray, uint64(v.Len()))
+	for i := 0; i < v.Len(); i++ {
+		if err := e.encode(v.Index(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// entry is an encoded map key and its value.
+type entry struct {
+	key   []byte
+	value reflect.Value
+}
+
+// writeEntries writes the map of the entries sorted by the encoded keys,
+// as the core deterministic encoding requires.
+func (e *encoder) writeEntries(entries []entry) error {
+	sort.Slice(entries, func(i, j int) bool {
+		return bytes.Compare(entries[i].key, entries[j].key) < 0
+	})
+
+	e.head(majorMap, uint64(len(entries)))
+	for _, en := range entries {
+		e.buf.Write(en.key)
+		if err := e.encode(en.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *encoder) mapping(v reflect.Value) error {
+	entries := make([]entry, 0, v.Len())
+
+	iter := v.MapRange()
+	for iter.Next() {
+		var k encoder
+		if err := k.encode(iter.Key()); err != nil {
+			return err
+		}
+		entries = append(entries, entry{key: k.buf.Bytes(), value: iter.Value()})
+	}
+
+	return e.writeEntries(entries)
+}
+
+func (e *encoder) structure(v reflect.Value) error {
+	fields := cachedFields(v.Type())
+	entries := make([]entry, 0, len(fields))
+
+	for _, f := range fields {
+		fv, ok := fieldByIndex(v, f.index)
+		if !ok || (f.omitEmpty && isEmpty(fv)) {
+			continue
+		}
+
+		var k encoder
+		k.text(f.name)
+		entries = append(entries, entry{key: k.buf.Bytes(), value: fv})
+	}
+
+	return e.writeEntries(entries)
+}
+
+// float writes the float in the shortest form that keeps its value.
+func (e *encoder) float(f float64) {
+	if f32 := float32(f); float64(f32) == f || math.IsNaN(f) {
+		if h, ok := float16Bits(f32); ok {
+			e.buf.Write([]byte{headFloat16, byte(h >> 8), byte(h)})
+			return
+		}
+
+		b := math.Float32bits(f32)
+		e.buf.Write([]byte{headFloat32, byte(b >> 24), byte(b >> 16), byte(b >> 8), byte(b)})
+		return
+	}
+
+	b := math.Float64bits(f)
+	e.buf.Write([]byte{
+		headFloat64,
+		byte(b >> 56), byte(b >> 48), byte(b >> 40), byte(b >> 32),
+		byte(b >> 24), byte(b >> 16), byte(b >> 8), byte(b),
+	})
+}
+
+// float16Bits returns the half-precision bits of f if f is exactly representable.
+func float16Bits(f float32) (uint16, bool) {
+	bits := math.Float32bits(f)
+	sign := uint16(bits>>16) & 0x8000
+	exp := int(bits>>23) & 0xff
+	mant := bits & 0x7fffff
+
+	switch {
+	case exp == 0xff && mant != 0:
+		return 0x7e00, true // the canonical NaN.
+	case exp == 0xff:
+		return sign | 0x7c00, true
+	case exp == 0 && mant == 0:
+		return sign, true
+	case exp == 0:
+		return 0, false // the float32 subnormals are too small.
+	}
+
+	// the normal half-precision floats.
+	if e := exp - 127 + 15; e >= 1 && e <= 30 {
+		if mant&0x1fff != 0 {
+			return 0, false
+		}
+		return sign | uint16(e)<<10 | uint16(mant>>13), true
+	}
+
+	// the subnormal half-precision floats, m * 2^-24 where m < 1024.
+	if shift := uint(126 - exp); exp-127+15 < 1 && shift < 32 {
+		m := mant | 0x800000
+		if m&(1<<shift-1) != 0 {
+			return 0, false
+		}
+		if h := m >> shift; h < 0x400 {
+			return sign | uint16(h), true
+		}
+	}
+
+	return 0, false
+}
+
+// field is an encoded struct field.
+type field struct {
+	name      string
+	index     []int
+	omitEmpty bool
+}
+
+var fieldCache sync.Map // map[reflect.Type][]field
+
+// cachedFields returns the encoded fields of the struct type.
+func cachedFields(t reflect.Type) []field {
+	if f, ok := fieldCache.Load(t); ok {
+		return f.([]field)
+	}
+
+	f, _ := fieldCache.LoadOrStore(t, typeFields(t, nil))
+	return f.([]field)
+}
+
+// typeFields lists the exported fields by their json tag names, the fields of
+// the untagged embedded structs are promoted unless the outer struct has the
+// same name.
+func typeFields(t reflect.Type, index []int) []field {
+	var (
+		fields   []field
+		promoted []field
+	)
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name, opts := tag, ""
+		if j := strings.IndexByte(tag, ','); j >= 0 {
+			name, opts = tag[:j], tag[j+1:]
+		}
+
+		idx := append(append([]int(nil), index...), i)
+
+		ft := sf.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+
+		if sf.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			promoted = append(promoted, typeFields(ft, idx)...)
+			continue
+		}
+
+		if sf.PkgPath != "" {
+			continue // unexported.
+		}
+
+		if name == "" {
+			name = sf.Name
+		}
+
+		fields = append(fields, field{
+			name:      name,
+			index:     idx,
+			omitEmpty: strings.Contains(","+opts+",", ",omitempty,"),
+		})
+	}
+
+	seen := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		seen[f.name] = true
+	}
+
+	for _, f := range promoted {
+		if !seen[f.name] {
+			fields = append(fields, f)
+			seen[f.name] = true
+		}
+	}
+
+	return fields
+}
+
+// fieldByIndex returns the nested field, it is false if an embedded pointer is nil.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// isEmpty reports whether the value is empty as omitempty of encoding/json defines.
+func isEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
@@ -0,0 +1,255 @@
+// Package compress provides a response compression middleware for the mux.Router.
+package compress
+
+import (
+	"compress/flate"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/josestg/justforfun/pkg/mux"
+)
+
+// Supported encodings.
+const (
+	Gzip    = "gzip"
+	Deflate = "deflate"
+)
+
+// DefaultMinSize is the default minimum body size to be compressed.
+// The smaller body is not worth the compression overhead.
+const DefaultMinSize = 1024
+
+// DefaultSkipTypes are the content types that are already compressed.
+// The type ends with "/" matches any subtype.
+var DefaultSkipTypes = []string{
+	"image/",
+	"video/",
+	"audio/",
+	"font/woff",
+	"font/woff2",
+	"application/zip",
+	"application/gzip",
+	"application/x-gzip",
+	"application/x-bzip2",
+	"application/x-xz",
+	"application/x-7z-compressed",
+	"application/x-rar-compressed",
+	"application/wasm",
+}
+
+// Option is an option type that can be used to customize the compression.
+type Option func(c *compressor)
+
+// WithLevel sets the compression level, see compress/flate.
+// It panics if the level is invalid.
+func WithLevel(level int) Option {
+	if level < flate.HuffmanOnly || level > flate.BestCompression {
+		panic(fmt.Sprintf("compress: invalid level %d", level))
+	}
+
+	return func(c *compressor) {
+		c.level = level
+	}
+}
+
+// WithMinSize sets the minimum body size to be compressed.
+func WithMinSize(n int) Option {
+	return func(c *compressor) {
+		c.minSize = n
+	}
+}
+
+// WithSkipTypes adds the content types that must not be compressed.
+func WithSkipTypes(types ...string) Option {
+	return func(c *compressor) {
+		c.skipTypes = append(c.skipTypes, types...)
+	}
+}
+
+// compressor holds the compression settings and the encoder pools.
+type compressor struct {
+	level     int
+	minSize   int
+	skipTypes []string
+
+	gzipPool    sync.Pool
+	deflatePool sync.Pool
+}
+
+// Middleware creates a middleware that compresses the response body with the
+// encoding negotiated from the Accept-Encoding header.
+//
+// The response is buffered until the body reaches the minimum size, so the
+// small body is sent as is. The body is not compressed if the handler has set
+// the Content-Encoding, if the content type is already compressed, or if the
+// status has no body. Flushing the writer forces the decision and flushes the
+// encoder, so the streaming responses are compressed as they go.
+//
+// The compressed representation differs from the plain one, so its strong ETag
+// is suffixed by the encoding, for example "abc" becomes "abc-gzip". The suffix
+// is stripped from the If-None-Match and If-Match of the requests that accept
+// the same encoding, so the handler compares them against its own ETag.
+//
+// The encoding and the body sizes are recorded into the mux.State.
+func Middleware(options ...Option) mux.Middleware {
+	c := compressor{
+		level:     flate.DefaultCompression,
+		minSize:   DefaultMinSize,
+		skipTypes: append([]string(nil), DefaultSkipTypes...),
+	}
+
+	for _, fn := range options {
+		fn(&c)
+	}
+
+	return func(handler mux.Handler) mux.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) error {
+			state, err := mux.GetState(r.Context())
+			if err != nil {
+				return mux.NewShutdownError(err.Error())
+			}
+
+			w.Header().Add("Vary", "Accept-Encoding")
+
+			encoding := Negotiate(r.Header.Get("Accept-Encoding"))
+			if encoding == "" || r.Method == http.MethodHead {
+				return handler.ServeHTTP(w, r)
+			}
+
+			// the client validates the compressed representation with its
+			// suffixed ETag, but the handler only knows the plain one.
+			revalidated := stripETags(r.Header, "If-None-Match", encoding)
+			stripETags(r.Header, "If-Match", encoding)
+
+			cw := &compressWriter{
+				ResponseWriter: w,
+				compressor:     &c,
+				state:          state,
+				encoding:       encoding,
+				revalidated:    revalidated,
+			}
+
+			err = handler.ServeHTTP(wrapWriter(cw), r)
+			if closeErr := cw.Close(); closeErr != nil && err == nil {
+				err = closeErr
+			}
+
+			return err
+		}
+
+		return mux.HandlerFunc(fn)
+	}
+}
+
+// Negotiate returns the preferred supported encoding of the Accept-Encoding
+// header value, or empty string if none is acceptable. Gzip is preferred over
+// deflate for the same quality.
+func Negotiate(acceptEncoding string) string {
+	type candidate struct {
+		encoding string
+		q        float64
+	}
+
+	quality := map[string]float64{}
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		fields := strings.Split(part, ";")
+		name := strings.ToLower(strings.TrimSpace(fields[0]))
+		if name == "" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+
+		quality[name] = q
+	}
+
+	candidates := make([]candidate, 0, 2)
+	for _, encoding := range []string{Gzip, Deflate} {
+		q, exist := quality[encoding]
+		if !exist {
+			q, exist = quality["*"]
+		}
+
+		if exist && q > 0 {
+			candidates = append(candidates, candidate{encoding: encoding, q: q})
+		}
+	}
+
+	if len(candidates) == 0 {
+		return ""
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].q > candidates[j].q
+	})
+
+	return candidates[0].encoding
+}
+
+// encodeETag suffixes the strong etag by the encoding.
+// The weak etag is left as is, it is already shared by the encodings.
+func encodeETag(etag, encoding string) string {
+	if strings.HasPrefix(etag, "W/") || len(etag) < 2 || !strings.HasSuffix(etag, `"`) {
+		return etag
+	}
+	return etag[:len(etag)-1] + "-" + encoding + `"`
+}
+
+// stripETags strips the suffix of the encoding from the entity tags of the
+// header, and reports whether any has been stripped.
+func stripETags(h http.Header, name, encoding string) bool {
+	list := h.Get(name)
+	if list == "" {
+		return false
+	}
+
+	suffix := "-" + encoding + `"`
+	stripped := false
+
+	tags := strings.Split(list, ",")
+	for i, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if !strings.HasPrefix(tag, "W/") && strings.HasSuffix(tag, suffix) {
+			tag = tag[:len(tag)-len(suffix)] + `"`
+			stripped = true
+		}
+		tags[i] = tag
+	}
+
+	if stripped {
+		h.Set(name, strings.Join(tags, ", "))
+	}
+
+	return stripped
+}
+
+// skip reports whether the content type must not be compressed.
+func (c *compressor) skip(contentType string) bool {
+	mediaType := strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	for _, t := range c.skipTypes {
+		if strings.HasSuffix(t, "/") && strings.HasPrefix(mediaType, t) {
+			// the SVG is a text format.
+			if mediaType == "image/svg+xml" {
+				return false
+			}
+			return true
+		}
+
+		if mediaType == t {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,344 @@
+package compress
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/josestg/justforfun/pkg/mux"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := map[string]string{
+		"":                         "",
+		"identity":                 "",
+		"gzip":                     Gzip,
+		"deflate":                  Deflate,
+		"deflate, gzip":            Gzip,
+		"gzip;q=0.5, deflate":      Deflate,
+		"gzip;q=0, deflate;q=0":    "",
+		"br, *":                    Gzip,
+		"*;q=0.1, gzip;q=0":        Deflate,
+		" GZIP ; q=1.0 , deflate ": Gzip,
+	}
+
+	for header, expected := range tests {
+		if got := Negotiate(header); got != expected {
+			t.Errorf("%q: expecting %q but got %q", header, expected, got)
+		}
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	large := strings.Repeat(`{"name":"justforfun"}`, 100)
+
+	var state *mux.State
+	observer := func(handler mux.Handler) mux.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) error {
+			state, _ = mux.GetState(r.Context())
+			return handler.ServeHTTP(w, r)
+		}
+		return mux.HandlerFunc(fn)
+	}
+
+	router := mux.NewRouter(nil, observer, Middleware())
+	router.Handle("GET /large", mux.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Length", "2100")
+		w.WriteHeader(http.StatusCreated)
+		_, err := io.WriteString(w, large)
+		return err
+	}))
+	router.Handle("GET /small", mux.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		_, err := io.WriteString(w, "small")
+		return err
+	}))
+	router.Handle("GET /image", mux.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		w.Header().Set("Content-Type", "image/png")
+		_, err := io.WriteString(w, large)
+		return err
+	}))
+	router.Handle("GET /encoded", mux.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		w.Header().Set("Content-Encoding", "br")
+		_, err := io.WriteString(w, large)
+		return err
+	}))
+	router.Handle("GET /failed", mux.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		_, _ = io.WriteString(w, "partial")
+		return errors.New("boom")
+	}))
+
+	do := func(path, acceptEncoding string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("gzip", func(t *testing.T) {
+		rec := do("/large", "gzip")
+
+		if rec.Code != http.StatusCreated || rec.Header().Get("Content-Encoding") != Gzip {
+			t.Fatalf("expecting gzip response but got %d %v", rec.Code, rec.Header())
+		}
+
+		if rec.Header().Get("Content-Length") != "" || rec.Header().Get("Vary") != "Accept-Encoding" {
+			t.Fatalf("expecting content length removed and vary set but got %v", rec.Header())
+		}
+
+		zr, err := gzip.NewReader(rec.Body)
+		if err != nil {
+			t.Fatalf("expecting valid gzip but got %v", err)
+		}
+
+		body, _ := io.ReadAll(zr)
+		if string(body) != large {
+			t.Fatalf("expecting decompressed body is the original")
+		}
+
+		if state.ContentEncoding != Gzip || state.UncompressedBytes != int64(len(large)) || state.CompressedBytes != state.BytesWritten {
+			t.Fatalf("expecting sizes are recorded but got %+v", state)
+		}
+
+		if state.CompressedBytes >= state.UncompressedBytes {
+			t.Fatalf("expecting compressed body is smaller but got %+v", state)
+		}
+	})
+
+	t.Run("deflate", func(t *testing.T) {
+		rec := do("/large", "deflate")
+		if rec.Header().Get("Content-Encoding") != Deflate {
+			t.Fatalf("expecting deflate response but got %v", rec.Header())
+		}
+
+		body, _ := io.ReadAll(flate.NewReader(rec.Body))
+		if string(body) != large {
+			t.Fatalf("expecting decompressed body is the original")
+		}
+	})
+
+	t.Run("not compressed", func(t *testing.T) {
+		tests := map[string]string{
+			"/small":   "gzip",
+			"/image":   "gzip",
+			"/encoded": "gzip",
+			"/large":   "identity",
+		}
+
+		for path, acceptEncoding := range tests {
+			rec := do(path, acceptEncoding)
+			if rec.Header().Get("Content-Encoding") == Gzip {
+				t.Fatalf("%s: expecting response is not compressed", path)
+			}
+
+			if state.ContentEncoding != "" || state.CompressedBytes != 0 {
+				t.Fatalf("%s: expecting no compression recorded but got %+v", path, state)
+			}
+		}
+
+		if rec := do("/small", "gzip"); rec.Body.String() != "small" || rec.Header().Get("Content-Type") == "" {
+			t.Fatalf("expecting small body as is but got %q %v", rec.Body.String(), rec.Header())
+		}
+	})
+
+	t.Run("error replaces buffered body", func(t *testing.T) {
+		rec := do("/failed", "gzip")
+		if rec.Code != http.StatusInternalServerError || strings.Contains(rec.Body.String(), "partial") {
+			t.Fatalf("expecting error response only but got %d %q", rec.Code, rec.Body.String())
+		}
+	})
+}
+
+func TestMiddlewareETag(t *testing.T) {
+	large := strings.Repeat(`{"name":"justforfun"}`, 100)
+
+	// the handler only knows the ETag of the plain representation.
+	router := mux.NewRouter(nil, Middleware())
+	router.Handle("GET /items/{etag}", mux.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		etag := `"` + mux.Param(r.Context(), "etag") + `"`
+		if mux.Param(r.Context(), "etag") == "weak" {
+			etag = `W/"weak"`
+		}
+
+		w.Header().Set("ETag", etag)
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return nil
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, err := io.WriteString(w, large)
+		return err
+	}))
+
+	tests := map[string]struct {
+		path           string
+		acceptEncoding string
+		ifNoneMatch    string
+		status         int
+		etag           string
+	}{
+		"gzip":                {"/items/abc", "gzip", "", http.StatusOK, `"abc-gzip"`},
+		"deflate":             {"/items/abc", "deflate", "", http.StatusOK, `"abc-deflate"`},
+		"identity":            {"/items/abc", "identity", "", http.StatusOK, `"abc"`},
+		"weak":                {"/items/weak", "gzip", "", http.StatusOK, `W/"weak"`},
+		"revalidated":         {"/items/abc", "gzip", `"abc-gzip"`, http.StatusNotModified, `"abc-gzip"`},
+		"plain revalidated":   {"/items/abc", "gzip", `"abc"`, http.StatusNotModified, `"abc"`},
+		"other encoding":      {"/items/abc", "deflate", `"abc-gzip"`, http.StatusOK, `"abc-deflate"`},
+		"encoding not reused": {"/items/abc", "identity", `"abc-gzip"`, http.StatusOK, `"abc"`},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			if tt.ifNoneMatch != "" {
+				req.Header.Set("If-None-Match", tt.ifNoneMatch)
+			}
+
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("expecting status %d but got %d", tt.status, rec.Code)
+			}
+
+			if got := rec.Header().Get("ETag"); got != tt.etag {
+				t.Fatalf("expecting ETag %s but got %s", tt.etag, got)
+			}
+		})
+	}
+}
+
+func TestStripETags(t *testing.T) {
+	h := http.Header{}
+	h.Set("If-None-Match", `"a-gzip", W/"b-gzip" ,"c-deflate"`)
+
+	if !stripETags(h, "If-None-Match", Gzip) {
+		t.Fatalf("expecting the tags are stripped")
+	}
+
+	if got, want := h.Get("If-None-Match"), `"a", W/"b-gzip", "c-deflate"`; got != want {
+		t.Fatalf("expecting %s but got %s", want, got)
+	}
+
+	if stripETags(h, "If-Match", Gzip) || h.Get("If-Match") != "" {
+		t.Fatalf("expecting the missing header is left as is")
+	}
+}
+
+func TestMiddlewareStreaming(t *testing.T) {
+	chunks := make(chan string)
+	flushed := make(chan struct{})
+
+	handler := Middleware()(mux.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for chunk := range chunks {
+			_, _ = io.WriteString(w, chunk)
+			w.(http.Flusher).Flush()
+			flushed <- struct{}{}
+		}
+		return nil
+	}))
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/events", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	req = req.WithContext(newStateContext(req))
+
+	done := make(chan error, 1)
+	go func() { done <- handler.ServeHTTP(rec, req) }()
+
+	chunks <- "data: first\n\n"
+	<-flushed
+
+	// the flushed gzip stream can be decoded before the response completes.
+	zr, err := gzip.NewReader(bytes.NewReader(rec.Body.Bytes()))
+	if err != nil {
+		t.Fatalf("expecting gzip header is flushed but got %v", err)
+	}
+
+	buf := make([]byte, 64)
+	n, _ := zr.Read(buf)
+	if string(buf[:n]) != "data: first\n\n" {
+		t.Fatalf("expecting first chunk is flushed but got %q", buf[:n])
+	}
+
+	if !rec.Flushed || rec.Header().Get("Content-Encoding") != Gzip {
+		t.Fatalf("expecting flushed gzip response but got %v", rec.Header())
+	}
+
+	close(chunks)
+	if err := <-done; err != nil {
+		t.Fatalf("expecting error nil but got %v", err)
+	}
+}
+
+// plainWriter hides the http.Flusher and http.Hijacker of the recorder.
+type plainWriter struct {
+	http.ResponseWriter
+}
+
+// hijackWriter is a recorder that can be hijacked.
+type hijackWriter struct {
+	*httptest.ResponseRecorder
+	hijacked bool
+}
+
+func (h *hijackWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h.hijacked = true
+	return nil, nil, nil
+}
+
+func TestWrapWriter(t *testing.T) {
+	newWriter := func(w http.ResponseWriter) http.ResponseWriter {
+		return wrapWriter(&compressWriter{ResponseWriter: w, compressor: &compressor{}, state: &mux.State{}, encoding: Gzip})
+	}
+
+	t.Run("plain writer", func(t *testing.T) {
+		w := newWriter(plainWriter{httptest.NewRecorder()})
+		if _, ok := w.(http.Flusher); ok {
+			t.Fatalf("expecting not a flusher")
+		}
+		if _, ok := w.(http.Hijacker); ok {
+			t.Fatalf("expecting not a hijacker")
+		}
+	})
+
+	t.Run("flusher only", func(t *testing.T) {
+		w := newWriter(httptest.NewRecorder())
+		if _, ok := w.(http.Flusher); !ok {
+			t.Fatalf("expecting a flusher")
+		}
+		if _, ok := w.(http.Hijacker); ok {
+			t.Fatalf("expecting not a hijacker")
+		}
+	})
+
+	t.Run("all interfaces", func(t *testing.T) {
+		inner := &hijackWriter{ResponseRecorder: httptest.NewRecorder()}
+		w := newWriter(inner)
+		if _, ok := w.(http.Flusher); !ok {
+			t.Fatalf("expecting a flusher")
+		}
+
+		if _, _, err := w.(http.Hijacker).Hijack(); err != nil || !inner.hijacked {
+			t.Fatalf("expecting inner Hijack is used")
+		}
+	})
+}
+
+// newStateContext creates the request context with a mux.State, like the Router does.
+func newStateContext(r *http.Request) context.Context {
+	return context.WithValue(r.Context(), mux.StateKey, &mux.State{})
+}
@@ -0,0 +1,302 @@
+package compress
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/josestg/justforfun/pkg/mux"
+)
+
+// encoder is the common methods of gzip.Writer and flate.Writer.
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// getEncoder gets a pooled encoder for the encoding that writes into w.
+func (c *compressor) getEncoder(encoding string, w io.Writer) encoder {
+	switch encoding {
+	case Gzip:
+		if enc, ok := c.gzipPool.Get().(*gzip.Writer); ok {
+			enc.Reset(w)
+			return enc
+		}
+
+		// the level has been validated by WithLevel.
+		enc, _ := gzip.NewWriterLevel(w, c.level)
+		return enc
+	default:
+		if enc, ok := c.deflatePool.Get().(*flate.Writer); ok {
+			enc.Reset(w)
+			return enc
+		}
+
+		enc, _ := flate.NewWriter(w, c.level)
+		return enc
+	}
+}
+
+// putEncoder returns the encoder into its pool.
+func (c *compressor) putEncoder(enc encoder) {
+	switch enc := enc.(type) {
+	case *gzip.Writer:
+		c.gzipPool.Put(enc)
+	case *flate.Writer:
+		c.deflatePool.Put(enc)
+	}
+}
+
+// countWriter counts the bytes written into the response by the encoder.
+type countWriter struct {
+	w     io.Writer
+	state *mux.State
+}
+
+func (c *countWriter) Write(b []byte) (int, error) {
+	n, err := c.w.Write(b)
+	c.state.CompressedBytes += int64(n)
+	return n, err
+}
+
+// compressWriter buffers the beginning of the body until it can decide
+// whether to compress the response, then writes through the encoder.
+type compressWriter struct {
+	http.ResponseWriter
+	compressor *compressor
+	state      *mux.State
+	encoding   string
+
+	// revalidated is true if the client has sent the suffixed ETag in the
+	// If-None-Match, so the 304 Not Modified keeps the suffix.
+	revalidated bool
+
+	status  int
+	buf     []byte
+	decided bool
+	encoder encoder
+}
+
+// Unwrap returns the inner writer.
+// It is used by http.ResponseController since Go 1.20.
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+func (cw *compressWriter) WriteHeader(code int) {
+	// the informational status is not the final status, except 101 Switching Protocols.
+	if code >= 100 && code < 200 && code != http.StatusSwitchingProtocols {
+		cw.ResponseWriter.WriteHeader(code)
+		return
+	}
+
+	if cw.decided {
+		return
+	}
+
+	// the response is still buffered, so the later status replaces it. It lets the
+	// error handler replace the partial body of the failed handler.
+	cw.status = code
+	cw.buf = cw.buf[:0]
+	if !bodyAllowed(code) {
+		cw.decide(false)
+	}
+}
+
+func (cw *compressWriter) Write(b []byte) (int, error) {
+	if cw.decided {
+		return cw.write(b)
+	}
+
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+
+	cw.buf = append(cw.buf, b...)
+	if len(cw.buf) >= cw.compressor.minSize {
+		if err := cw.decide(true); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(b), nil
+}
+
+// wrapWriter returns cw as a http.ResponseWriter.
+//
+// The returned writer implements http.Flusher and http.Hijacker only if the
+// inner writer implements them, so the type assertion done by the handler
+// still tells the truth about the inner writer.
+func wrapWriter(cw *compressWriter) http.ResponseWriter {
+	_, isFlusher := cw.ResponseWriter.(http.Flusher)
+	_, isHijacker := cw.ResponseWriter.(http.Hijacker)
+
+	f, h := flusher{cw}, hijacker{cw}
+
+	switch {
+	case isFlusher && isHijacker:
+		return struct {
+			*compressWriter
+			http.Flusher
+			http.Hijacker
+		}{cw, f, h}
+	case isFlusher:
+		return struct {
+			*compressWriter
+			http.Flusher
+		}{cw, f}
+	case isHijacker:
+		return struct {
+			*compressWriter
+			http.Hijacker
+		}{cw, h}
+	default:
+		return cw
+	}
+}
+
+// flusher implements http.Flusher for compressWriter.
+type flusher struct{ cw *compressWriter }
+
+// Flush writes the buffered body and flushes the encoder. The streaming body
+// is compressed even if it is still smaller than the minimum size.
+func (f flusher) Flush() {
+	cw := f.cw
+	if !cw.decided {
+		if cw.status == 0 {
+			cw.status = http.StatusOK
+		}
+
+		if err := cw.decide(true); err != nil {
+			return
+		}
+	}
+
+	if cw.encoder != nil {
+		if err := cw.encoder.Flush(); err != nil {
+			return
+		}
+	}
+
+	cw.ResponseWriter.(http.Flusher).Flush()
+}
+
+// hijacker implements http.Hijacker for compressWriter.
+type hijacker struct{ cw *compressWriter }
+
+// Hijack lets the caller take over the connection, nothing is compressed after that.
+func (h hijacker) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h.cw.decided = true
+	return h.cw.ResponseWriter.(http.Hijacker).Hijack()
+}
+
+// Close completes the response. If nothing has been written, the response is
+// left untouched, so the outer layers can still write it.
+func (cw *compressWriter) Close() error {
+	if !cw.decided {
+		if cw.status == 0 {
+			return nil
+		}
+
+		if err := cw.decide(len(cw.buf) >= cw.compressor.minSize); err != nil {
+			return err
+		}
+	}
+
+	if cw.encoder == nil {
+		return nil
+	}
+
+	err := cw.encoder.Close()
+	cw.compressor.putEncoder(cw.encoder)
+	cw.encoder = nil
+	return err
+}
+
+// decide writes the header and the buffered body, with or without compression.
+// The sized is false if the body is known to be too small to be compressed.
+func (cw *compressWriter) decide(sized bool) error {
+	cw.decided = true
+
+	// the content type must be detected from the plain body, because
+	// net/http would detect it from the compressed body.
+	h := cw.Header()
+	if h.Get("Content-Type") == "" && h.Get("Content-Encoding") == "" && len(cw.buf) != 0 {
+		h.Set("Content-Type", http.DetectContentType(cw.buf))
+	}
+
+	if sized && cw.compressible() {
+		h.Del("Content-Length")
+		h.Set("Content-Encoding", cw.encoding)
+
+		cw.state.ContentEncoding = cw.encoding
+		cw.encoder = cw.compressor.getEncoder(cw.encoding, &countWriter{w: cw.ResponseWriter, state: cw.state})
+	}
+
+	// the 304 Not Modified describes the representation the client has.
+	if cw.encoder != nil || (cw.status == http.StatusNotModified && cw.revalidated) {
+		if etag := h.Get("ETag"); etag != "" {
+			h.Set("ETag", encodeETag(etag, cw.encoding))
+		}
+	}
+
+	cw.ResponseWriter.WriteHeader(cw.status)
+
+	buf := cw.buf
+	cw.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+
+	_, err := cw.write(buf)
+	return err
+}
+
+// write writes b into the encoder if the response is compressed.
+func (cw *compressWriter) write(b []byte) (int, error) {
+	if cw.encoder == nil {
+		return cw.ResponseWriter.Write(b)
+	}
+
+	n, err := cw.encoder.Write(b)
+	cw.state.UncompressedBytes += int64(n)
+	return n, err
+}
+
+// compressible reports whether the response can be compressed.
+func (cw *compressWriter) compressible() bool {
+	if !bodyAllowed(cw.status) {
+		return false
+	}
+
+	h := cw.Header()
+	if h.Get("Content-Encoding") != "" {
+		return false
+	}
+
+	if cw.compressor.skip(h.Get("Content-Type")) {
+		return false
+	}
+
+	if cl, err := strconv.Atoi(h.Get("Content-Length")); err == nil && cl < cw.compressor.minSize {
+		return false
+	}
+
+	return true
+}
+
+// bodyAllowed reports whether the status allows a response body.
+func bodyAllowed(status int) bool {
+	switch {
+	case status >= 100 && status < 200:
+		return false
+	case status == http.StatusNoContent, status == http.StatusNotModified:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,297 @@
+// Package cors provides a Cross-Origin Resource Sharing middleware for the mux.Router.
+package cors
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/josestg/justforfun/pkg/mux"
+)
+
+// Headers of the CORS protocol.
+const (
+	HeaderOrigin           = "Origin"
+	HeaderRequestMethod    = "Access-Control-Request-Method"
+	HeaderRequestHeaders   = "Access-Control-Request-Headers"
+	HeaderAllowOrigin      = "Access-Control-Allow-Origin"
+	HeaderAllowMethods     = "Access-Control-Allow-Methods"
+	HeaderAllowHeaders     = "Access-Control-Allow-Headers"
+	HeaderAllowCredentials = "Access-Control-Allow-Credentials"
+	HeaderExposeHeaders    = "Access-Control-Expose-Headers"
+	HeaderMaxAge           = "Access-Control-Max-Age"
+)
+
+var (
+	// DefaultMethods are the allowed methods if Config.AllowedMethods is empty.
+	DefaultMethods = []string{
+		http.MethodGet,
+		http.MethodHead,
+		http.MethodPost,
+		http.MethodPut,
+		http.MethodPatch,
+		http.MethodDelete,
+	}
+
+	// DefaultHeaders are the allowed headers if Config.AllowedHeaders is empty.
+	DefaultHeaders = []string{
+		"Accept",
+		"Accept-Language",
+		"Content-Language",
+		"Content-Type",
+		"Authorization",
+		"Idempotency-Key",
+		mux.RequestIDHeader,
+	}
+)
+
+// Config is the CORS policy.
+type Config struct {
+	// AllowedOrigins are the origins that can access the resources.
+	// The origin can be exact ("https://example.com"), a wildcard subdomain
+	// ("https://*.example.com"), or "*" for any origin.
+	AllowedOrigins []string
+
+	// AllowOriginFunc reports whether the origin can access the resources.
+	// It is checked if the origin does not match the AllowedOrigins.
+	AllowOriginFunc func(origin string) bool `json:"-"`
+
+	// AllowedMethods are the methods the client can use in the actual request.
+	// The default is DefaultMethods.
+	AllowedMethods []string
+
+	// AllowedHeaders are the headers the client can use in the actual request,
+	// "*" allows any header. The default is DefaultHeaders.
+	AllowedHeaders []string
+
+	// ExposedHeaders are the response headers the client can read.
+	ExposedHeaders []string
+
+	// AllowCredentials allows the client to send cookies and authorization.
+	AllowCredentials bool
+
+	// MaxAge is how long the preflight result can be cached by the client.
+	// Zero means the header is not sent.
+	MaxAge time.Duration
+}
+
+// policy is the parsed Config.
+type policy struct {
+	anyOrigin   bool
+	origins     map[string]struct{}
+	wildcards   []wildcard
+	originFunc  func(origin string) bool
+	methods     map[string]struct{}
+	anyHeader   bool
+	headers     map[string]struct{}
+	credentials bool
+
+	allowMethods  string
+	exposeHeaders string
+	maxAge        string
+}
+
+// wildcard is a wildcard subdomain origin, split at the '*'.
+type wildcard struct {
+	prefix string
+	suffix string
+}
+
+func (w wildcard) match(origin string) bool {
+	if len(origin) <= len(w.prefix)+len(w.suffix) ||
+		!strings.HasPrefix(origin, w.prefix) ||
+		!strings.HasSuffix(origin, w.suffix) {
+		return false
+	}
+
+	subdomain := origin[len(w.prefix) : len(origin)-len(w.suffix)]
+	return !strings.ContainsAny(subdomain, "/:@")
+}
+
+// New creates a middleware that applies the CORS policy.
+//
+// For the allowed origin, the middleware sets the Access-Control-* headers
+// before calling the next handler, so the error responses can be read by the
+// client too. The preflight request is passed to the next handler as well,
+// which is the Router's automatic OPTIONS response for the registered routes.
+// The CORS headers are omitted for the disallowed origin, method or headers,
+// and the browser rejects the response.
+//
+// New panics if an origin pattern is invalid.
+func New(c Config) mux.Middleware {
+	p := newPolicy(c)
+
+	return func(handler mux.Handler) mux.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) error {
+			h := w.Header()
+			origin := r.Header.Get(HeaderOrigin)
+
+			if r.Method == http.MethodOptions && r.Header.Get(HeaderRequestMethod) != "" {
+				h.Add("Vary", HeaderOrigin)
+				h.Add("Vary", HeaderRequestMethod)
+				h.Add("Vary", HeaderRequestHeaders)
+
+				if origin != "" {
+					p.preflight(h, origin, r.Header.Get(HeaderRequestMethod), r.Header.Get(HeaderRequestHeaders))
+				}
+
+				return handler.ServeHTTP(w, r)
+			}
+
+			h.Add("Vary", HeaderOrigin)
+			if origin != "" && p.allowOrigin(origin) {
+				p.setOrigin(h, origin)
+				if p.exposeHeaders != "" {
+					h.Set(HeaderExposeHeaders, p.exposeHeaders)
+				}
+			}
+
+			return handler.ServeHTTP(w, r)
+		}
+
+		return mux.HandlerFunc(fn)
+	}
+}
+
+// newPolicy parses the Config.
+func newPolicy(c Config) *policy {
+	p := policy{
+		origins:     make(map[string]struct{}),
+		originFunc:  c.AllowOriginFunc,
+		methods:     make(map[string]struct{}),
+		headers:     make(map[string]struct{}),
+		credentials: c.AllowCredentials,
+	}
+
+	for _, origin := range c.AllowedOrigins {
+		origin = strings.ToLower(strings.TrimSpace(origin))
+		switch n := strings.Count(origin, "*"); {
+		case origin == "*":
+			p.anyOrigin = true
+		case n == 0:
+			p.origins[origin] = struct{}{}
+		case n == 1 && strings.Contains(origin, "://*."):
+			i := strings.Index(origin, "*")
+			p.wildcards = append(p.wildcards, wildcard{prefix: origin[:i], suffix: origin[i+1:]})
+		default:
+			panic(fmt.Sprintf("cors: invalid origin pattern %q", origin))
+		}
+	}
+
+	methods := c.AllowedMethods
+	if len(methods) == 0 {
+		methods = DefaultMethods
+	}
+
+	allowed := make([]string, 0, len(methods))
+	for _, method := range methods {
+		method = strings.ToUpper(strings.TrimSpace(method))
+		p.methods[method] = struct{}{}
+		allowed = append(allowed, method)
+	}
+
+	headers := c.AllowedHeaders
+	if len(headers) == 0 {
+		headers = DefaultHeaders
+	}
+
+	for _, header := range headers {
+		if header == "*" {
+			p.anyHeader = true
+			continue
+		}
+		p.headers[http.CanonicalHeaderKey(header)] = struct{}{}
+	}
+
+	exposed := make([]string, 0, len(c.ExposedHeaders))
+	for _, header := range c.ExposedHeaders {
+		exposed = append(exposed, http.CanonicalHeaderKey(header))
+	}
+
+	p.allowMethods = strings.Join(allowed, ", ")
+	p.exposeHeaders = strings.Join(exposed, ", ")
+	if c.MaxAge > 0 {
+		p.maxAge = strconv.Itoa(int(c.MaxAge.Seconds()))
+	}
+
+	return &p
+}
+
+// preflight sets the preflight response headers if the request is allowed.
+func (p *policy) preflight(h http.Header, origin, method, headers string) {
+	if !p.allowOrigin(origin) || !p.allowMethod(method) {
+		return
+	}
+
+	requested := make([]string, 0)
+	for _, header := range strings.Split(headers, ",") {
+		if header = strings.TrimSpace(header); header == "" {
+			continue
+		}
+
+		header = http.CanonicalHeaderKey(header)
+		if _, allowed := p.headers[header]; !allowed && !p.anyHeader {
+			return
+		}
+
+		requested = append(requested, header)
+	}
+
+	p.setOrigin(h, origin)
+	h.Set(HeaderAllowMethods, p.allowMethods)
+	if len(requested) != 0 {
+		h.Set(HeaderAllowHeaders, strings.Join(requested, ", "))
+	}
+
+	if p.maxAge != "" {
+		h.Set(HeaderMaxAge, p.maxAge)
+	}
+}
+
+// setOrigin sets the allowed origin and credentials headers.
+func (p *policy) setOrigin(h http.Header, origin string) {
+	// the "*" can not be used with credentials, so the origin is echoed.
+	if p.anyOrigin && !p.credentials {
+		h.Set(HeaderAllowOrigin, "*")
+	} else {
+		h.Set(HeaderAllowOrigin, origin)
+	}
+
+	if p.credentials {
+		h.Set(HeaderAllowCredentials, "true")
+	}
+}
+
+// allowOrigin reports whether the origin is allowed.
+func (p *policy) allowOrigin(origin string) bool {
+	if p.anyOrigin {
+		return true
+	}
+
+	lower := strings.ToLower(origin)
+	if _, exist := p.origins[lower]; exist {
+		return true
+	}
+
+	for _, w := range p.wildcards {
+		if w.match(lower) {
+			return true
+		}
+	}
+
+	return p.originFunc != nil && p.originFunc(origin)
+}
+
+// allowMethod reports whether the method is allowed.
+// The simple methods are always allowed.
+func (p *policy) allowMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost:
+		return true
+	}
+
+	_, exist := p.methods[method]
+	return exist
+}
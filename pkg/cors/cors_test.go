@@ -0,0 +1,137 @@
+package cors
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/josestg/justforfun/pkg/mux"
+)
+
+func newTestRouter(c Config) *mux.Router {
+	router := mux.NewRouter(nil, New(c))
+	router.Handle("GET /v1/users/{id}", mux.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		w.Header().Set("X-Total", "1")
+		_, err := io.WriteString(w, "user")
+		return err
+	}))
+	router.Handle("DELETE /v1/users/{id}", mux.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		return nil
+	}))
+	return router
+}
+
+func TestPreflight(t *testing.T) {
+	router := newTestRouter(Config{
+		AllowedOrigins: []string{"https://app.example.com", "https://*.example.org"},
+		AllowOriginFunc: func(origin string) bool {
+			return origin == "http://localhost:3000"
+		},
+		AllowedHeaders:   []string{"Content-Type", "Authorization"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	})
+
+	tests := []struct {
+		name    string
+		path    string
+		origin  string
+		method  string
+		headers string
+		status  int
+		allowed bool
+	}{
+		{"exact origin", "/v1/users/1", "https://app.example.com", http.MethodDelete, "content-type, authorization", http.StatusNoContent, true},
+		{"wildcard subdomain", "/v1/users/1", "https://a.b.example.org", http.MethodDelete, "", http.StatusNoContent, true},
+		{"origin func", "/v1/users/1", "http://localhost:3000", http.MethodGet, "", http.StatusNoContent, true},
+		{"wildcard does not match apex", "/v1/users/1", "https://example.org", http.MethodDelete, "", http.StatusNoContent, false},
+		{"unknown origin", "/v1/users/1", "https://evil.com", http.MethodDelete, "", http.StatusNoContent, false},
+		{"disallowed method", "/v1/users/1", "https://app.example.com", "TRACE", "", http.StatusNoContent, false},
+		{"disallowed header", "/v1/users/1", "https://app.example.com", http.MethodDelete, "X-Secret", http.StatusNoContent, false},
+		{"unregistered route", "/v1/posts", "https://app.example.com", http.MethodGet, "", http.StatusNotFound, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodOptions, tt.path, nil)
+			req.Header.Set(HeaderOrigin, tt.origin)
+			req.Header.Set(HeaderRequestMethod, tt.method)
+			if tt.headers != "" {
+				req.Header.Set(HeaderRequestHeaders, tt.headers)
+			}
+
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("expecting status %d but got %d", tt.status, rec.Code)
+			}
+
+			got := rec.Header().Get(HeaderAllowOrigin)
+			if !tt.allowed {
+				if got != "" {
+					t.Fatalf("expecting no allow origin but got %q", got)
+				}
+				return
+			}
+
+			if got != tt.origin {
+				t.Fatalf("expecting allow origin %q but got %q", tt.origin, got)
+			}
+
+			if rec.Header().Get(HeaderAllowCredentials) != "true" || rec.Header().Get(HeaderMaxAge) != "600" {
+				t.Fatalf("expecting credentials and max age but got %v", rec.Header())
+			}
+
+			if rec.Header().Get(HeaderAllowMethods) != strings.Join(DefaultMethods, ", ") {
+				t.Fatalf("expecting default methods but got %q", rec.Header().Get(HeaderAllowMethods))
+			}
+
+			if tt.headers != "" && rec.Header().Get(HeaderAllowHeaders) != "Content-Type, Authorization" {
+				t.Fatalf("expecting requested headers but got %q", rec.Header().Get(HeaderAllowHeaders))
+			}
+		})
+	}
+}
+
+func TestActualRequest(t *testing.T) {
+	router := newTestRouter(Config{
+		AllowedOrigins: []string{"*"},
+		ExposedHeaders: []string{"x-total"},
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/users/1", nil)
+	req.Header.Set(HeaderOrigin, "https://anyone.dev")
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK || rec.Body.String() != "user" {
+		t.Fatalf("expecting handler response but got %d %q", rec.Code, rec.Body.String())
+	}
+
+	if got := rec.Header().Get(HeaderAllowOrigin); got != "*" {
+		t.Fatalf("expecting allow origin * but got %q", got)
+	}
+
+	if got := rec.Header().Get(HeaderExposeHeaders); got != "X-Total" {
+		t.Fatalf("expecting exposed headers but got %q", got)
+	}
+
+	if got := rec.Header().Values("Vary"); len(got) != 1 || got[0] != HeaderOrigin {
+		t.Fatalf("expecting vary origin but got %v", got)
+	}
+}
+
+func TestInvalidOrigin(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("expecting panic for invalid origin pattern")
+		}
+	}()
+
+	New(Config{AllowedOrigins: []string{"https://*.*.example.com"}})
+}
@@ -3,6 +3,7 @@ package env
 import (
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -63,3 +64,22 @@ func Bool(key string, initial bool) bool {
 
 	return b
 }
+
+// Strings returns the env comma-separated values if the key exists.
+// Otherwise, returns initial value.
+// Each value is trimmed and the empty values are dropped.
+func Strings(key string, initial []string) []string {
+	v, exists := os.LookupEnv(key)
+	if !exists {
+		return initial
+	}
+
+	values := make([]string, 0)
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			values = append(values, s)
+		}
+	}
+
+	return values
+}
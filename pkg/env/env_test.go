@@ -2,6 +2,7 @@ package env
 
 import (
 	"os"
+	"reflect"
 	"testing"
 	"time"
 )
@@ -132,3 +133,28 @@ func TestBool(t *testing.T) {
 	// this should be panic.
 	_ = Bool(key, initial)
 }
+
+func TestStrings(t *testing.T) {
+	const key = "TESTING_ENV_STRINGS"
+	initial := []string{"a"}
+
+	if got := Strings(key, initial); !reflect.DeepEqual(got, initial) {
+		t.Errorf("expecting using the initial value")
+	}
+
+	if err := os.Setenv(key, " b, ,c "); err != nil {
+		t.Errorf("expecting error nil but got %v", err)
+	}
+
+	if got := Strings(key, initial); !reflect.DeepEqual(got, []string{"b", "c"}) {
+		t.Errorf("expcting using the env value but got %v", got)
+	}
+
+	if err := os.Setenv(key, ""); err != nil {
+		t.Errorf("expecting error nil but got %v", err)
+	}
+
+	if got := Strings(key, initial); len(got) != 0 {
+		t.Errorf("expcting empty values but got %v", got)
+	}
+}
@@ -0,0 +1,304 @@
+// Package idempotency provides the Idempotency-Key middleware for the mux.Router.
+// see: https://datatracker.ietf.org/doc/draft-ietf-httpapi-idempotency-key-header.
+//
+// The first response of a key is stored with the fingerprint of its request,
+// then the retries of the same request get the stored response instead of
+// executing the handler again, so a client can safely retry a POST.
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/josestg/justforfun/pkg/mux"
+	"github.com/josestg/justforfun/pkg/xerrs"
+)
+
+// Headers of the idempotency.
+const (
+	HeaderKey      = "Idempotency-Key"
+	HeaderReplayed = "Idempotent-Replayed"
+	HeaderRetry    = "Retry-After"
+)
+
+const (
+	// DefaultTTL is the default time the response of a key is replayed.
+	DefaultTTL = 24 * time.Hour
+
+	// DefaultLockTimeout is the default time an in-progress request holds its key.
+	DefaultLockTimeout = time.Minute
+
+	// DefaultMaxBodySize is the default maximum size of the fingerprinted request body.
+	DefaultMaxBodySize = 1 << 20
+
+	// MaxKeyLength is the maximum length of the Idempotency-Key.
+	MaxKeyLength = 255
+)
+
+// storeTimeout limits the time of saving or unlocking the key, they are done
+// after the handler, so the request context may have been canceled.
+const storeTimeout = 5 * time.Second
+
+// skippedHeaders are the response headers that are not stored,
+// they belong to the request that is replaying the response.
+var skippedHeaders = []string{mux.RequestIDHeader, "Date", "Content-Length"}
+
+// Option is an option type that can be used to customize the middleware.
+type Option func(c *config)
+
+// config holds the options.
+type config struct {
+	ttl         time.Duration
+	lockTimeout time.Duration
+	maxBodySize int64
+	scope       func(r *http.Request) string
+	now         func() time.Time
+}
+
+// WithTTL sets the time the response of a key is replayed, after that the key
+// can be used again. The default is DefaultTTL.
+func WithTTL(d time.Duration) Option {
+	return func(c *config) {
+		c.ttl = d
+	}
+}
+
+// WithLockTimeout sets the time an in-progress request holds its key. If the
+// request dies without a response, such as the instance crashes, its retry can
+// lock the key after that. It must be longer than the handler timeout.
+// The default is DefaultLockTimeout.
+func WithLockTimeout(d time.Duration) Option {
+	return func(c *config) {
+		c.lockTimeout = d
+	}
+}
+
+// WithMaxBodySize sets the maximum size of the request body, the larger body
+// fails with 413 Payload Too Large. The default is DefaultMaxBodySize.
+func WithMaxBodySize(n int64) Option {
+	return func(c *config) {
+		c.maxBodySize = n
+	}
+}
+
+// WithScope sets the function that returns the owner of the keys, for example
+// auth.Subject of the request context, so the clients can not see the
+// responses of each other. By default, the keys are global.
+func WithScope(fn func(r *http.Request) string) Option {
+	return func(c *config) {
+		c.scope = fn
+	}
+}
+
+// WithClock sets the time source of the middleware.
+func WithClock(now func() time.Time) Option {
+	return func(c *config) {
+		c.now = now
+	}
+}
+
+// Middleware creates a middleware that makes the POST and PATCH requests with
+// the Idempotency-Key header idempotent. The other requests are passed through.
+//
+// The first request of a key executes the handler, and its response is stored
+// in the store. The retry with the same method, URI and body gets the stored
+// response with the Idempotent-Replayed header. The request with the key of an
+// in-progress request fails with 409 Conflict and the Retry-After header, and
+// the request that reuses the key with a different payload fails with
+// 422 Unprocessable Entity.
+//
+// The 5xx responses are not stored, so the request can be retried, except the
+// request that is timed out by mux.Timeout: its handler may still be running,
+// so its key is locked until the lock timeout. The response is stored as a
+// whole, so the middleware is not suitable for the streaming handlers.
+func Middleware(store Store, options ...Option) mux.Middleware {
+	c := config{
+		ttl:         DefaultTTL,
+		lockTimeout: DefaultLockTimeout,
+		maxBodySize: DefaultMaxBodySize,
+		now:         time.Now,
+	}
+
+	for _, fn := range options {
+		fn(&c)
+	}
+
+	return func(handler mux.Handler) mux.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) error {
+			if r.Method != http.MethodPost && r.Method != http.MethodPatch {
+				return handler.ServeHTTP(w, r)
+			}
+
+			values := r.Header.Values(HeaderKey)
+			if len(values) == 0 {
+				return handler.ServeHTTP(w, r)
+			}
+
+			key := values[0]
+			if len(values) > 1 || key == "" || len(key) > MaxKeyLength {
+				detail := fmt.Sprintf("the %s header must be a single value of 1 to %d characters", HeaderKey, MaxKeyLength)
+				return mux.NewError(http.StatusBadRequest, "invalid_idempotency_key", detail)
+			}
+
+			return c.serve(store, handler, key, w, r)
+		}
+
+		return mux.HandlerFunc(fn)
+	}
+}
+
+// serve serves the request of the valid key.
+func (c *config) serve(store Store, handler mux.Handler, key string, w http.ResponseWriter, r *http.Request) error {
+	body, err := io.ReadAll(io.LimitReader(r.Body, c.maxBodySize+1))
+	if err != nil {
+		return mux.WrapError(err, http.StatusBadRequest, "invalid_body", "request body can not be read")
+	}
+
+	if int64(len(body)) > c.maxBodySize {
+		detail := fmt.Sprintf("request body must not be larger than %d bytes", c.maxBodySize)
+		return mux.NewError(http.StatusRequestEntityTooLarge, "body_too_large", detail)
+	}
+
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	if c.scope != nil {
+		key = c.scope(r) + ":" + key
+	}
+
+	fingerprint := fingerprint(r, body)
+	now := c.now()
+
+	record, err := store.Lock(r.Context(), key, fingerprint, now, now.Add(c.lockTimeout), now.Add(-c.ttl))
+	if err != nil {
+		return xerrs.Wrap(err, "locking idempotency key")
+	}
+
+	if record != nil {
+		return replay(w, record, fingerprint, now)
+	}
+
+	rec := &recorder{ResponseWriter: w}
+	saved := false
+
+	// the key is unlocked if the handler fails or panics, so the retry can
+	// execute the handler again. The request context may have been canceled.
+	// The key of the timed out request stays locked until the lock timeout,
+	// since mux.Timeout leaves its handler running, and the retry would
+	// execute the handler twice.
+	defer func() {
+		if saved || timedOut(r) {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+		defer cancel()
+		_ = store.Unlock(ctx, key)
+	}()
+
+	err = handler.ServeHTTP(rec, r)
+
+	status := rec.status
+	if status == 0 && err == nil {
+		status = http.StatusOK
+	}
+
+	if status == 0 || status >= http.StatusInternalServerError {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+
+	res := Response{Status: status, Header: rec.header, Body: rec.body.Bytes()}
+	if saveErr := store.Save(ctx, key, &res); saveErr != nil {
+		// the response has been written, so the error is only recorded.
+		if err == nil {
+			err = xerrs.Wrap(saveErr, "saving idempotency key")
+		}
+		return err
+	}
+
+	saved = true
+	return err
+}
+
+// timedOut reports whether the request has been timed out by mux.Timeout.
+func timedOut(r *http.Request) bool {
+	state, err := mux.GetState(r.Context())
+	return err == nil && state.TimedOut
+}
+
+// replay writes the stored response of the record, or the error if the
+// record can not be replayed.
+func replay(w http.ResponseWriter, record *Record, fingerprint string, now time.Time) error {
+	if record.Fingerprint != fingerprint {
+		detail := fmt.Sprintf("the %s has been used by a different request", HeaderKey)
+		return mux.NewError(http.StatusUnprocessableEntity, "idempotency_key_reused", detail)
+	}
+
+	if record.Response == nil {
+		retry := math.Ceil(record.LockedUntil.Sub(now).Seconds())
+		if retry < 1 {
+			retry = 1
+		}
+
+		w.Header().Set(HeaderRetry, strconv.Itoa(int(retry)))
+		detail := fmt.Sprintf("a request with the same %s is in progress", HeaderKey)
+		return mux.NewError(http.StatusConflict, "request_in_progress", detail)
+	}
+
+	h := w.Header()
+	for k, v := range record.Response.Header {
+		h[k] = v
+	}
+	h.Set(HeaderReplayed, "true")
+
+	w.WriteHeader(record.Response.Status)
+	_, err := w.Write(record.Response.Body)
+	return err
+}
+
+// fingerprint hashes the method, the URI and the body of the request.
+func fingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	_, _ = io.WriteString(h, r.Method+" "+r.URL.RequestURI()+"\n")
+	_, _ = h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// recorder is a http.ResponseWriter that copies the response while writing it.
+type recorder struct {
+	http.ResponseWriter
+	status int
+	header http.Header
+	body   bytes.Buffer
+}
+
+func (w *recorder) WriteHeader(code int) {
+	if w.status == 0 && (code < 100 || code >= 200) {
+		w.status = code
+		w.header = w.Header().Clone()
+		for _, k := range skippedHeaders {
+			w.header.Del(k)
+		}
+	}
+
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *recorder) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
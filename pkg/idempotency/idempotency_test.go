@@ -0,0 +1,305 @@
+package idempotency
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/josestg/justforfun/pkg/mux"
+)
+
+// newTestRouter creates a router whose POST /orders handler creates an order
+// for each execution, and returns the number of the executions.
+func newTestRouter(store Store, options ...Option) (*mux.Router, *int32) {
+	var created int32
+
+	router := mux.NewRouter(nil)
+	router.Handle("POST /orders", mux.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		body, _ := io.ReadAll(r.Body)
+		if string(body) == "fail" {
+			return mux.NewError(http.StatusServiceUnavailable, "unavailable", "try again")
+		}
+
+		if string(body) == "invalid" {
+			return mux.NewError(http.StatusBadRequest, "invalid_body", "invalid order")
+		}
+
+		id := atomic.AddInt32(&created, 1)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Location", "/orders/1")
+		w.WriteHeader(http.StatusCreated)
+		return json.NewEncoder(w).Encode(map[string]interface{}{"id": id, "item": string(body)})
+	}), Middleware(store, options...))
+
+	return router, &created
+}
+
+func post(router http.Handler, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(body))
+	if key != "" {
+		req.Header.Set(HeaderKey, key)
+	}
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestMiddleware_Replay(t *testing.T) {
+	router, created := newTestRouter(NewMemoryStore())
+
+	first := post(router, "key-1", "book")
+	if first.Code != http.StatusCreated {
+		t.Fatalf("expecting status 201 but got %d", first.Code)
+	}
+
+	if first.Header().Get(HeaderReplayed) != "" {
+		t.Fatalf("expecting the first response is not replayed")
+	}
+
+	retry := post(router, "key-1", "book")
+	if retry.Code != http.StatusCreated {
+		t.Fatalf("expecting status 201 but got %d", retry.Code)
+	}
+
+	if got := retry.Header().Get(HeaderReplayed); got != "true" {
+		t.Fatalf("expecting the replayed header but got %q", got)
+	}
+
+	if got := retry.Header().Get("Location"); got != "/orders/1" {
+		t.Fatalf("expecting the stored header but got %q", got)
+	}
+
+	if got, want := retry.Body.String(), first.Body.String(); got != want {
+		t.Fatalf("expecting body %q but got %q", want, got)
+	}
+
+	if retry.Header().Get(mux.RequestIDHeader) == first.Header().Get(mux.RequestIDHeader) {
+		t.Fatalf("expecting the request id of the retry")
+	}
+
+	if n := atomic.LoadInt32(created); n != 1 {
+		t.Fatalf("expecting the handler is executed once but got %d", n)
+	}
+
+	// without the key, the request is not idempotent.
+	post(router, "", "book")
+	post(router, "", "book")
+	if n := atomic.LoadInt32(created); n != 3 {
+		t.Fatalf("expecting the handler is executed 3 times but got %d", n)
+	}
+}
+
+func TestMiddleware_Reused(t *testing.T) {
+	router, _ := newTestRouter(NewMemoryStore())
+
+	post(router, "key-1", "book")
+
+	rec := post(router, "key-1", "pen")
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expecting status 422 but got %d", rec.Code)
+	}
+
+	if !strings.Contains(rec.Body.String(), "idempotency_key_reused") {
+		t.Fatalf("expecting the error code but got %s", rec.Body.String())
+	}
+}
+
+func TestMiddleware_InProgress(t *testing.T) {
+	store := NewMemoryStore()
+	entered, release := make(chan struct{}), make(chan struct{})
+
+	router := mux.NewRouter(nil)
+	router.Handle("POST /slow", mux.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		close(entered)
+		<-release
+		w.WriteHeader(http.StatusAccepted)
+		return nil
+	}), Middleware(store, WithLockTimeout(30*time.Second)))
+
+	do := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/slow", nil)
+		req.Header.Set(HeaderKey, "key-1")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	var (
+		wg    sync.WaitGroup
+		first *httptest.ResponseRecorder
+	)
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		first = do()
+	}()
+
+	<-entered
+	rec := do()
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("expecting status 409 but got %d", rec.Code)
+	}
+
+	if got := rec.Header().Get(HeaderRetry); got != "30" {
+		t.Fatalf("expecting retry after 30 but got %q", got)
+	}
+
+	close(release)
+	wg.Wait()
+
+	if first.Code != http.StatusAccepted {
+		t.Fatalf("expecting status 202 but got %d", first.Code)
+	}
+
+	if rec := do(); rec.Code != http.StatusAccepted || rec.Header().Get(HeaderReplayed) != "true" {
+		t.Fatalf("expecting the replayed 202 but got %d", rec.Code)
+	}
+}
+
+func TestMiddleware_NotStored(t *testing.T) {
+	store := NewMemoryStore()
+	router, created := newTestRouter(store)
+
+	if rec := post(router, "key-1", "fail"); rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expecting status 503 but got %d", rec.Code)
+	}
+
+	if store.Len() != 0 {
+		t.Fatalf("expecting the key is unlocked after the server error")
+	}
+
+	// the key is free, so the retry executes the handler.
+	if rec := post(router, "key-1", "fail"); rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expecting status 503 but got %d", rec.Code)
+	}
+
+	// the client error is the response of the request, so it is replayed.
+	post(router, "key-2", "invalid")
+	rec := post(router, "key-2", "invalid")
+	if rec.Code != http.StatusBadRequest || rec.Header().Get(HeaderReplayed) != "true" {
+		t.Fatalf("expecting the replayed 400 but got %d", rec.Code)
+	}
+
+	if n := atomic.LoadInt32(created); n != 0 {
+		t.Fatalf("expecting no order is created but got %d", n)
+	}
+}
+
+func TestMiddleware_TimedOut(t *testing.T) {
+	store := NewMemoryStore()
+	release := make(chan struct{})
+	var executed int32
+
+	router := mux.NewRouter(nil)
+	router.Handle("POST /slow", mux.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		atomic.AddInt32(&executed, 1)
+		<-release
+		w.WriteHeader(http.StatusCreated)
+		return nil
+	}), Middleware(store, WithLockTimeout(30*time.Second)), mux.Timeout(10*time.Millisecond))
+
+	do := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/slow", nil)
+		req.Header.Set(HeaderKey, "key-1")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	defer close(release)
+
+	if rec := do(); rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expecting status 503 but got %d", rec.Code)
+	}
+
+	// the handler is still running, so the retry must not execute it again.
+	rec := do()
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("expecting status 409 but got %d", rec.Code)
+	}
+
+	if n := atomic.LoadInt32(&executed); n != 1 {
+		t.Fatalf("expecting the handler is executed once but got %d", n)
+	}
+}
+
+func TestMiddleware_Expiration(t *testing.T) {
+	now := time.Unix(1000, 0)
+	router, created := newTestRouter(NewMemoryStore(), WithTTL(time.Hour), WithClock(func() time.Time { return now }))
+
+	post(router, "key-1", "book")
+
+	now = now.Add(time.Hour + time.Second)
+	rec := post(router, "key-1", "pen")
+	if rec.Code != http.StatusCreated || rec.Header().Get(HeaderReplayed) != "" {
+		t.Fatalf("expecting the expired key is used again but got %d", rec.Code)
+	}
+
+	if n := atomic.LoadInt32(created); n != 2 {
+		t.Fatalf("expecting the handler is executed twice but got %d", n)
+	}
+}
+
+func TestMiddleware_Scope(t *testing.T) {
+	scope := WithScope(func(r *http.Request) string { return r.Header.Get("X-User") })
+	router, created := newTestRouter(NewMemoryStore(), scope)
+
+	for _, user := range []string{"alice", "bob"} {
+		req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader("book"))
+		req.Header.Set(HeaderKey, "key-1")
+		req.Header.Set("X-User", user)
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	if n := atomic.LoadInt32(created); n != 2 {
+		t.Fatalf("expecting the keys of the users are separated but got %d executions", n)
+	}
+}
+
+func TestMiddleware_InvalidRequest(t *testing.T) {
+	router, _ := newTestRouter(NewMemoryStore(), WithMaxBodySize(4))
+
+	if rec := post(router, strings.Repeat("k", MaxKeyLength+1), "book"); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expecting status 400 but got %d", rec.Code)
+	}
+
+	if rec := post(router, "key-1", "notebook"); rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expecting status 413 but got %d", rec.Code)
+	}
+}
+
+func TestMemoryStore_DeadLock(t *testing.T) {
+	store := NewMemoryStore()
+	now := time.Unix(1000, 0)
+	ctx := context.Background()
+
+	if r, _ := store.Lock(ctx, "k", "a", now, now.Add(time.Minute), now.Add(-time.Hour)); r != nil {
+		t.Fatalf("expecting the key is locked")
+	}
+
+	// the request is in progress.
+	if r, _ := store.Lock(ctx, "k", "a", now.Add(time.Second), now.Add(time.Minute), now.Add(-time.Hour)); r == nil || r.Response != nil {
+		t.Fatalf("expecting the in-progress record")
+	}
+
+	later := now.Add(2 * time.Minute)
+
+	// the request has died, but the key can not be taken by a different request.
+	if r, _ := store.Lock(ctx, "k", "b", later, later.Add(time.Minute), later.Add(-time.Hour)); r == nil || r.Fingerprint != "a" {
+		t.Fatalf("expecting the record of the dead request")
+	}
+
+	// the retry of the dead request locks the key again.
+	if r, _ := store.Lock(ctx, "k", "a", later, later.Add(time.Minute), later.Add(-time.Hour)); r != nil {
+		t.Fatalf("expecting the key is locked again")
+	}
+}
@@ -0,0 +1,158 @@
+package idempotency
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/josestg/justforfun/pkg/xerrs"
+)
+
+// PostgresStore is a Store on the idempotency_keys table of Postgres, so the
+// keys are shared between instances. The table is created by the migration
+// vars/migrations/*_create_table_idempotency_keys.sql.
+type PostgresStore struct {
+	db *sql.DB
+}
+
+// NewPostgresStore creates a new PostgresStore.
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+// lockQuery inserts the in-progress record, or replaces the record that can be
+// locked again in the same statement, so the concurrent requests can not
+// both lock the key.
+const lockQuery = `
+INSERT INTO idempotency_keys (key, fingerprint, locked_until, date_created)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (key) DO UPDATE
+SET fingerprint     = EXCLUDED.fingerprint,
+    response_status = NULL,
+    response_header = NULL,
+    response_body   = NULL,
+    locked_until    = EXCLUDED.locked_until,
+    date_created    = EXCLUDED.date_created
+WHERE idempotency_keys.date_created < $5
+   OR (idempotency_keys.response_status IS NULL
+       AND idempotency_keys.locked_until < EXCLUDED.date_created
+       AND idempotency_keys.fingerprint = EXCLUDED.fingerprint)
+RETURNING key;`
+
+const selectQuery = `
+SELECT fingerprint, response_status, response_header, response_body, locked_until
+FROM idempotency_keys
+WHERE key = $1;`
+
+const saveQuery = `
+UPDATE idempotency_keys
+SET response_status = $2,
+    response_header = $3,
+    response_body   = $4
+WHERE key = $1 AND response_status IS NULL;`
+
+const unlockQuery = `
+DELETE FROM idempotency_keys
+WHERE key = $1 AND response_status IS NULL;`
+
+const purgeQuery = `
+DELETE FROM idempotency_keys
+WHERE date_created < $1;`
+
+func (s *PostgresStore) Lock(ctx context.Context, key, fingerprint string, now, lockedUntil, expiredBefore time.Time) (*Record, error) {
+	// the existing record may be removed between the statements,
+	// then the key is locked again.
+	for tries := 0; tries < 3; tries++ {
+		var locked string
+		err := s.db.QueryRowContext(ctx, lockQuery, key, fingerprint, lockedUntil, now, expiredBefore).Scan(&locked)
+		if err == nil {
+			return nil, nil
+		}
+
+		if !errors.Is(err, sql.ErrNoRows) {
+			return nil, xerrs.Wrap(err, "inserting idempotency key")
+		}
+
+		record, err := s.record(ctx, key)
+		if err == nil {
+			return record, nil
+		}
+
+		if !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+	}
+
+	return nil, fmt.Errorf("idempotency: can not lock the key %q", key)
+}
+
+// record selects the record of the key.
+func (s *PostgresStore) record(ctx context.Context, key string) (*Record, error) {
+	var (
+		record Record
+		status sql.NullInt32
+		header []byte
+		body   []byte
+	)
+
+	err := s.db.QueryRowContext(ctx, selectQuery, key).Scan(&record.Fingerprint, &status, &header, &body, &record.LockedUntil)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		return nil, xerrs.Wrap(err, "selecting idempotency key")
+	}
+
+	if status.Valid {
+		record.Response = &Response{Status: int(status.Int32), Body: body}
+		if err := json.Unmarshal(header, &record.Response.Header); err != nil {
+			return nil, xerrs.Wrap(err, "decoding response header")
+		}
+	}
+
+	return &record, nil
+}
+
+func (s *PostgresStore) Save(ctx context.Context, key string, res *Response) error {
+	header, err := json.Marshal(res.Header)
+	if err != nil {
+		return xerrs.Wrap(err, "encoding response header")
+	}
+
+	if res.Header == nil {
+		header = []byte("{}")
+	}
+
+	body := res.Body
+	if body == nil {
+		body = []byte{}
+	}
+
+	if _, err := s.db.ExecContext(ctx, saveQuery, key, res.Status, header, body); err != nil {
+		return xerrs.Wrap(err, "saving idempotency key")
+	}
+
+	return nil
+}
+
+func (s *PostgresStore) Unlock(ctx context.Context, key string) error {
+	if _, err := s.db.ExecContext(ctx, unlockQuery, key); err != nil {
+		return xerrs.Wrap(err, "deleting idempotency key")
+	}
+	return nil
+}
+
+// Purge removes the records created before the given time, it returns the
+// number of the removed records. The expired records are not replayed anyway,
+// Purge only keeps the table small, so it can be run periodically.
+func (s *PostgresStore) Purge(ctx context.Context, before time.Time) (int64, error) {
+	res, err := s.db.ExecContext(ctx, purgeQuery, before)
+	if err != nil {
+		return 0, xerrs.Wrap(err, "purging idempotency keys")
+	}
+
+	return res.RowsAffected()
+}
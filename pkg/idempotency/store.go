@@ -0,0 +1,129 @@
+package idempotency
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Response is the stored response of a request.
+type Response struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// Record is the stored state of a key.
+type Record struct {
+	// Fingerprint identifies the request that holds the key.
+	Fingerprint string
+
+	// Response is the response of the request, or nil while the request is in
+	// progress.
+	Response *Response
+
+	// LockedUntil is the time until the in-progress request holds the key.
+	// After that, the request is considered dead and the key can be locked again.
+	LockedUntil time.Time
+}
+
+// Store knows how to persist the records of the keys.
+//
+// The Store must be safe for concurrent use, and Lock must be atomic, so only
+// one of the concurrent requests with the same key can lock it. To share the
+// keys between instances, use a shared storage such as the PostgresStore.
+type Store interface {
+	// Lock creates an in-progress record of the key that is locked until
+	// lockedUntil, and returns nil. The key can be locked if it does not exist,
+	// or its record is created before expiredBefore, or its request has died
+	// before completing with the same fingerprint. Otherwise, it returns the
+	// existing record.
+	Lock(ctx context.Context, key, fingerprint string, now, lockedUntil, expiredBefore time.Time) (*Record, error)
+
+	// Save stores the response of the locked key.
+	Save(ctx context.Context, key string, res *Response) error
+
+	// Unlock removes the in-progress record of the key, so the request can be
+	// retried. The completed record is not removed.
+	Unlock(ctx context.Context, key string) error
+}
+
+// entry is a record with its creation time.
+type entry struct {
+	record  Record
+	created time.Time
+}
+
+// MemoryStore is an in-memory Store, it is suitable for a single instance.
+// The expired records are removed while locking the keys.
+type MemoryStore struct {
+	mu        sync.Mutex
+	entries   map[string]*entry
+	nextSweep time.Time
+}
+
+// NewMemoryStore creates a new MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]*entry)}
+}
+
+func (s *MemoryStore) Lock(_ context.Context, key, fingerprint string, now, lockedUntil, expiredBefore time.Time) (*Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// removes the expired records at most once per the lifetime of a record.
+	if now.After(s.nextSweep) {
+		for k, e := range s.entries {
+			if e.created.Before(expiredBefore) {
+				delete(s.entries, k)
+			}
+		}
+		s.nextSweep = now.Add(now.Sub(expiredBefore))
+	}
+
+	e, exist := s.entries[key]
+	if exist && !e.created.Before(expiredBefore) {
+		dead := e.record.Response == nil && e.record.LockedUntil.Before(now) && e.record.Fingerprint == fingerprint
+		if !dead {
+			record := e.record
+			return &record, nil
+		}
+	}
+
+	s.entries[key] = &entry{
+		record:  Record{Fingerprint: fingerprint, LockedUntil: lockedUntil},
+		created: now,
+	}
+
+	return nil, nil
+}
+
+func (s *MemoryStore) Save(_ context.Context, key string, res *Response) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, exist := s.entries[key]; exist {
+		e.record.Response = res
+	}
+
+	return nil
+}
+
+func (s *MemoryStore) Unlock(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, exist := s.entries[key]; exist && e.record.Response == nil {
+		delete(s.entries, key)
+	}
+
+	return nil
+}
+
+// Len returns the number of stored records.
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.entries)
+}
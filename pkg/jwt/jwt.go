@@ -134,5 +134,10 @@ func b64URLEncoded(v interface{}) ([]byte, error) {
 		return nil, err
 	}
 
+	// flush the partially written block, otherwise the last bytes are lost.
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+
 	return buf.Bytes(), nil
 }
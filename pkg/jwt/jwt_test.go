@@ -234,3 +234,26 @@ func TestDecode(t *testing.T) {
 		}
 	})
 }
+
+func TestEncode_PayloadLength(t *testing.T) {
+	selector := func(header Header) (Verifier, error) {
+		return &verifierMock{}, nil
+	}
+
+	// the payloads of different length make each partial base64 block.
+	for _, subject := range []string{"a", "ab", "abc"} {
+		token, err := Encode(&signerMock{}, Header{}, StandardClaims{Subject: subject})
+		if err != nil {
+			t.Fatalf("expecting nil but got %v", err)
+		}
+
+		var claims StandardClaims
+		if err := Decode(selector, token, &claims); err != nil {
+			t.Fatalf("subject %q: expecting nil but got %v", subject, err)
+		}
+
+		if claims.Subject != subject {
+			t.Fatalf("expecting subject %q but got %q", subject, claims.Subject)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package loadshed
+
+import "time"
+
+// Defaults of the adaptive limit.
+const (
+	// defaultSampleSize is the number of the latency samples of each limit adjustment.
+	defaultSampleSize = 20
+
+	// baselineDrift is the fraction the baseline moves toward a slower average,
+	// so a lasting latency change becomes the new normal.
+	baselineDrift = 0.05
+)
+
+// adaptive adjusts the limit by the observed latency with additive increase
+// and multiplicative decrease.
+//
+// The latencies are averaged over each sample. The baseline is the lowest
+// average seen, drifting slowly toward the slower averages. When the average
+// exceeds the baseline by the tolerance, the limit is lowered by a quarter,
+// otherwise it is raised by one.
+type adaptive struct {
+	minLimit   int
+	tolerance  float64
+	sampleSize int
+
+	count    int
+	sum      time.Duration
+	baseline time.Duration
+}
+
+// newAdaptive creates a new adaptive limit.
+func newAdaptive(minLimit int, tolerance float64) *adaptive {
+	if minLimit < 1 {
+		minLimit = 1
+	}
+
+	if tolerance <= 1 {
+		panic("loadshed: adaptive tolerance must be greater than 1")
+	}
+
+	return &adaptive{
+		minLimit:   minLimit,
+		tolerance:  tolerance,
+		sampleSize: defaultSampleSize,
+	}
+}
+
+// observe records the latency and returns the adjusted limit.
+func (a *adaptive) observe(latency time.Duration, limit, maxLimit int) int {
+	a.count++
+	a.sum += latency
+	if a.count < a.sampleSize {
+		return limit
+	}
+
+	avg := a.sum / time.Duration(a.count)
+	a.count, a.sum = 0, 0
+
+	if a.baseline == 0 || avg < a.baseline {
+		a.baseline = avg
+		return clamp(limit+1, a.minLimit, maxLimit)
+	}
+
+	overloaded := float64(avg) > float64(a.baseline)*a.tolerance
+	a.baseline += time.Duration(float64(avg-a.baseline) * baselineDrift)
+
+	if overloaded {
+		decrease := limit / 4
+		if decrease < 1 {
+			decrease = 1
+		}
+		return clamp(limit-decrease, a.minLimit, maxLimit)
+	}
+
+	return clamp(limit+1, a.minLimit, maxLimit)
+}
+
+// clamp limits v into [lo, hi].
+func clamp(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
@@ -0,0 +1,60 @@
+package loadshed
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestAdaptive_Observe(t *testing.T) {
+	a := newAdaptive(2, 2)
+	a.sampleSize = 1
+
+	steps := []struct {
+		latency time.Duration
+		limit   int
+	}{
+		{10 * time.Millisecond, 10}, // the first sample is the baseline, already at max.
+		{15 * time.Millisecond, 10}, // within the tolerance.
+		{50 * time.Millisecond, 8},  // overloaded, lowered by a quarter.
+		{50 * time.Millisecond, 6},  // still overloaded.
+		{50 * time.Millisecond, 5},  // still overloaded.
+		{10 * time.Millisecond, 6},  // recovered, raised by one.
+		{100 * time.Millisecond, 5}, // overloaded.
+		{100 * time.Millisecond, 4}, // overloaded.
+		{100 * time.Millisecond, 3}, // overloaded.
+		{100 * time.Millisecond, 2}, // overloaded.
+		{100 * time.Millisecond, 2}, // at minimum.
+		{5 * time.Millisecond, 3},   // new baseline.
+	}
+
+	limit := 10
+	for i, s := range steps {
+		limit = a.observe(s.latency, limit, 10)
+		if limit != s.limit {
+			t.Fatalf("step %d: expecting limit %d but got %d", i, s.limit, limit)
+		}
+	}
+}
+
+func TestLimiter_Adaptive(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := New(4, WithAdaptive(1, 2), WithClock(func() time.Time { return now }))
+	l.adaptive.sampleSize = 1
+
+	observe := func(latency time.Duration) {
+		release, err := l.Acquire(context.Background())
+		if err != nil {
+			t.Fatalf("expecting nil but got %v", err)
+		}
+		now = now.Add(latency)
+		release()
+	}
+
+	observe(10 * time.Millisecond)
+	observe(100 * time.Millisecond)
+
+	if got := l.Limit(); got != 3 {
+		t.Fatalf("expecting limit is lowered to 3 but got %d", got)
+	}
+}
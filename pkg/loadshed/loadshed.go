@@ -0,0 +1,301 @@
+// Package loadshed provides a concurrency limiting middleware for the mux.Router.
+//
+// The Limiter caps the number of in-flight requests. The requests above the
+// limit wait in a bounded queue for a bounded time, and the excess requests are
+// shed with 503 Service Unavailable, so the server keeps serving the admitted
+// requests with a bounded latency instead of queueing everything behind its
+// bottleneck, such as the database connection pool.
+package loadshed
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/josestg/justforfun/pkg/mux"
+)
+
+// HeaderRetry is the header that tells the shed client when to retry.
+const HeaderRetry = "Retry-After"
+
+// DefaultRetryAfter is the default Retry-After of the shed requests.
+const DefaultRetryAfter = time.Second
+
+// ErrOverloaded is an error when the request is shed.
+var ErrOverloaded = errors.New("loadshed: overloaded")
+
+// otherMethod is the limiter key method of the non-standard methods, so a
+// client can not create a limiter for each method it makes up.
+const otherMethod = "OTHER"
+
+// methods are the methods that have their own limiters.
+var methods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodConnect: true,
+	http.MethodOptions: true,
+	http.MethodTrace:   true,
+}
+
+// Option is an option type that can be used to customize the Limiter.
+type Option func(l *Limiter)
+
+// WithQueue lets at most size requests wait at most wait for a slot when the
+// limit is reached. By default, the requests above the limit are shed immediately.
+func WithQueue(size int, wait time.Duration) Option {
+	return func(l *Limiter) {
+		l.queueSize = size
+		l.queueWait = wait
+	}
+}
+
+// WithRetryAfter sets the Retry-After of the shed requests.
+// The default is DefaultRetryAfter.
+func WithRetryAfter(d time.Duration) Option {
+	return func(l *Limiter) {
+		l.retryAfter = d
+	}
+}
+
+// WithAdaptive makes the limit adaptive. The limit given to New becomes the
+// maximum limit, it is lowered down to minLimit while the observed latency is
+// above tolerance times the baseline latency, and is raised back one by one
+// while the latency is healthy. For example, tolerance 2 lowers the limit when
+// the requests become two times slower than usual.
+func WithAdaptive(minLimit int, tolerance float64) Option {
+	return func(l *Limiter) {
+		l.adaptive = newAdaptive(minLimit, tolerance)
+	}
+}
+
+// WithClock sets the time source of the latency measurement.
+func WithClock(now func() time.Time) Option {
+	return func(l *Limiter) {
+		l.now = now
+	}
+}
+
+// waiter is a queued request.
+type waiter struct {
+	ready   chan struct{}
+	granted bool
+}
+
+// Limiter limits the number of in-flight requests.
+type Limiter struct {
+	mu       sync.Mutex
+	limit    int
+	inFlight int
+	queue    []*waiter
+
+	maxLimit   int
+	queueSize  int
+	queueWait  time.Duration
+	retryAfter time.Duration
+	adaptive   *adaptive
+	now        func() time.Time
+}
+
+// New creates a new Limiter that allows at most limit in-flight requests.
+func New(limit int, options ...Option) *Limiter {
+	if limit < 1 {
+		panic("loadshed: limit must be positive")
+	}
+
+	l := Limiter{
+		limit:      limit,
+		maxLimit:   limit,
+		retryAfter: DefaultRetryAfter,
+		now:        time.Now,
+	}
+
+	for _, fn := range options {
+		fn(&l)
+	}
+
+	return &l
+}
+
+// Limit returns the current limit.
+func (l *Limiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.limit
+}
+
+// InFlight returns the number of admitted requests that are not released yet.
+func (l *Limiter) InFlight() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.inFlight
+}
+
+// Acquire admits a request, waiting in the queue if the limit is reached.
+// It returns ErrOverloaded if the queue is full or the wait is over, and the
+// ctx error if ctx is done while waiting. The returned release must be called
+// once the request is completed.
+func (l *Limiter) Acquire(ctx context.Context) (release func(), err error) {
+	l.mu.Lock()
+	if l.inFlight < l.limit && len(l.queue) == 0 {
+		l.inFlight++
+		l.mu.Unlock()
+		return l.releaser(), nil
+	}
+
+	if len(l.queue) >= l.queueSize || l.queueWait <= 0 {
+		l.mu.Unlock()
+		return nil, ErrOverloaded
+	}
+
+	w := &waiter{ready: make(chan struct{})}
+	l.queue = append(l.queue, w)
+	l.mu.Unlock()
+
+	timer := time.NewTimer(l.queueWait)
+	defer timer.Stop()
+
+	select {
+	case <-w.ready:
+		return l.releaser(), nil
+	case <-timer.C:
+		err = ErrOverloaded
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// the slot may be granted right after the wait is over.
+	if w.granted {
+		return l.releaser(), nil
+	}
+
+	for i := range l.queue {
+		if l.queue[i] == w {
+			l.queue = append(l.queue[:i], l.queue[i+1:]...)
+			break
+		}
+	}
+
+	return nil, err
+}
+
+// releaser creates the release function of an admitted request.
+func (l *Limiter) releaser() func() {
+	start := l.now()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			latency := l.now().Sub(start)
+
+			l.mu.Lock()
+			defer l.mu.Unlock()
+
+			l.inFlight--
+			if l.adaptive != nil {
+				l.limit = l.adaptive.observe(latency, l.limit, l.maxLimit)
+			}
+
+			l.dispatch()
+		})
+	}
+}
+
+// dispatch admits the queued requests in order while there are free slots.
+// The caller must hold the lock.
+func (l *Limiter) dispatch() {
+	for l.inFlight < l.limit && len(l.queue) > 0 {
+		w := l.queue[0]
+		l.queue[0] = nil
+		l.queue = l.queue[1:]
+
+		w.granted = true
+		l.inFlight++
+		close(w.ready)
+	}
+}
+
+// Middleware creates a middleware that limits the in-flight requests of all
+// the routes it is applied to together. The shed requests get a 503 Service
+// Unavailable error with the Retry-After header.
+func (l *Limiter) Middleware() mux.Middleware {
+	return func(handler mux.Handler) mux.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) error {
+			return l.serve(handler, w, r)
+		}
+
+		return mux.HandlerFunc(fn)
+	}
+}
+
+// serve serves the request by the handler if the request is admitted.
+func (l *Limiter) serve(handler mux.Handler, w http.ResponseWriter, r *http.Request) error {
+	release, err := l.Acquire(r.Context())
+	if err != nil {
+		w.Header().Set(HeaderRetry, ceilSeconds(l.retryAfter))
+
+		httpErr := mux.NewError(http.StatusServiceUnavailable, "overloaded", "server is overloaded, please try again later")
+		httpErr.Cause = err
+		return httpErr
+	}
+
+	defer release()
+	return handler.ServeHTTP(w, r)
+}
+
+// PerRoute creates a middleware that limits the in-flight requests of each
+// route separately, each route has its own Limiter created with the given
+// limit and options. The route is the method and the matched path pattern,
+// see mux.State. The non-standard methods share the limiter of the route.
+func PerRoute(limit int, options ...Option) mux.Middleware {
+	var (
+		mu       sync.Mutex
+		limiters = make(map[string]*Limiter)
+	)
+
+	return func(handler mux.Handler) mux.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) error {
+			state, err := mux.GetState(r.Context())
+			if err != nil {
+				return mux.NewShutdownError(err.Error())
+			}
+
+			method := r.Method
+			if !methods[method] {
+				method = otherMethod
+			}
+
+			key := method + " " + state.Route
+
+			mu.Lock()
+			l, exist := limiters[key]
+			if !exist {
+				l = New(limit, options...)
+				limiters[key] = l
+			}
+			mu.Unlock()
+
+			return l.serve(handler, w, r)
+		}
+
+		return mux.HandlerFunc(fn)
+	}
+}
+
+// ceilSeconds formats the duration as the number of seconds rounded up.
+func ceilSeconds(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
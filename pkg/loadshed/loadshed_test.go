@@ -0,0 +1,243 @@
+package loadshed
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/josestg/justforfun/pkg/mux"
+)
+
+// queued returns the number of the queued requests.
+func (l *Limiter) queued() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.queue)
+}
+
+// waitQueued waits until n requests are queued.
+func waitQueued(t *testing.T, l *Limiter, n int) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for l.queued() != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("expecting %d queued requests but got %d", n, l.queued())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestLimiter_Acquire(t *testing.T) {
+	l := New(2)
+
+	r1, err := l.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("expecting nil but got %v", err)
+	}
+
+	if _, err := l.Acquire(context.Background()); err != nil {
+		t.Fatalf("expecting nil but got %v", err)
+	}
+
+	if _, err := l.Acquire(context.Background()); !errors.Is(err, ErrOverloaded) {
+		t.Fatalf("expecting error %v but got %v", ErrOverloaded, err)
+	}
+
+	r1()
+	r1()
+
+	if got := l.InFlight(); got != 1 {
+		t.Fatalf("expecting released once, in-flight 1 but got %d", got)
+	}
+
+	if _, err := l.Acquire(context.Background()); err != nil {
+		t.Fatalf("expecting nil after release but got %v", err)
+	}
+}
+
+func TestLimiter_Queue(t *testing.T) {
+	l := New(1, WithQueue(1, time.Second))
+
+	release, err := l.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("expecting nil but got %v", err)
+	}
+
+	admitted := make(chan error, 1)
+	go func() {
+		release, err := l.Acquire(context.Background())
+		if err == nil {
+			release()
+		}
+		admitted <- err
+	}()
+
+	waitQueued(t, l, 1)
+
+	if _, err := l.Acquire(context.Background()); !errors.Is(err, ErrOverloaded) {
+		t.Fatalf("expecting full queue error %v but got %v", ErrOverloaded, err)
+	}
+
+	release()
+
+	if err := <-admitted; err != nil {
+		t.Fatalf("expecting the queued request is admitted but got %v", err)
+	}
+
+	if got := l.InFlight(); got != 0 {
+		t.Fatalf("expecting in-flight 0 but got %d", got)
+	}
+}
+
+func TestLimiter_QueueWait(t *testing.T) {
+	l := New(1, WithQueue(1, 10*time.Millisecond))
+
+	if _, err := l.Acquire(context.Background()); err != nil {
+		t.Fatalf("expecting nil but got %v", err)
+	}
+
+	if _, err := l.Acquire(context.Background()); !errors.Is(err, ErrOverloaded) {
+		t.Fatalf("expecting wait error %v but got %v", ErrOverloaded, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := l.Acquire(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expecting error %v but got %v", context.Canceled, err)
+	}
+
+	if got := l.queued(); got != 0 {
+		t.Fatalf("expecting the given up requests leave the queue but got %d", got)
+	}
+}
+
+func TestLimiter_Middleware(t *testing.T) {
+	l := New(1, WithRetryAfter(1500*time.Millisecond))
+
+	entered := make(chan struct{})
+	unblock := make(chan struct{})
+
+	router := mux.NewRouter(nil)
+	router.Handle("GET /slow", mux.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		close(entered)
+		<-unblock
+		return nil
+	}), l.Middleware())
+
+	done := make(chan int)
+	go func() {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/slow", nil))
+		done <- rec.Code
+	}()
+
+	<-entered
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/slow", nil))
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expecting status %d but got %d", http.StatusServiceUnavailable, rec.Code)
+	}
+
+	if got := rec.Header().Get(HeaderRetry); got != "2" {
+		t.Fatalf("expecting Retry-After 2 but got %q", got)
+	}
+
+	close(unblock)
+	if code := <-done; code != http.StatusOK {
+		t.Fatalf("expecting status %d but got %d", http.StatusOK, code)
+	}
+}
+
+func TestPerRoute(t *testing.T) {
+	entered := make(chan struct{})
+	unblock := make(chan struct{})
+
+	router := mux.NewRouter(nil, PerRoute(1))
+	router.Handle("GET /slow", mux.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		close(entered)
+		<-unblock
+		return nil
+	}))
+	router.Handle("GET /fast", mux.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		return nil
+	}))
+
+	done := make(chan struct{})
+	go func() {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/slow", nil))
+		close(done)
+	}()
+
+	<-entered
+
+	tests := []struct {
+		path   string
+		status int
+	}{
+		{"/fast", http.StatusOK},
+		{"/slow", http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+		if rec.Code != tt.status {
+			t.Errorf("%s: expecting status %d but got %d", tt.path, tt.status, rec.Code)
+		}
+	}
+
+	close(unblock)
+	<-done
+}
+
+func TestPerRoute_OtherMethods(t *testing.T) {
+	entered := make(chan struct{})
+	unblock := make(chan struct{})
+
+	router := mux.NewRouter(nil, PerRoute(1))
+	router.Handle("/any", mux.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		if r.Method == "FOO" {
+			close(entered)
+			<-unblock
+		}
+		return nil
+	}))
+
+	done := make(chan struct{})
+	go func() {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("FOO", "/any", nil))
+		close(done)
+	}()
+
+	<-entered
+
+	// the made up methods share a limiter, so they can not grow the limiters.
+	tests := []struct {
+		method string
+		status int
+	}{
+		{http.MethodGet, http.StatusOK},
+		{"BAR", http.StatusServiceUnavailable},
+		{"FOO", http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(tt.method, "/any", nil))
+
+		if rec.Code != tt.status {
+			t.Errorf("%s: expecting status %d but got %d", tt.method, tt.status, rec.Code)
+		}
+	}
+
+	close(unblock)
+	<-done
+}
@@ -0,0 +1,152 @@
+package logx
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Field is a key/value pair of the log entry.
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// Entry is a single log entry.
+type Entry struct {
+	Time    time.Time
+	Level   Level
+	Message string
+	Fields  []Field
+}
+
+// Encoder knows how to encode the log entry into a single line.
+type Encoder interface {
+	// Encode encodes the entry into buf without the trailing new line.
+	Encode(buf *bytes.Buffer, e *Entry)
+}
+
+// JSONEncoder encodes the entry as a JSON object, the fields are written in
+// order after the time, level and msg keys.
+type JSONEncoder struct{}
+
+func (JSONEncoder) Encode(buf *bytes.Buffer, e *Entry) {
+	buf.WriteString(`{"time":`)
+	writeJSON(buf, e.Time.Format(time.RFC3339Nano))
+	buf.WriteString(`,"level":`)
+	writeJSON(buf, e.Level.String())
+	buf.WriteString(`,"msg":`)
+	writeJSON(buf, e.Message)
+
+	for _, f := range e.Fields {
+		buf.WriteByte(',')
+		writeJSON(buf, f.Key)
+		buf.WriteByte(':')
+		writeJSON(buf, jsonValue(f.Value))
+	}
+
+	buf.WriteByte('}')
+}
+
+// writeJSON writes the JSON encoding of v, or its quoted text if v can not be encoded.
+func writeJSON(buf *bytes.Buffer, v interface{}) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		b, _ = json.Marshal(fmt.Sprintf("%+v", v))
+	}
+	buf.Write(b)
+}
+
+// jsonValue converts the values that have no useful JSON encoding.
+func jsonValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case error:
+		return v.Error()
+	case time.Duration:
+		return v.String()
+	default:
+		return v
+	}
+}
+
+// TextEncoder encodes the entry as a human-readable line, for example:
+//
+// 	2021-12-01T10:00:00.000000+07:00 INFO  request completed  status=200 route=/v1/healths
+//
+// The values that contain spaces, quotes or '=' are quoted.
+type TextEncoder struct{}
+
+// textTimeFormat is the time layout of the TextEncoder, it has fixed width.
+const textTimeFormat = "2006-01-02T15:04:05.000000Z07:00"
+
+func (TextEncoder) Encode(buf *bytes.Buffer, e *Entry) {
+	buf.WriteString(e.Time.Format(textTimeFormat))
+	buf.WriteByte(' ')
+	buf.WriteString(fmt.Sprintf("%-5s", strings.ToUpper(e.Level.String())))
+	buf.WriteByte(' ')
+	buf.WriteString(e.Message)
+
+	for i, f := range e.Fields {
+		if i == 0 {
+			buf.WriteString("  ")
+		} else {
+			buf.WriteByte(' ')
+		}
+
+		buf.WriteString(f.Key)
+		buf.WriteByte('=')
+		buf.WriteString(textValue(f.Value))
+	}
+}
+
+// textValue formats the value, and quotes it when needed.
+func textValue(v interface{}) string {
+	var s string
+	switch v := v.(type) {
+	case string:
+		s = v
+	case error:
+		s = v.Error()
+	case time.Time:
+		s = v.Format(time.RFC3339Nano)
+	default:
+		s = fmt.Sprintf("%+v", v)
+	}
+
+	if needsQuote(s) {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// needsQuote reports whether s is empty or contains a space, a quote, '=' or
+// a non-printable character.
+func needsQuote(s string) bool {
+	if s == "" {
+		return true
+	}
+
+	for _, r := range s {
+		if unicode.IsSpace(r) || r == '"' || r == '=' || !unicode.IsPrint(r) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// NewEncoder creates the encoder of the format name: json or text.
+func NewEncoder(format string) (Encoder, error) {
+	switch strings.ToLower(strings.TrimSpace(format)) {
+	case "json", "":
+		return JSONEncoder{}, nil
+	case "text":
+		return TextEncoder{}, nil
+	default:
+		return nil, fmt.Errorf("logx: unknown format %q", format)
+	}
+}
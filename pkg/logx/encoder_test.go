@@ -0,0 +1,63 @@
+package logx
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestJSONEncoder(t *testing.T) {
+	var buf bytes.Buffer
+	JSONEncoder{}.Encode(&buf, &Entry{
+		Time:    testTime,
+		Level:   LevelError,
+		Message: `say "hi"`,
+		Fields: []Field{
+			{Key: "error", Value: errors.New("boom")},
+			{Key: "timeout", Value: 25 * time.Second},
+			{Key: "tags", Value: []string{"a", "b"}},
+			{Key: "func", Value: func() {}},
+		},
+	})
+
+	want := `{"time":"2021-12-01T10:00:00Z","level":"error","msg":"say \"hi\"","error":"boom","timeout":"25s","tags":["a","b"],"func":`
+	if got := buf.String(); len(got) < len(want) || got[:len(want)] != want {
+		t.Fatalf("expecting prefix\n%s\nbut got\n%s", want, got)
+	}
+}
+
+func TestTextEncoder(t *testing.T) {
+	var buf bytes.Buffer
+	TextEncoder{}.Encode(&buf, &Entry{
+		Time:    testTime,
+		Level:   LevelWarn,
+		Message: "request completed",
+		Fields: []Field{
+			{Key: "status", Value: 404},
+			{Key: "error", Value: errors.New("user not found")},
+			{Key: "route", Value: "/v1/users/{id}"},
+			{Key: "empty", Value: ""},
+			{Key: "query", Value: "a=b"},
+		},
+	})
+
+	want := `2021-12-01T10:00:00.000000Z WARN  request completed  status=404 error="user not found" route=/v1/users/{id} empty="" query="a=b"`
+	if got := buf.String(); got != want {
+		t.Fatalf("expecting\n%s\nbut got\n%s", want, got)
+	}
+}
+
+func TestNewEncoder(t *testing.T) {
+	if enc, err := NewEncoder("text"); err != nil || enc != (TextEncoder{}) {
+		t.Fatalf("expecting text encoder but got %v %v", enc, err)
+	}
+
+	if enc, err := NewEncoder(""); err != nil || enc != (JSONEncoder{}) {
+		t.Fatalf("expecting json encoder but got %v %v", enc, err)
+	}
+
+	if _, err := NewEncoder("xml"); err == nil {
+		t.Fatalf("expecting error for unknown format")
+	}
+}
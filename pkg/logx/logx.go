@@ -0,0 +1,219 @@
+// Package logx provides a structured leveled logger.
+//
+// The log entries have a level, a message and key/value fields, and are
+// written one per line by an Encoder. The child loggers created by Logger.With
+// carry their fields into every entry, for example the request-scoped fields:
+//
+// 	reqLogger := logger.With("request_id", id, "route", route)
+// 	reqLogger.Info("request completed", "status", 200)
+package logx
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Level is the severity of the log entry.
+type Level int8
+
+// Log levels.
+const (
+	LevelDebug Level = iota - 1
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	case LevelError:
+		return "error"
+	default:
+		return fmt.Sprintf("level(%d)", l)
+	}
+}
+
+// ParseLevel parses the level name, it is case-insensitive.
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "debug":
+		return LevelDebug, nil
+	case "info", "":
+		return LevelInfo, nil
+	case "warn", "warning":
+		return LevelWarn, nil
+	case "error":
+		return LevelError, nil
+	default:
+		return LevelInfo, fmt.Errorf("logx: unknown level %q", s)
+	}
+}
+
+// badKey is the key of the value that has no key.
+const badKey = "!BADKEY"
+
+// Option is an option type that can be used to customize the Logger.
+type Option func(l *Logger)
+
+// WithLevel sets the minimum level of the written entries, the default is LevelInfo.
+func WithLevel(level Level) Option {
+	return func(l *Logger) {
+		l.level = level
+	}
+}
+
+// WithEncoder sets the entry encoder, the default is JSONEncoder.
+func WithEncoder(enc Encoder) Option {
+	return func(l *Logger) {
+		l.encoder = enc
+	}
+}
+
+// WithClock sets the time source of the entries, it is useful for testing.
+func WithClock(now func() time.Time) Option {
+	return func(l *Logger) {
+		l.now = now
+	}
+}
+
+// output is the writer shared by a Logger and its children,
+// so the lines are not interleaved.
+type output struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// Logger is a structured leveled logger. It is safe for concurrent use.
+// The methods of the nil Logger do nothing.
+type Logger struct {
+	out     *output
+	level   Level
+	encoder Encoder
+	now     func() time.Time
+	fields  []Field
+}
+
+// New creates a new Logger that writes into w.
+func New(w io.Writer, options ...Option) *Logger {
+	l := Logger{
+		out:     &output{w: w},
+		level:   LevelInfo,
+		encoder: JSONEncoder{},
+		now:     time.Now,
+	}
+
+	for _, fn := range options {
+		fn(&l)
+	}
+
+	return &l
+}
+
+// With creates a child logger that adds the given key/value pairs to each entry.
+func (l *Logger) With(keyvals ...interface{}) *Logger {
+	if l == nil {
+		return nil
+	}
+
+	child := *l
+	child.fields = make([]Field, 0, len(l.fields)+len(keyvals)/2)
+	child.fields = append(child.fields, l.fields...)
+	child.fields = appendFields(child.fields, keyvals)
+	return &child
+}
+
+// Enabled reports whether the entries of the level are written.
+func (l *Logger) Enabled(level Level) bool {
+	return l != nil && level >= l.level
+}
+
+// Log writes an entry with the given level, message and key/value pairs.
+// The keys should be strings, a value without key is written under "!BADKEY".
+func (l *Logger) Log(level Level, msg string, keyvals ...interface{}) {
+	if !l.Enabled(level) {
+		return
+	}
+
+	e := Entry{
+		Time:    l.now(),
+		Level:   level,
+		Message: msg,
+		Fields:  appendFields(append([]Field(nil), l.fields...), keyvals),
+	}
+
+	var buf bytes.Buffer
+	l.encoder.Encode(&buf, &e)
+	buf.WriteByte('\n')
+
+	l.out.mu.Lock()
+	defer l.out.mu.Unlock()
+	_, _ = l.out.w.Write(buf.Bytes())
+}
+
+// Debug writes a debug entry.
+func (l *Logger) Debug(msg string, keyvals ...interface{}) { l.Log(LevelDebug, msg, keyvals...) }
+
+// Info writes an info entry.
+func (l *Logger) Info(msg string, keyvals ...interface{}) { l.Log(LevelInfo, msg, keyvals...) }
+
+// Warn writes a warn entry.
+func (l *Logger) Warn(msg string, keyvals ...interface{}) { l.Log(LevelWarn, msg, keyvals...) }
+
+// Error writes an error entry.
+func (l *Logger) Error(msg string, keyvals ...interface{}) { l.Log(LevelError, msg, keyvals...) }
+
+// Printf writes an info entry with the formatted message, so the Logger can be
+// used as the printer of the packages that print free-form text, such as
+// mux.Printer and sqlize.Printer.
+func (l *Logger) Printf(format string, args ...interface{}) {
+	if !l.Enabled(LevelInfo) {
+		return
+	}
+	l.Log(LevelInfo, strings.TrimRight(fmt.Sprintf(format, args...), "\n"))
+}
+
+// appendFields appends the key/value pairs into fields.
+func appendFields(fields []Field, keyvals []interface{}) []Field {
+	for i := 0; i < len(keyvals); i += 2 {
+		if i+1 == len(keyvals) {
+			fields = append(fields, Field{Key: badKey, Value: keyvals[i]})
+			break
+		}
+
+		key, ok := keyvals[i].(string)
+		if !ok {
+			key = fmt.Sprint(keyvals[i])
+		}
+
+		fields = append(fields, Field{Key: key, Value: keyvals[i+1]})
+	}
+	return fields
+}
+
+// contextKey is the type of the context keys of this package.
+type contextKey int
+
+const loggerKey = contextKey(0)
+
+// ContextWithLogger returns a copy of ctx that holds the logger.
+func ContextWithLogger(ctx context.Context, l *Logger) context.Context {
+	return context.WithValue(ctx, loggerKey, l)
+}
+
+// FromContext returns the logger in ctx, or nil if there is none.
+// Since the nil Logger does nothing, the result can be used directly.
+func FromContext(ctx context.Context) *Logger {
+	l, _ := ctx.Value(loggerKey).(*Logger)
+	return l
+}
@@ -0,0 +1,118 @@
+package logx
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testTime = time.Date(2021, 12, 1, 10, 0, 0, 0, time.UTC)
+
+func newTestLogger(buf *bytes.Buffer, options ...Option) *Logger {
+	options = append([]Option{WithClock(func() time.Time { return testTime })}, options...)
+	return New(buf, options...)
+}
+
+func TestLogger_Level(t *testing.T) {
+	var buf bytes.Buffer
+	l := newTestLogger(&buf, WithLevel(LevelWarn))
+
+	l.Debug("debug")
+	l.Info("info")
+	l.Warn("warn")
+	l.Error("error")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expecting 2 lines but got %d: %q", len(lines), buf.String())
+	}
+
+	if !strings.Contains(lines[0], `"level":"warn"`) || !strings.Contains(lines[1], `"level":"error"`) {
+		t.Fatalf("unexpected lines %q", lines)
+	}
+}
+
+func TestLogger_With(t *testing.T) {
+	var buf bytes.Buffer
+	parent := newTestLogger(&buf).With("app", "test")
+	child := parent.With("request_id", "abc")
+
+	child.Info("child", "status", 200)
+	parent.Info("parent")
+
+	want := `{"time":"2021-12-01T10:00:00Z","level":"info","msg":"child","app":"test","request_id":"abc","status":200}` + "\n" +
+		`{"time":"2021-12-01T10:00:00Z","level":"info","msg":"parent","app":"test"}` + "\n"
+
+	if got := buf.String(); got != want {
+		t.Fatalf("expecting\n%s\nbut got\n%s", want, got)
+	}
+}
+
+func TestLogger_BadKeyvals(t *testing.T) {
+	var buf bytes.Buffer
+	newTestLogger(&buf).Info("msg", 1, "one", "dangling")
+
+	want := `{"time":"2021-12-01T10:00:00Z","level":"info","msg":"msg","1":"one","!BADKEY":"dangling"}` + "\n"
+	if got := buf.String(); got != want {
+		t.Fatalf("expecting %s but got %s", want, got)
+	}
+}
+
+func TestLogger_Printf(t *testing.T) {
+	var buf bytes.Buffer
+	newTestLogger(&buf, WithEncoder(TextEncoder{})).Printf("server: listening on %s\n", ":8000")
+
+	want := "2021-12-01T10:00:00.000000Z INFO  server: listening on :8000\n"
+	if got := buf.String(); got != want {
+		t.Fatalf("expecting %q but got %q", want, got)
+	}
+}
+
+func TestLogger_Nil(t *testing.T) {
+	var l *Logger
+	l.With("key", "value").Error("nothing", "error", errors.New("boom"))
+	l.Printf("nothing")
+
+	if l.Enabled(LevelError) {
+		t.Fatalf("expecting nil logger is disabled")
+	}
+
+	if FromContext(context.Background()) != nil {
+		t.Fatalf("expecting no logger in empty context")
+	}
+
+	var buf bytes.Buffer
+	want := newTestLogger(&buf)
+	if got := FromContext(ContextWithLogger(context.Background(), want)); got != want {
+		t.Fatalf("expecting the logger in context")
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		s     string
+		level Level
+		err   bool
+	}{
+		{"debug", LevelDebug, false},
+		{"INFO", LevelInfo, false},
+		{"", LevelInfo, false},
+		{"warning", LevelWarn, false},
+		{"error", LevelError, false},
+		{"fatal", LevelInfo, true},
+	}
+
+	for _, tt := range tests {
+		level, err := ParseLevel(tt.s)
+		if (err != nil) != tt.err {
+			t.Errorf("%q: expecting error %v but got %v", tt.s, tt.err, err)
+		}
+
+		if level != tt.level {
+			t.Errorf("%q: expecting level %s but got %s", tt.s, tt.level, level)
+		}
+	}
+}
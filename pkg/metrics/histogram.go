@@ -0,0 +1,127 @@
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+)
+
+// DefaultBuckets are the default histogram buckets, tailored to measure
+// the response time of a network service in seconds.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// histogramSeries is a single labeled histogram.
+type histogramSeries struct {
+	values []string
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// Histogram counts the observations in the configurable buckets.
+type Histogram struct {
+	desc
+	buckets []float64
+
+	mu     sync.Mutex
+	series map[string]*histogramSeries
+}
+
+// NewHistogram creates a new Histogram with the given upper bounds and label names.
+// If buckets is empty, DefaultBuckets is used. The +Inf bucket is always added.
+// It panics if the name, the labels or the buckets are invalid.
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+
+	upper := make([]float64, 0, len(buckets))
+	for i, b := range buckets {
+		if i > 0 && b <= buckets[i-1] {
+			panic(fmt.Sprintf("metrics: histogram %q buckets must be in increasing order", name))
+		}
+
+		if !math.IsInf(b, +1) {
+			upper = append(upper, b)
+		}
+	}
+
+	return &Histogram{
+		desc:    newDesc(name, help, TypeHistogram, labels),
+		buckets: upper,
+		series:  make(map[string]*histogramSeries),
+	}
+}
+
+// Observe adds the observation v into the histogram of the given label values.
+func (h *Histogram) Observe(v float64, values ...string) {
+	key := h.key(values)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s, exist := h.series[key]
+	if !exist {
+		s = &histogramSeries{
+			values: append([]string(nil), values...),
+			counts: make([]uint64, len(h.buckets)),
+		}
+		h.series[key] = s
+	}
+
+	// the counts are stored per bucket, and accumulated on collecting.
+	if i := sort.SearchFloat64s(h.buckets, v); i < len(h.buckets) {
+		s.counts[i]++
+	}
+
+	s.count++
+	s.sum += v
+}
+
+// Count returns the number of observations of the given label values.
+func (h *Histogram) Count(values ...string) uint64 {
+	key := h.key(values)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if s, exist := h.series[key]; exist {
+		return s.count
+	}
+	return 0
+}
+
+func (h *Histogram) Collect() []Family {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	keys := make([]string, 0, len(h.series))
+	for key := range h.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	samples := make([]Sample, 0, len(keys)*(len(h.buckets)+3))
+	for _, key := range keys {
+		s := h.series[key]
+
+		var cumulative uint64
+		for i, upper := range h.buckets {
+			cumulative += s.counts[i]
+			samples = append(samples, Sample{
+				Name:   h.name + "_bucket",
+				Labels: h.pairs(s.values, Label{Name: "le", Value: formatFloat(upper)}),
+				Value:  float64(cumulative),
+			})
+		}
+
+		samples = append(samples,
+			Sample{Name: h.name + "_bucket", Labels: h.pairs(s.values, Label{Name: "le", Value: "+Inf"}), Value: float64(s.count)},
+			Sample{Name: h.name + "_sum", Labels: h.pairs(s.values), Value: s.sum},
+			Sample{Name: h.name + "_count", Labels: h.pairs(s.values), Value: float64(s.count)},
+		)
+	}
+
+	return []Family{{Name: h.name, Help: h.help, Type: TypeHistogram, Samples: samples}}
+}
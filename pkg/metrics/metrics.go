@@ -0,0 +1,170 @@
+// Package metrics provides counters, gauges and histograms with labels,
+// and exposes them in the Prometheus text exposition format.
+package metrics
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Type is the metric type.
+type Type string
+
+// Metric types.
+const (
+	TypeCounter   Type = "counter"
+	TypeGauge     Type = "gauge"
+	TypeHistogram Type = "histogram"
+	TypeUntyped   Type = "untyped"
+)
+
+var (
+	metricNameRe = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+	labelNameRe  = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+)
+
+// Label is a label name and value pair.
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Sample is a single value of a metric family.
+// The Name is the family name with its suffix, for example `_bucket` for histogram.
+type Sample struct {
+	Name   string
+	Labels []Label
+	Value  float64
+}
+
+// Family is a group of samples that share the name, help and type.
+type Family struct {
+	Name    string
+	Help    string
+	Type    Type
+	Samples []Sample
+}
+
+// Collector knows how to collect the metric families.
+type Collector interface {
+	// Collect returns the current state of the metric families.
+	Collect() []Family
+}
+
+// CollectorFunc is an adapter to allow the use of ordinary functions as Collector.
+type CollectorFunc func() []Family
+
+// Collect calls f().
+func (f CollectorFunc) Collect() []Family {
+	return f()
+}
+
+// Registry holds the registered collectors.
+type Registry struct {
+	mu         sync.Mutex
+	collectors []Collector
+	names      map[string]struct{}
+}
+
+// NewRegistry creates a new empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		names: make(map[string]struct{}),
+	}
+}
+
+// Register registers the collector.
+// It returns an error if the collector has a family name that is already registered.
+func (r *Registry) Register(c Collector) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	families := c.Collect()
+	for _, f := range families {
+		if _, exist := r.names[f.Name]; exist {
+			return fmt.Errorf("metrics: duplicate metric name %q", f.Name)
+		}
+	}
+
+	for _, f := range families {
+		r.names[f.Name] = struct{}{}
+	}
+
+	r.collectors = append(r.collectors, c)
+	return nil
+}
+
+// MustRegister registers the collectors and panics on error.
+func (r *Registry) MustRegister(collectors ...Collector) {
+	for _, c := range collectors {
+		if err := r.Register(c); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// Gather collects the families of all collectors sorted by name.
+func (r *Registry) Gather() []Family {
+	r.mu.Lock()
+	collectors := append([]Collector(nil), r.collectors...)
+	r.mu.Unlock()
+
+	families := make([]Family, 0, len(collectors))
+	for _, c := range collectors {
+		families = append(families, c.Collect()...)
+	}
+
+	sort.SliceStable(families, func(i, j int) bool {
+		return families[i].Name < families[j].Name
+	})
+
+	return families
+}
+
+// desc describes a metric with labels.
+type desc struct {
+	name   string
+	help   string
+	typ    Type
+	labels []string
+}
+
+// newDesc creates a desc and panics if the name or labels are invalid.
+func newDesc(name, help string, typ Type, labels []string) desc {
+	if !metricNameRe.MatchString(name) {
+		panic(fmt.Sprintf("metrics: invalid metric name %q", name))
+	}
+
+	for _, label := range labels {
+		if !labelNameRe.MatchString(label) || strings.HasPrefix(label, "__") {
+			panic(fmt.Sprintf("metrics: invalid label name %q of %q", label, name))
+		}
+
+		if typ == TypeHistogram && label == "le" {
+			panic(fmt.Sprintf("metrics: label name \"le\" is reserved for histogram %q", name))
+		}
+	}
+
+	return desc{name: name, help: help, typ: typ, labels: append([]string(nil), labels...)}
+}
+
+// key creates the series key from the label values.
+// It panics if the number of values does not match the labels.
+func (d *desc) key(values []string) string {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metrics: %q expects %d label values but got %d", d.name, len(d.labels), len(values)))
+	}
+	return strings.Join(values, "\xff")
+}
+
+// pairs pairs the label names with the values.
+func (d *desc) pairs(values []string, extra ...Label) []Label {
+	labels := make([]Label, 0, len(values)+len(extra))
+	for i, v := range values {
+		labels = append(labels, Label{Name: d.labels[i], Value: v})
+	}
+	return append(labels, extra...)
+}
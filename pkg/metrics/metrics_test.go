@@ -0,0 +1,94 @@
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteText(t *testing.T) {
+	reg := NewRegistry()
+
+	requests := NewCounter("app_requests_total", "Total requests.\nWith newline.", "path")
+	temperature := NewGauge("app_temperature", "", "room")
+	latency := NewHistogram("app_latency_seconds", "Latency.", []float64{0.1, 1}, "path")
+	reg.MustRegister(requests, temperature, latency)
+
+	requests.Inc(`/a"b`)
+	requests.Add(2, "/")
+	temperature.Set(21.5, "kitchen")
+	temperature.Dec("kitchen")
+	latency.Observe(0.05, "/")
+	latency.Observe(0.5, "/")
+	latency.Observe(3, "/")
+
+	var buf bytes.Buffer
+	if err := reg.WriteText(&buf); err != nil {
+		t.Fatalf("expecting error nil but got %v", err)
+	}
+
+	expected := `# HELP app_latency_seconds Latency.
+# TYPE app_latency_seconds histogram
+app_latency_seconds_bucket{path="/",le="0.1"} 1
+app_latency_seconds_bucket{path="/",le="1"} 2
+app_latency_seconds_bucket{path="/",le="+Inf"} 3
+app_latency_seconds_sum{path="/"} 3.55
+app_latency_seconds_count{path="/"} 3
+# HELP app_requests_total Total requests.\nWith newline.
+# TYPE app_requests_total counter
+app_requests_total{path="/"} 2
+app_requests_total{path="/a\"b"} 1
+# TYPE app_temperature gauge
+app_temperature{room="kitchen"} 20.5
+`
+
+	if buf.String() != expected {
+		t.Fatalf("expecting exposition:\n%s\nbut got:\n%s", expected, buf.String())
+	}
+}
+
+func TestRegistry_Register(t *testing.T) {
+	reg := NewRegistry()
+	if err := reg.Register(NewCounter("dup", "")); err != nil {
+		t.Fatalf("expecting error nil but got %v", err)
+	}
+
+	if err := reg.Register(NewGauge("dup", "")); err == nil {
+		t.Fatalf("expecting duplicate name error")
+	}
+}
+
+func TestInvalidMetrics(t *testing.T) {
+	tests := map[string]func(){
+		"invalid name":          func() { NewCounter("1abc", "") },
+		"invalid label":         func() { NewGauge("abc", "", "a-b") },
+		"reserved le":           func() { NewHistogram("abc", "", nil, "le") },
+		"unordered buckets":     func() { NewHistogram("abc", "", []float64{1, 0.5}) },
+		"label values mismatch": func() { NewCounter("abc", "", "a").Inc() },
+		"negative counter":      func() { NewCounter("abc", "").Add(-1) },
+	}
+
+	for name, fn := range tests {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("expecting panic")
+				}
+			}()
+			fn()
+		})
+	}
+}
+
+func TestRuntimeCollector(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteText(&buf, NewRuntimeCollector().Collect()); err != nil {
+		t.Fatalf("expecting error nil but got %v", err)
+	}
+
+	for _, name := range []string{"go_goroutines ", "go_memstats_alloc_bytes ", "go_info{version="} {
+		if !strings.Contains(buf.String(), name) {
+			t.Fatalf("expecting %q in:\n%s", name, buf.String())
+		}
+	}
+}
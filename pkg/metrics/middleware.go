@@ -0,0 +1,90 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/josestg/justforfun/pkg/mux"
+)
+
+// unmatchedRoute is the route label of the requests that match no route,
+// so the unknown paths do not create a series each.
+const unmatchedRoute = "unmatched"
+
+// otherMethod is the method label of the non-standard methods, so a client
+// can not create a series for each method it makes up.
+const otherMethod = "OTHER"
+
+// methods are the methods that are labeled as they are.
+var methods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodConnect: true,
+	http.MethodOptions: true,
+	http.MethodTrace:   true,
+}
+
+// HTTPMetrics holds the metrics of the HTTP requests.
+type HTTPMetrics struct {
+	Requests *Counter
+	Duration *Histogram
+	InFlight *Gauge
+}
+
+// NewHTTPMetrics creates the HTTP request metrics and registers them into the registry.
+// If buckets is empty, DefaultBuckets is used for the duration histogram.
+func NewHTTPMetrics(reg *Registry, buckets ...float64) *HTTPMetrics {
+	m := HTTPMetrics{
+		Requests: NewCounter("http_requests_total", "Total number of HTTP requests.", "method", "route", "status"),
+		Duration: NewHistogram("http_request_duration_seconds", "Duration of HTTP requests in seconds.", buckets, "method", "route", "status"),
+		InFlight: NewGauge("http_requests_in_flight", "Number of HTTP requests currently being served.", "method", "route"),
+	}
+
+	reg.MustRegister(m.Requests, m.Duration, m.InFlight)
+	return &m
+}
+
+// Middleware creates a middleware that records the request count, the latency
+// and the in-flight requests, labeled by the method, the route pattern and
+// the response status. The non-standard methods are labeled as OTHER.
+//
+// The middleware must be a global middleware placed before the middlewares that
+// write the error responses, so it records the final status.
+func (m *HTTPMetrics) Middleware() mux.Middleware {
+	return func(handler mux.Handler) mux.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) error {
+			state, err := mux.GetState(r.Context())
+			if err != nil {
+				return mux.NewShutdownError(err.Error())
+			}
+
+			route := state.Route
+			if route == "" {
+				route = unmatchedRoute
+			}
+
+			method := r.Method
+			if !methods[method] {
+				method = otherMethod
+			}
+
+			m.InFlight.Inc(method, route)
+			defer func(start time.Time) {
+				m.InFlight.Dec(method, route)
+
+				status := strconv.Itoa(state.StatusCode)
+				m.Requests.Inc(method, route, status)
+				m.Duration.Observe(time.Since(start).Seconds(), method, route, status)
+			}(time.Now())
+
+			return handler.ServeHTTP(w, r)
+		}
+
+		return mux.HandlerFunc(fn)
+	}
+}
@@ -0,0 +1,87 @@
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/josestg/justforfun/pkg/mux"
+)
+
+func TestHTTPMetrics(t *testing.T) {
+	reg := NewRegistry()
+	m := NewHTTPMetrics(reg)
+
+	var inFlight float64
+	router := mux.NewRouter(nil, m.Middleware())
+	router.Handle("GET /users/{id}", mux.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		inFlight = m.InFlight.Value(http.MethodGet, "/users/{id}")
+		if mux.Param(r.Context(), "id") == "0" {
+			return mux.NewError(http.StatusNotFound, "not_found", "user not found")
+		}
+		return nil
+	}))
+	router.Handle("GET /failed", mux.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		return errors.New("boom")
+	}))
+
+	for _, path := range []string{"/users/1", "/users/2", "/users/0", "/unknown", "/failed"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	if inFlight != 1 {
+		t.Fatalf("expecting in-flight 1 during request but got %v", inFlight)
+	}
+
+	if v := m.InFlight.Value(http.MethodGet, "/users/{id}"); v != 0 {
+		t.Fatalf("expecting in-flight 0 after requests but got %v", v)
+	}
+
+	tests := []struct {
+		route  string
+		status string
+		count  float64
+	}{
+		{"/users/{id}", "200", 2},
+		{"/users/{id}", "404", 1},
+		{"unmatched", "404", 1},
+		{"/failed", "500", 1},
+	}
+
+	for _, tt := range tests {
+		if got := m.Requests.Value(http.MethodGet, tt.route, tt.status); got != tt.count {
+			t.Errorf("%s %s: expecting count %v but got %v", tt.route, tt.status, tt.count, got)
+		}
+
+		if got := m.Duration.Count(http.MethodGet, tt.route, tt.status); float64(got) != tt.count {
+			t.Errorf("%s %s: expecting observations %v but got %v", tt.route, tt.status, tt.count, got)
+		}
+	}
+}
+
+func TestHTTPMetrics_OtherMethod(t *testing.T) {
+	reg := NewRegistry()
+	m := NewHTTPMetrics(reg)
+
+	router := mux.NewRouter(nil, m.Middleware())
+	router.Handle("/users", mux.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		return nil
+	}))
+
+	for _, method := range []string{http.MethodPost, "PROPFIND", "X-MADE-UP-1", "X-MADE-UP-2"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, "/users", nil))
+	}
+
+	if got := m.Requests.Value(http.MethodPost, "/users", "200"); got != 1 {
+		t.Fatalf("expecting count 1 of POST but got %v", got)
+	}
+
+	if got := m.Requests.Value("OTHER", "/users", "200"); got != 3 {
+		t.Fatalf("expecting count 3 of OTHER but got %v", got)
+	}
+
+	if got := m.Requests.Value("PROPFIND", "/users", "200"); got != 0 {
+		t.Fatalf("expecting no series of PROPFIND but got %v", got)
+	}
+}
@@ -0,0 +1,38 @@
+package metrics
+
+import (
+	"runtime"
+)
+
+// NewRuntimeCollector creates a Collector of the Go runtime statistics.
+func NewRuntimeCollector() Collector {
+	return CollectorFunc(func() []Family {
+		var m runtime.MemStats
+		runtime.ReadMemStats(&m)
+
+		gauge := func(name, help string, v float64) Family {
+			return Family{Name: name, Help: help, Type: TypeGauge, Samples: []Sample{{Name: name, Value: v}}}
+		}
+
+		counter := func(name, help string, v float64) Family {
+			return Family{Name: name, Help: help, Type: TypeCounter, Samples: []Sample{{Name: name, Value: v}}}
+		}
+
+		return []Family{
+			{
+				Name:    "go_info",
+				Help:    "Information about the Go environment.",
+				Type:    TypeGauge,
+				Samples: []Sample{{Name: "go_info", Labels: []Label{{Name: "version", Value: runtime.Version()}}, Value: 1}},
+			},
+			gauge("go_goroutines", "Number of goroutines that currently exist.", float64(runtime.NumGoroutine())),
+			gauge("go_memstats_alloc_bytes", "Number of bytes allocated and still in use.", float64(m.Alloc)),
+			counter("go_memstats_alloc_bytes_total", "Total number of bytes allocated, even if freed.", float64(m.TotalAlloc)),
+			gauge("go_memstats_sys_bytes", "Number of bytes obtained from system.", float64(m.Sys)),
+			gauge("go_memstats_heap_inuse_bytes", "Number of heap bytes that are in use.", float64(m.HeapInuse)),
+			gauge("go_memstats_heap_objects", "Number of allocated objects.", float64(m.HeapObjects)),
+			counter("go_gc_cycles_total", "Number of completed GC cycles.", float64(m.NumGC)),
+			counter("go_gc_pause_seconds_total", "Total GC stop-the-world pause time in seconds.", float64(m.PauseTotalNs)/1e9),
+		}
+	})
+}
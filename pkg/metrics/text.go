@@ -0,0 +1,71 @@
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// TextContentType is the media type of the Prometheus text exposition format.
+const TextContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+// WriteText writes the families of all registered collectors into w
+// in the Prometheus text exposition format.
+func (r *Registry) WriteText(w io.Writer) error {
+	return WriteText(w, r.Gather())
+}
+
+// WriteText writes the families into w in the Prometheus text exposition format.
+func WriteText(w io.Writer, families []Family) error {
+	bw := bufio.NewWriter(w)
+
+	for _, f := range families {
+		typ := f.Type
+		if typ == "" {
+			typ = TypeUntyped
+		}
+
+		if f.Help != "" {
+			bw.WriteString("# HELP " + f.Name + " " + helpEscaper.Replace(f.Help) + "\n")
+		}
+		bw.WriteString("# TYPE " + f.Name + " " + string(typ) + "\n")
+
+		for _, s := range f.Samples {
+			bw.WriteString(s.Name)
+			if len(s.Labels) != 0 {
+				bw.WriteByte('{')
+				for i, l := range s.Labels {
+					if i > 0 {
+						bw.WriteByte(',')
+					}
+					bw.WriteString(l.Name + `="` + labelEscaper.Replace(l.Value) + `"`)
+				}
+				bw.WriteByte('}')
+			}
+			bw.WriteString(" " + formatFloat(s.Value) + "\n")
+		}
+	}
+
+	return bw.Flush()
+}
+
+// formatFloat formats the value as the exposition format expects.
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, +1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
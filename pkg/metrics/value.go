@@ -0,0 +1,142 @@
+package metrics
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// valueSeries is a single labeled value.
+type valueSeries struct {
+	values []string
+	value  float64
+}
+
+// valueVec is a labeled value metric, the base of Counter and Gauge.
+type valueVec struct {
+	desc
+	mu     sync.Mutex
+	series map[string]*valueSeries
+}
+
+func newValueVec(name, help string, typ Type, labels []string) valueVec {
+	return valueVec{
+		desc:   newDesc(name, help, typ, labels),
+		series: make(map[string]*valueSeries),
+	}
+}
+
+// update applies fn to the value of the series.
+func (v *valueVec) update(values []string, fn func(value float64) float64) {
+	key := v.key(values)
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	s, exist := v.series[key]
+	if !exist {
+		s = &valueSeries{values: append([]string(nil), values...)}
+		v.series[key] = s
+	}
+
+	s.value = fn(s.value)
+}
+
+// get returns the value of the series.
+func (v *valueVec) get(values []string) float64 {
+	key := v.key(values)
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if s, exist := v.series[key]; exist {
+		return s.value
+	}
+	return 0
+}
+
+func (v *valueVec) Collect() []Family {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	keys := make([]string, 0, len(v.series))
+	for key := range v.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	samples := make([]Sample, 0, len(keys))
+	for _, key := range keys {
+		s := v.series[key]
+		samples = append(samples, Sample{Name: v.name, Labels: v.pairs(s.values), Value: s.value})
+	}
+
+	return []Family{{Name: v.name, Help: v.help, Type: v.typ, Samples: samples}}
+}
+
+// Counter is a labeled value that only goes up.
+type Counter struct {
+	valueVec
+}
+
+// NewCounter creates a new Counter with the given label names.
+// It panics if the name or the labels are invalid.
+func NewCounter(name, help string, labels ...string) *Counter {
+	return &Counter{valueVec: newValueVec(name, help, TypeCounter, labels)}
+}
+
+// Inc increments the counter of the given label values by 1.
+func (c *Counter) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Add adds v to the counter of the given label values.
+// It panics if v is negative.
+func (c *Counter) Add(v float64, values ...string) {
+	if v < 0 {
+		panic(fmt.Sprintf("metrics: counter %q can not decrease", c.name))
+	}
+
+	c.update(values, func(value float64) float64 { return value + v })
+}
+
+// Value returns the counter of the given label values.
+func (c *Counter) Value(values ...string) float64 {
+	return c.get(values)
+}
+
+// Gauge is a labeled value that can go up and down.
+type Gauge struct {
+	valueVec
+}
+
+// NewGauge creates a new Gauge with the given label names.
+// It panics if the name or the labels are invalid.
+func NewGauge(name, help string, labels ...string) *Gauge {
+	return &Gauge{valueVec: newValueVec(name, help, TypeGauge, labels)}
+}
+
+// Set sets the gauge of the given label values to v.
+func (g *Gauge) Set(v float64, values ...string) {
+	g.update(values, func(float64) float64 { return v })
+}
+
+// Add adds v to the gauge of the given label values.
+func (g *Gauge) Add(v float64, values ...string) {
+	g.update(values, func(value float64) float64 { return value + v })
+}
+
+// Inc increments the gauge of the given label values by 1.
+func (g *Gauge) Inc(values ...string) {
+	g.Add(1, values...)
+}
+
+// Dec decrements the gauge of the given label values by 1.
+func (g *Gauge) Dec(values ...string) {
+	g.Add(-1, values...)
+}
+
+// Value returns the gauge of the given label values.
+func (g *Gauge) Value(values ...string) float64 {
+	return g.get(values)
+}
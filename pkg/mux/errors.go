@@ -0,0 +1,164 @@
+package mux
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/josestg/justforfun/pkg/validate"
+)
+
+// ErrorHandler knows how to turn an error returned by the Handler into a
+// response. The ErrorHandler should also update the State.StatusCode, so the
+// middlewares can see the real status code.
+type ErrorHandler func(w http.ResponseWriter, r *http.Request, err error)
+
+// handledError marks the error has been written as a response by the ErrorHandler.
+type handledError struct {
+	err error
+}
+
+func (h *handledError) Error() string { return h.err.Error() }
+
+// Unwrap provides compatibility for Go 1.13 error chains.
+func (h *handledError) Unwrap() error { return h.err }
+
+// isHandledError returns true if the error has been handled by the ErrorHandler.
+func isHandledError(err error) bool {
+	var he *handledError
+	return errors.As(err, &he)
+}
+
+// Error is an HTTP error.
+//
+// The Status, Code, Detail and Fields are public and safe to be written as a
+// response, meanwhile the Cause is internal and only used for logging.
+type Error struct {
+	// Status is the HTTP status code.
+	Status int
+
+	// Code is a machine-readable error code, for example "user_not_found".
+	Code string
+
+	// Detail is a human-readable explanation for the client.
+	Detail string
+
+	// Fields holds the error messages for each invalid field.
+	Fields map[string][]string
+
+	// Cause is the internal error that causes this error.
+	Cause error
+}
+
+// NewError creates a new HTTP error.
+func NewError(status int, code string, detail string) *Error {
+	return &Error{
+		Status: status,
+		Code:   code,
+		Detail: detail,
+	}
+}
+
+// WrapError creates a new HTTP error that wraps the internal cause.
+func WrapError(cause error, status int, code string, detail string) *Error {
+	e := NewError(status, code, detail)
+	e.Cause = cause
+	return e
+}
+
+// Error implements the error interface.
+// The message contains the Cause, so it must not be written as a response.
+func (e *Error) Error() string {
+	msg := e.Detail
+	if msg == "" {
+		msg = http.StatusText(e.Status)
+	}
+
+	if e.Code != "" {
+		msg = e.Code + ": " + msg
+	}
+
+	if e.Cause != nil {
+		msg = msg + ": " + e.Cause.Error()
+	}
+
+	return msg
+}
+
+// Unwrap provides compatibility for Go 1.13 error chains.
+func (e *Error) Unwrap() error { return e.Cause }
+
+// ToError converts the given error into an HTTP error.
+//
+// If the error chain contains an *Error, that error is returned.
+// The validate.Errors is converted into 422 Unprocessable Entity with the field errors,
+// and the other errors, including the shutdown error, are converted into
+// 500 Internal Server Error without exposing the error message.
+func ToError(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		if e.Status == 0 {
+			c := *e
+			c.Status = http.StatusInternalServerError
+			return &c
+		}
+		return e
+	}
+
+	var fields validate.Errors
+	if errors.As(err, &fields) {
+		e := WrapError(err, http.StatusUnprocessableEntity, "validation_failed", "one or more fields are invalid")
+		e.Fields = fields
+		return e
+	}
+
+	return WrapError(err, http.StatusInternalServerError, "internal_error", "")
+}
+
+// ErrorResponse is the response body written by DefaultErrorHandler.
+type ErrorResponse struct {
+	Error  string              `json:"error"`
+	Code   string              `json:"code,omitempty"`
+	Fields map[string][]string `json:"fields,omitempty"`
+}
+
+// DefaultErrorHandler is the default ErrorHandler of the Router.
+// The error is converted by ToError and written as JSON.
+func DefaultErrorHandler(w http.ResponseWriter, r *http.Request, err error) {
+	e := ToError(err)
+
+	resp := ErrorResponse{
+		Error:  e.Detail,
+		Code:   e.Code,
+		Fields: e.Fields,
+	}
+
+	if resp.Error == "" {
+		resp.Error = http.StatusText(e.Status)
+	}
+
+	if state, stateErr := GetState(r.Context()); stateErr == nil {
+		state.Err = err
+	}
+
+	writeError(w, r, e.Status, &resp)
+}
+
+// writeError writes the given response as JSON and records the status code
+// into the request State.
+func writeError(w http.ResponseWriter, r *http.Request, status int, resp interface{}) {
+	b, err := json.Marshal(resp)
+	if err != nil {
+		status = http.StatusInternalServerError
+		b = []byte(`{"error":"Internal Server Error"}`)
+	}
+
+	if state, err := GetState(r.Context()); err == nil {
+		state.StatusCode = status
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	_, _ = w.Write(b)
+}
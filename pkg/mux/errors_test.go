@@ -0,0 +1,148 @@
+package mux
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/josestg/justforfun/pkg/validate"
+)
+
+func TestDefaultErrorHandler(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		status   int
+		expected ErrorResponse
+	}{
+		{
+			name:     "unknown error",
+			err:      errors.New("pq: connection refused"),
+			status:   http.StatusInternalServerError,
+			expected: ErrorResponse{Error: "Internal Server Error", Code: "internal_error"},
+		},
+		{
+			name:     "shutdown error",
+			err:      NewShutdownError("state is missing"),
+			status:   http.StatusInternalServerError,
+			expected: ErrorResponse{Error: "Internal Server Error", Code: "internal_error"},
+		},
+		{
+			name:     "http error",
+			err:      fmt.Errorf("find user: %w", WrapError(errors.New("sql: no rows"), http.StatusNotFound, "user_not_found", "user does not exist")),
+			status:   http.StatusNotFound,
+			expected: ErrorResponse{Error: "user does not exist", Code: "user_not_found"},
+		},
+		{
+			name:   "validation error",
+			err:    validate.Errors{"email": {"is required"}},
+			status: http.StatusUnprocessableEntity,
+			expected: ErrorResponse{
+				Error:  "one or more fields are invalid",
+				Code:   "validation_failed",
+				Fields: map[string][]string{"email": {"is required"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var state *State
+
+			// the status seen by the outer middleware must be the written one.
+			observer := func(handler Handler) Handler {
+				fn := func(w http.ResponseWriter, r *http.Request) error {
+					state, _ = GetState(r.Context())
+					return handler.ServeHTTP(w, r)
+				}
+				return HandlerFunc(fn)
+			}
+
+			router := NewRouter(make(ShutdownChannel, 1), observer)
+			router.Handle("/example", HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+				return tt.err
+			}))
+
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/example", nil))
+
+			if rec.Code != tt.status {
+				t.Fatalf("expecting status code: %d but got: %d", tt.status, rec.Code)
+			}
+
+			if state == nil || state.StatusCode != tt.status {
+				t.Fatalf("expecting state status code: %d but got: %+v", tt.status, state)
+			}
+
+			if state.Err == nil || state.Err.Error() != tt.err.Error() {
+				t.Fatalf("expecting state error: %v but got: %v", tt.err, state.Err)
+			}
+
+			var got ErrorResponse
+			if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+				t.Fatalf("expecting error nil but got %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Fatalf("expecting: %+v got: %+v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestRouter_SetErrorHandler(t *testing.T) {
+	router := NewRouter(nil)
+
+	calls := 0
+	router.SetErrorHandler(func(w http.ResponseWriter, r *http.Request, err error) {
+		calls++
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	rm := func(handler Handler) Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) error {
+			if err := handler.ServeHTTP(w, r); err == nil {
+				t.Errorf("expecting the error is still returned to the outer layers")
+			}
+			return nil
+		}
+		return HandlerFunc(fn)
+	}
+
+	router.Handle("/example", HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		return errors.New("boom")
+	}), rm)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/example", nil))
+
+	if rec.Code != http.StatusTeapot {
+		t.Fatalf("expecting status code: %d but got: %d", http.StatusTeapot, rec.Code)
+	}
+
+	if calls != 1 {
+		t.Fatalf("expecting error handler is called once but got %d", calls)
+	}
+}
+
+func TestError_Error(t *testing.T) {
+	cause := errors.New("sql: no rows")
+	err := WrapError(cause, http.StatusNotFound, "user_not_found", "user does not exist")
+
+	const expected = "user_not_found: user does not exist: sql: no rows"
+	if err.Error() != expected {
+		t.Fatalf("expecting %q but got %q", expected, err.Error())
+	}
+
+	if !errors.Is(err, cause) {
+		t.Fatalf("expecting the cause is in the error chain")
+	}
+
+	if got := NewError(http.StatusForbidden, "", "").Error(); got != "Forbidden" {
+		t.Fatalf("expecting status text but got %q", got)
+	}
+}
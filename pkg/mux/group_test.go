@@ -0,0 +1,91 @@
+package mux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestRouter_Group(t *testing.T) {
+	// Objective:
+	// Check the prefix and the execution order of nested groups.
+	//
+	// Expect:
+	// Global Middlewares = {gm}
+	// Group Middlewares = {v1}, nested group {admin}
+	// Router Middlewares = {rm}
+	// The Call Stack should be look like this:
+	// gm, v1, admin, rm, fn
+	callStack := make([]string, 0)
+
+	factory := func(name string) Middleware {
+		return func(handler Handler) Handler {
+			fn := func(w http.ResponseWriter, r *http.Request) error {
+				callStack = append(callStack, name)
+				return handler.ServeHTTP(w, r)
+			}
+			return HandlerFunc(fn)
+		}
+	}
+
+	router := NewRouter(nil, factory("gm"))
+	v1 := router.Group("/v1", factory("v1"))
+	admin := v1.Group("/admin/", factory("admin"))
+
+	fn := func(w http.ResponseWriter, r *http.Request) error {
+		callStack = append(callStack, "fn:"+Param(r.Context(), "id"))
+		return nil
+	}
+
+	admin.Handle("GET /users/{id}", HandlerFunc(fn), factory("rm"))
+	v1.Handle("GET /healths", HandlerFunc(fn))
+
+	tests := []struct {
+		path     string
+		status   int
+		expected []string
+	}{
+		{"/v1/admin/users/7", http.StatusOK, []string{"gm", "v1", "admin", "rm", "fn:7"}},
+		{"/v1/healths", http.StatusOK, []string{"gm", "v1", "fn:"}},
+		{"/admin/users/7", http.StatusNotFound, []string{"gm"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			callStack = callStack[:0]
+
+			// the group serves the same routes as the root router.
+			rec := httptest.NewRecorder()
+			admin.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if rec.Code != tt.status {
+				t.Fatalf("expecting status code: %d but got: %d", tt.status, rec.Code)
+			}
+
+			if !reflect.DeepEqual(tt.expected, callStack) {
+				t.Fatalf("expecting: %v got: %v", tt.expected, callStack)
+			}
+		})
+	}
+}
+
+func TestRouter_GroupSharesErrorHandler(t *testing.T) {
+	router := NewRouter(nil)
+	group := router.Group("/v1")
+
+	group.SetErrorHandler(func(w http.ResponseWriter, r *http.Request, err error) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	router.Handle("/fail", HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		return NewError(http.StatusBadRequest, "", "")
+	}))
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/fail", nil))
+
+	if rec.Code != http.StatusTeapot {
+		t.Fatalf("expecting status code: %d but got: %d", http.StatusTeapot, rec.Code)
+	}
+}
@@ -3,7 +3,10 @@ package mux
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
+	"strings"
 	"syscall"
 	"time"
 )
@@ -25,6 +28,36 @@ const StateKey = keyType(0)
 type State struct {
 	StatusCode     int
 	RequestCreated time.Time
+
+	// RequestID is the incoming X-Request-ID if it is valid,
+	// otherwise a generated one.
+	RequestID string
+
+	// Route is the path pattern of the matched route, for example
+	// `/v1/users/{id}`. It is empty if no route matches the path.
+	Route string
+
+	// WroteHeader, BytesWritten and TimeToFirstByte are recorded by
+	// the Router's ResponseWriter.
+	WroteHeader     bool
+	BytesWritten    int64
+	TimeToFirstByte time.Duration
+
+	// ContentEncoding, UncompressedBytes and CompressedBytes are recorded by
+	// a compression middleware. The UncompressedBytes is the body written by
+	// the handler, and the CompressedBytes is the encoded body.
+	ContentEncoding   string
+	UncompressedBytes int64
+	CompressedBytes   int64
+
+	// Timeout is the handler timeout set by the Timeout middleware,
+	// and TimedOut is true if the handler exceeded it.
+	Timeout  time.Duration
+	TimedOut bool
+
+	// Err is the error returned by the handler.
+	// It is recorded by the ErrorHandler for logging only.
+	Err error
 }
 
 // GetState gets the initial state form the given context.
@@ -94,54 +127,325 @@ func applyMiddleware(handler Handler, middlewares []Middleware) Handler {
 	return handler
 }
 
+// Router is a request multiplexer that matches the request against the
+// registered route patterns and calls the handler of the matched route.
+//
+// The route pattern has form `[METHOD ]PATH`, for example:
+//
+//	GET /v1/users/{id}    matches GET /v1/users/42 with param id=42.
+//	/files/{path...}      matches any method on /files/a/b with param path=a/b.
+//
+// If a static segment and a parameter both match, the static one wins, unless
+// only the route of the parameter serves the request method. For example, with
+// `GET /v1/users/me` and `DELETE /v1/users/{id}`, DELETE /v1/users/me is served
+// by the latter. If the request path matches a route but none of the matching
+// routes serves the method, the Router responds with 405 Method Not Allowed. The OPTIONS request to a
+// registered path is answered with 204 No Content and the Allow header, unless
+// the route registers its own OPTIONS handler. The global middlewares run for
+// these responses too, so a CORS middleware can answer the preflight requests.
+//
+// The request path that is not canonical, for example "/v1//users" or
+// "/v1/./users/../users", is redirected to its clean form with 301 Moved
+// Permanently, or 308 Permanent Redirect if the method is not GET or HEAD.
+//
+// The routes can be grouped by using Router.Group, the group shares the routing
+// tree, the global middlewares and the error handler with the root Router.
 type Router struct {
-	mux *http.ServeMux
-	sc  ShutdownChannel
-	gm  []Middleware
+	root *node
+	sc   ShutdownChannel
+	gm   []Middleware
+
+	notFound         Handler
+	methodNotAllowed Handler
+	options          Handler
+	redirect         Handler
+	errorHandler     ErrorHandler
+
+	// base is the root Router. For the root Router, base is itself.
+	base *Router
+
+	// prefix and group are the path prefix and the middlewares of the group.
+	prefix string
+	group  []Middleware
+
+	// routes holds the registered routes for introspection.
+	routes []*Route
 }
 
+// NewRouter creates a new Router with the given global middlewares.
 func NewRouter(channel ShutdownChannel, middleware ...Middleware) *Router {
-	mux := http.NewServeMux()
-	return &Router{
-		mux: mux,
-		sc:  channel,
-		gm:  middleware,
+	r := &Router{
+		root: newNode(),
+		sc:   channel,
+		gm:   middleware,
+
+		errorHandler: DefaultErrorHandler,
 	}
+
+	r.base = r
+	r.notFound = r.chain(HandlerFunc(notFound), r.gm)
+	r.methodNotAllowed = r.chain(HandlerFunc(methodNotAllowed), r.gm)
+	r.options = r.chain(HandlerFunc(options), r.gm)
+	r.redirect = r.chain(HandlerFunc(redirect), r.gm)
+	return r
 }
 
 // SignalShutdown sends a shutdown signal through the shutdown channel.
 func (r *Router) SignalShutdown() {
-	if r.sc != nil {
-		r.sc <- syscall.SIGTERM
+	if sc := r.base.sc; sc != nil {
+		sc <- syscall.SIGTERM
 	}
 }
 
-func (r *Router) Handle(pattern string, handler Handler, middleware ...Middleware) {
+// SetErrorHandler sets the handler that turns the errors returned by
+// the handlers into responses. If h is nil, DefaultErrorHandler is used.
+//
+// The error handler is shared by the root Router and all of its groups.
+func (r *Router) SetErrorHandler(h ErrorHandler) {
+	if h == nil {
+		h = DefaultErrorHandler
+	}
+	r.base.errorHandler = h
+}
+
+// Group creates a sub-router whose routes are prefixed by the given prefix and
+// wrapped by the given group middlewares.
+//
+// The group middlewares are executed after the global middlewares and before
+// the route middlewares. Groups can be nested, the prefix and the middlewares
+// of the nested group are appended to its parent's.
+//
+//	admin := router.Group("/v1/admin", auth)
+//	admin.Handle("GET /users", listUsers) // serves GET /v1/admin/users
+func (r *Router) Group(prefix string, middleware ...Middleware) *Router {
+	prefix = strings.TrimSuffix(prefix, "/")
+	if prefix != "" && !strings.HasPrefix(prefix, "/") {
+		panic(fmt.Sprintf("mux: group prefix must begin with '/': %q", prefix))
+	}
+
+	group := make([]Middleware, 0, len(r.group)+len(middleware))
+	group = append(group, r.group...)
+	group = append(group, middleware...)
+
+	return &Router{
+		base:   r.base,
+		prefix: r.prefix + prefix,
+		group:  group,
+	}
+}
+
+// Handle registers the handler for the given pattern.
+// The handler is wrapped by the route middlewares, then by the group middlewares,
+// and then by the global middlewares.
+//
+// The returned Route can be used to describe the route, for example:
+//
+//	router.Handle("GET /v1/users/{id}", h).Describe("Shows a user.").Returns(http.StatusOK, User{})
+//
+// Handle panics if the pattern is invalid or already registered.
+func (r *Router) Handle(pattern string, handler Handler, middleware ...Middleware) *Route {
+	method, path := parsePattern(pattern)
+	path = r.prefix + path
+
+	base := r.base
+
 	// wraps original handler with given middlewares.
-	handler = applyMiddleware(handler, middleware)
+	handler = base.chain(handler, middleware)
+	// wraps the wrapped handler with the group middlewares.
+	handler = base.chain(handler, r.group)
 	// wraps the wrapped original handler again with r.gm.
-	handler = applyMiddleware(handler, r.gm)
+	handler = base.chain(handler, base.gm)
 
-	fn := func(w http.ResponseWriter, req *http.Request) {
-		// create the initial state
-		s := State{
-			StatusCode:     http.StatusOK, // default status code
-			RequestCreated: time.Now(),
+	base.root.insert(method, path, handler)
+
+	route := &Route{
+		Method:     method,
+		Pattern:    path,
+		Middleware: middlewareNames(base.gm, r.group, middleware),
+	}
+
+	base.routes = append(base.routes, route)
+	return route
+}
+
+// chain applies the middlewares to the handler like applyMiddleware, but each
+// layer is guarded by r.catch. So, the error is written as a response at the
+// layer where it is returned, and the outer layers can see the real status.
+func (r *Router) chain(handler Handler, middlewares []Middleware) Handler {
+	guarded := make([]Middleware, 0, len(middlewares))
+	for _, fn := range middlewares {
+		if fn == nil {
+			continue
 		}
 
-		// Set an initial value for each request.
-		ctx := context.WithValue(req.Context(), StateKey, &s)
-		if err := handler.ServeHTTP(w, req.WithContext(ctx)); err != nil {
-			// makes a shutdown signal if a critical error occurred.
-			if IsShutdownError(err) {
-				r.SignalShutdown()
-			}
+		fn := fn
+		guarded = append(guarded, func(handler Handler) Handler {
+			return r.catch(fn(handler))
+		})
+	}
+
+	return applyMiddleware(r.catch(handler), guarded)
+}
+
+// catch calls the error handler for the error returned by the handler.
+// The error is still returned to the outer layers but marked as handled,
+// so it will not be written twice.
+func (r *Router) catch(handler Handler) Handler {
+	if _, guarded := handler.(*catcher); guarded {
+		return handler
+	}
+	return &catcher{router: r, handler: handler}
+}
+
+// catcher is a Handler guarded by the Router error handler.
+type catcher struct {
+	router  *Router
+	handler Handler
+}
+
+func (c *catcher) ServeHTTP(w http.ResponseWriter, r *http.Request) error {
+	err := c.handler.ServeHTTP(w, r)
+	if err == nil || isHandledError(err) {
+		return err
+	}
+
+	// the response can not be replaced once the header has been written,
+	// so the error is only recorded for logging.
+	if state, stateErr := GetState(r.Context()); stateErr == nil && state.WroteHeader {
+		state.Err = err
+		return &handledError{err: err}
+	}
+
+	c.router.errorHandler(w, r, err)
+	return &handledError{err: err}
+}
+
+// match finds the handler, path parameters and route pattern for the given
+// request. If the path matches but the method does not, match also returns
+// the list of allowed methods.
+func (r *Router) match(req *http.Request) (Handler, Params, string, []string) {
+	if cleanPath(req.URL.Path) != req.URL.Path {
+		return r.redirect, nil, "", nil
+	}
+
+	segments := splitPath(req.URL.Path)
+	if found, params := r.root.lookup(segments, nil, serves(req.Method)); found != nil {
+		handler, _ := found.handler(req.Method)
+		return handler, params, found.pattern, nil
+	}
+
+	// the path may still match a route of another method.
+	found, params := r.root.lookup(segments, nil, registered)
+	if found == nil {
+		return r.notFound, nil, "", nil
+	}
+
+	// answers OPTIONS for every registered path, the routes that handle it
+	// themselves are matched above.
+	if req.Method == http.MethodOptions {
+		return r.options, params, found.pattern, found.allowed()
+	}
+
+	return r.methodNotAllowed, params, found.pattern, found.allowed()
+}
+
+func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	// the group serves the same routing tree as the root Router.
+	if r.base != r {
+		r.base.ServeHTTP(w, req)
+		return
+	}
+
+	handler, params, route, allowed := r.match(req)
+	if len(allowed) != 0 {
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+	}
+
+	// uses the request ID from the client or upstream proxy if it is valid,
+	// and echoes it back, so the client can report it.
+	requestID := req.Header.Get(RequestIDHeader)
+	if !validRequestID(requestID) {
+		requestID = newRequestID()
+	}
+	w.Header().Set(RequestIDHeader, requestID)
+
+	// create the initial state
+	s := State{
+		StatusCode:     http.StatusOK, // default status code
+		RequestCreated: time.Now(),
+		RequestID:      requestID,
+		Route:          route,
+	}
+
+	// Set an initial value for each request.
+	ctx := context.WithValue(req.Context(), StateKey, &s)
+	if len(params) != 0 {
+		ctx = context.WithValue(ctx, paramsKey, params)
+	}
+
+	req = req.WithContext(ctx)
+	if err := handler.ServeHTTP(wrapWriter(w, &s), req); err != nil {
+		// turns the error into a proper response if no layer has done it.
+		if !isHandledError(err) {
+			r.errorHandler(w, req, err)
+		}
+
+		// makes a shutdown signal if a critical error occurred.
+		if IsShutdownError(err) {
+			r.SignalShutdown()
 		}
 	}
+}
 
-	r.mux.Handle(pattern, http.HandlerFunc(fn))
+// notFound replies to the request with an HTTP 404 not found error.
+func notFound(w http.ResponseWriter, r *http.Request) error {
+	return writeStatus(r.Context(), w, http.StatusNotFound)
 }
 
-func (r *Router) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
-	r.mux.ServeHTTP(rw, req)
+// methodNotAllowed replies to the request with an HTTP 405 method not allowed error.
+func methodNotAllowed(w http.ResponseWriter, r *http.Request) error {
+	return writeStatus(r.Context(), w, http.StatusMethodNotAllowed)
+}
+
+// redirect replies to the request with a redirect to the clean path.
+func redirect(w http.ResponseWriter, r *http.Request) error {
+	state, err := GetState(r.Context())
+	if err != nil {
+		return NewShutdownError(err.Error())
+	}
+
+	status := http.StatusMovedPermanently
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		status = http.StatusPermanentRedirect
+	}
+
+	u := url.URL{Path: cleanPath(r.URL.Path), RawQuery: r.URL.RawQuery}
+	state.StatusCode = status
+	http.Redirect(w, r, u.String(), status)
+	return nil
+}
+
+// options replies to the request with an HTTP 204 no content, the Allow header
+// is set by the Router.
+func options(w http.ResponseWriter, r *http.Request) error {
+	state, err := GetState(r.Context())
+	if err != nil {
+		return NewShutdownError(err.Error())
+	}
+
+	state.StatusCode = http.StatusNoContent
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// writeStatus writes the status code and its text as plaintext response.
+func writeStatus(ctx context.Context, w http.ResponseWriter, status int) error {
+	state, err := GetState(ctx)
+	if err != nil {
+		return NewShutdownError(err.Error())
+	}
+
+	state.StatusCode = status
+	http.Error(w, http.StatusText(status), status)
+	return nil
 }
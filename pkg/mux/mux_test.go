@@ -41,11 +41,15 @@ func TestRouter_Handle(t *testing.T) {
 		router := NewRouter(nil)
 
 		handler := func(w http.ResponseWriter, r *http.Request) error {
-			_, err := GetState(r.Context())
+			state, err := GetState(r.Context())
 			if err != nil {
 				t.Fatal("expecting request has initial state")
 			}
 
+			if state.RequestID != expectedRequestID {
+				t.Errorf("expecting request id: %s but got: %s", expectedRequestID, state.RequestID)
+			}
+
 			_, err = io.WriteString(w, expectedResponseBody)
 			return err
 		}
@@ -55,9 +59,14 @@ func TestRouter_Handle(t *testing.T) {
 
 		// make a http request
 		req := httptest.NewRequest(http.MethodPost, exampleURL, nil)
+		req.Header.Set(RequestIDHeader, expectedRequestID)
 		rec := httptest.NewRecorder()
 		router.ServeHTTP(rec, req)
 
+		if id := rec.Header().Get(RequestIDHeader); id != expectedRequestID {
+			t.Errorf("expecting request id header: %s but got: %s", expectedRequestID, id)
+		}
+
 		statusCode := rec.Result().StatusCode
 		if statusCode != http.StatusOK {
 			t.Errorf("expecting status code: %d but got: %d", http.StatusOK, statusCode)
@@ -65,7 +74,7 @@ func TestRouter_Handle(t *testing.T) {
 
 		body := rec.Body.String()
 		if body != expectedResponseBody {
-			t.Errorf("expecting response body: %s but got: %s", expectedRequestID, body)
+			t.Errorf("expecting response body: %s but got: %s", expectedResponseBody, body)
 		}
 	})
 
@@ -0,0 +1,80 @@
+package mux
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync/atomic"
+	"time"
+)
+
+// RequestIDHeader is the header to propagate the request ID.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength is the maximum length of the incoming request ID.
+const maxRequestIDLength = 128
+
+// fallbackCounter is used to generate the request ID if the random source fails.
+var fallbackCounter uint64
+
+// RequestID gets the request ID from the given context.
+// If the state is missing, an empty string is returned.
+func RequestID(ctx context.Context) string {
+	state, err := GetState(ctx)
+	if err != nil {
+		return ""
+	}
+	return state.RequestID
+}
+
+// validRequestID returns true if the incoming request ID is safe to be used.
+// The valid request ID only contains alphanumeric, '-', '_', '.' and ':'
+// and has length at most 128 characters, so it can not be used to inject
+// anything into the response headers or the logs.
+func validRequestID(id string) bool {
+	if len(id) == 0 || len(id) > maxRequestIDLength {
+		return false
+	}
+
+	for i := 0; i < len(id); i++ {
+		c := id[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// newRequestID generates a new random request ID formatted as UUID version 4.
+func newRequestID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		// the random source is unavailable, uses time and counter instead.
+		n := atomic.AddUint64(&fallbackCounter, 1)
+		t := uint64(time.Now().UnixNano())
+		for i := 0; i < 8; i++ {
+			b[i] = byte(t >> (8 * i))
+			b[i+8] = byte(n >> (8 * i))
+		}
+	}
+
+	b[6] = (b[6] & 0x0f) | 0x40 // version 4
+	b[8] = (b[8] & 0x3f) | 0x80 // variant RFC 4122
+
+	var buf [36]byte
+	hex.Encode(buf[0:8], b[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], b[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], b[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], b[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], b[10:])
+
+	return string(buf[:])
+}
@@ -0,0 +1,56 @@
+package mux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestRouter_RequestID(t *testing.T) {
+	uuid := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+	tests := []struct {
+		name      string
+		incoming  string
+		generated bool
+	}{
+		{name: "missing", incoming: "", generated: true},
+		{name: "valid", incoming: "req-01:abc_DEF.2", generated: false},
+		{name: "contains space", incoming: "abc def", generated: true},
+		{name: "contains newline", incoming: "abc\nX-Injected: 1", generated: true},
+		{name: "too long", incoming: strings.Repeat("a", 129), generated: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			router := NewRouter(nil)
+			router.Handle("/example", HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+				got = RequestID(r.Context())
+				return nil
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/example", nil)
+			if tt.incoming != "" {
+				req.Header.Set(RequestIDHeader, tt.incoming)
+			}
+
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if tt.generated && !uuid.MatchString(got) {
+				t.Fatalf("expecting generated uuid but got %q", got)
+			}
+
+			if !tt.generated && got != tt.incoming {
+				t.Fatalf("expecting request id %q but got %q", tt.incoming, got)
+			}
+
+			if header := rec.Header().Get(RequestIDHeader); header != got {
+				t.Fatalf("expecting header %q but got %q", got, header)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package mux
+
+import (
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// Route describes a registered route.
+// The metadata (Summary, Request and Responses) is optional and only used
+// for introspection, for example to generate the API documentation.
+type Route struct {
+	// Method is the route method, an empty method means the route serves all methods.
+	Method string
+
+	// Pattern is the full path pattern including the group prefix.
+	Pattern string
+
+	// Middleware is the names of the global, group and route middlewares
+	// in the execution order.
+	Middleware []string
+
+	// Summary is a short description of the route.
+	Summary string
+
+	// Request is a value of the request body type.
+	Request interface{}
+
+	// Responses holds a value of the response body type for each status code.
+	// A nil value means the response has no body.
+	Responses map[int]interface{}
+}
+
+// Describe sets the route summary.
+func (r *Route) Describe(summary string) *Route {
+	r.Summary = summary
+	return r
+}
+
+// Accepts sets the request body type by the given value.
+func (r *Route) Accepts(v interface{}) *Route {
+	r.Request = v
+	return r
+}
+
+// Returns sets the response body type for the given status code by the given value.
+func (r *Route) Returns(status int, v interface{}) *Route {
+	if r.Responses == nil {
+		r.Responses = make(map[int]interface{})
+	}
+	r.Responses[status] = v
+	return r
+}
+
+// Routes returns the registered routes sorted by pattern and method.
+func (r *Router) Routes() []Route {
+	routes := make([]Route, 0, len(r.base.routes))
+	for _, route := range r.base.routes {
+		c := *route
+		c.Middleware = append([]string(nil), route.Middleware...)
+		if route.Responses != nil {
+			c.Responses = make(map[int]interface{}, len(route.Responses))
+			for status, v := range route.Responses {
+				c.Responses[status] = v
+			}
+		}
+		routes = append(routes, c)
+	}
+
+	sort.SliceStable(routes, func(i, j int) bool {
+		if routes[i].Pattern != routes[j].Pattern {
+			return routes[i].Pattern < routes[j].Pattern
+		}
+		return routes[i].Method < routes[j].Method
+	})
+
+	return routes
+}
+
+// middlewareNames returns the names of the given middlewares.
+func middlewareNames(groups ...[]Middleware) []string {
+	names := make([]string, 0)
+	for _, middlewares := range groups {
+		for _, fn := range middlewares {
+			if fn != nil {
+				names = append(names, middlewareName(fn))
+			}
+		}
+	}
+	return names
+}
+
+// middlewareName returns the name of the function that creates the middleware.
+//
+// For example, the middleware created by `func Logger(l *log.Logger) mux.Middleware`
+// in package `.../restapi/middleware` is named "middleware.Logger".
+func middlewareName(fn Middleware) string {
+	f := runtime.FuncForPC(reflect.ValueOf(fn).Pointer())
+	if f == nil {
+		return "unknown"
+	}
+
+	name := f.Name()
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+
+	// drops the closure suffixes, for example ".func1" and ".func1.1".
+	parts := strings.Split(name, ".")
+	for len(parts) > 2 {
+		last := parts[len(parts)-1]
+		if !strings.HasPrefix(last, "func") && strings.Trim(last, "0123456789") != "" {
+			break
+		}
+		parts = parts[:len(parts)-1]
+	}
+
+	return strings.Join(parts, ".")
+}
@@ -0,0 +1,58 @@
+package mux
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func namedMiddleware() Middleware {
+	return func(handler Handler) Handler {
+		return handler
+	}
+}
+
+func TestRouter_Routes(t *testing.T) {
+	type user struct {
+		Name string `json:"name"`
+	}
+
+	handler := HandlerFunc(func(w http.ResponseWriter, r *http.Request) error { return nil })
+
+	router := NewRouter(nil, namedMiddleware())
+	v1 := router.Group("/v1", namedMiddleware())
+
+	v1.Handle("POST /users", handler, namedMiddleware()).
+		Describe("Creates a user.").
+		Accepts(user{}).
+		Returns(http.StatusCreated, user{})
+
+	router.Handle("/files/{path...}", handler)
+
+	expected := []Route{
+		{
+			Method:     "",
+			Pattern:    "/files/{path...}",
+			Middleware: []string{"mux.namedMiddleware"},
+		},
+		{
+			Method:     http.MethodPost,
+			Pattern:    "/v1/users",
+			Middleware: []string{"mux.namedMiddleware", "mux.namedMiddleware", "mux.namedMiddleware"},
+			Summary:    "Creates a user.",
+			Request:    user{},
+			Responses:  map[int]interface{}{http.StatusCreated: user{}},
+		},
+	}
+
+	routes := v1.Routes()
+	if !reflect.DeepEqual(expected, routes) {
+		t.Fatalf("expecting: %+v got: %+v", expected, routes)
+	}
+
+	// the returned routes are copies.
+	routes[1].Middleware[0] = "changed"
+	if router.Routes()[1].Middleware[0] == "changed" {
+		t.Fatalf("expecting routes can not be changed from outside")
+	}
+}
@@ -0,0 +1,298 @@
+package mux
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/josestg/justforfun/pkg/xerrs"
+)
+
+// DefaultShutdownTimeout is the default deadline for the server to complete
+// the in-flight requests after receiving the shutdown signal.
+const DefaultShutdownTimeout = 30 * time.Second
+
+// ServerOption is an option type that can be used to customize the Server.
+type ServerOption func(s *Server)
+
+// Printer is a contract for server logger.
+type Printer interface {
+	// Printf knows how to print formatted text.
+	Printf(format string, args ...interface{})
+}
+
+// nopPrinter is a Printer that prints nothing.
+type nopPrinter struct{}
+
+func (nopPrinter) Printf(string, ...interface{}) {}
+
+// WithShutdownTimeout sets the deadline for the server to complete the
+// in-flight requests. After the deadline, the connections are closed forcibly.
+func WithShutdownTimeout(d time.Duration) ServerOption {
+	return func(s *Server) {
+		s.shutdownTimeout = d
+	}
+}
+
+// WithDrainDelay sets the delay between marking the server not-ready and
+// draining the connections. It gives the load balancer time to see the
+// readiness change and stop sending new requests.
+func WithDrainDelay(d time.Duration) ServerOption {
+	return func(s *Server) {
+		s.drainDelay = d
+	}
+}
+
+// WithServerPrinter sets the Server logger.
+func WithServerPrinter(p Printer) ServerOption {
+	return func(s *Server) {
+		s.printer = p
+	}
+}
+
+// ShutdownHook is a function that will be executed when the server is shutting down.
+type ShutdownHook func(ctx context.Context) error
+
+// hook is a registered ShutdownHook.
+type hook struct {
+	name    string
+	timeout time.Duration
+	fn      ShutdownHook
+}
+
+// Server is a graceful HTTP server.
+//
+// The Server serves until it receives a signal from the ShutdownChannel, then:
+//
+//	1. marks itself not-ready,
+//	2. waits for the drain delay,
+//	3. shuts down the http.Server gracefully, or closes it when the shutdown timeout is exceeded,
+//	4. runs the shutdown hooks in the registration order, each with its own timeout.
+//
+// The errors from all steps are combined into a single error.
+type Server struct {
+	srv *http.Server
+	sc  ShutdownChannel
+
+	printer         Printer
+	shutdownTimeout time.Duration
+	drainDelay      time.Duration
+
+	mu    sync.Mutex
+	hooks []hook
+
+	ready int32
+}
+
+// NewServer creates a new Server for the given http.Server.
+// The Server shuts down when the given channel receives a signal.
+func NewServer(srv *http.Server, channel ShutdownChannel, options ...ServerOption) *Server {
+	s := Server{
+		srv:             srv,
+		sc:              channel,
+		printer:         nopPrinter{},
+		shutdownTimeout: DefaultShutdownTimeout,
+	}
+
+	for _, fn := range options {
+		fn(&s)
+	}
+
+	return &s
+}
+
+// OnShutdown registers a hook that will be executed after the server has been shut down,
+// for example to close the database connection or to flush the logs.
+// The hooks are executed in the registration order, and each hook is given
+// a context with the given timeout.
+func (s *Server) OnShutdown(name string, timeout time.Duration, fn ShutdownHook) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.hooks = append(s.hooks, hook{name: name, timeout: timeout, fn: fn})
+}
+
+// Ready returns true if the server is serving and not shutting down.
+func (s *Server) Ready() bool {
+	return atomic.LoadInt32(&s.ready) == 1
+}
+
+// ListenAndServe listens on the http.Server address and serves until the
+// shutdown is completed.
+func (s *Server) ListenAndServe() error {
+	addr := s.srv.Addr
+	if addr == "" {
+		addr = ":http"
+	}
+
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		// the hooks still need to release the resources.
+		return combineErrors(xerrs.Wrap(err, "listening failed"), s.runHooks())
+	}
+
+	return s.Serve(l)
+}
+
+// Serve accepts the incoming connections on the given listener and serves until the
+// shutdown is completed. Serve returns nil if the shutdown is graceful.
+func (s *Server) Serve(l net.Listener) error {
+	atomic.StoreInt32(&s.ready, 1)
+
+	listenErr := make(chan error, 1)
+	go func() {
+		s.printer.Printf("server: listening on %s", l.Addr())
+		listenErr <- s.srv.Serve(l)
+	}()
+
+	select {
+	case sig := <-s.sc:
+		s.printer.Printf("server: receives shutdown signal: %v", sig)
+		return s.shutdown()
+	case err := <-listenErr:
+		atomic.StoreInt32(&s.ready, 0)
+		return combineErrors(xerrs.Wrap(err, "serving failed"), s.runHooks())
+	}
+}
+
+// shutdown shuts down the server and runs the hooks.
+func (s *Server) shutdown() error {
+	atomic.StoreInt32(&s.ready, 0)
+
+	if s.drainDelay > 0 {
+		s.printer.Printf("server: not ready, draining in %s", s.drainDelay)
+		time.Sleep(s.drainDelay)
+	}
+
+	// creating a deadline for the server to complete the in-flight requests.
+	ctx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
+	defer cancel()
+
+	errs := make([]error, 0)
+
+	// Gracefully shutdown
+	// a non-nil error caused by the server failing to shut down gracefully
+	// or the server exceeding the shutdown timeout.
+	if err := s.srv.Shutdown(ctx); err != nil {
+		errs = append(errs, xerrs.Wrap(err, "shutting down gracefully"))
+
+		// Force shutdown
+		if err := s.srv.Close(); err != nil {
+			errs = append(errs, xerrs.Wrap(err, "closing server"))
+		}
+	} else {
+		s.printer.Printf("server: was shutting down gracefully")
+	}
+
+	errs = append(errs, s.runHooks())
+	return combineErrors(errs...)
+}
+
+// runHooks runs the shutdown hooks in order.
+// All hooks are executed even if the previous hook fails.
+func (s *Server) runHooks() error {
+	s.mu.Lock()
+	hooks := append([]hook(nil), s.hooks...)
+	s.mu.Unlock()
+
+	errs := make([]error, 0)
+	for _, h := range hooks {
+		if err := runHook(h); err != nil {
+			errs = append(errs, xerrs.Wrap(err, fmt.Sprintf("shutdown hook %q", h.name)))
+			continue
+		}
+		s.printer.Printf("server: shutdown hook %q completed", h.name)
+	}
+
+	return combineErrors(errs...)
+}
+
+// runHook runs the hook with its timeout. If the hook ignores its context,
+// runHook stops waiting after the timeout is exceeded.
+func runHook(h hook) error {
+	ctx := context.Background()
+	if h.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.timeout)
+		defer cancel()
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if rec := recover(); rec != nil {
+				done <- fmt.Errorf("panics: %v", rec)
+			}
+		}()
+		done <- h.fn(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// multiError is a combination of multiple errors.
+type multiError []error
+
+// combineErrors combines the non-nil errors into a single error.
+// It returns nil if there is no error, and the error itself if there is only one.
+func combineErrors(errs ...error) error {
+	combined := make(multiError, 0, len(errs))
+	for _, err := range errs {
+		if m, ok := err.(multiError); ok {
+			combined = append(combined, m...)
+			continue
+		}
+
+		if err != nil {
+			combined = append(combined, err)
+		}
+	}
+
+	switch len(combined) {
+	case 0:
+		return nil
+	case 1:
+		return combined[0]
+	default:
+		return combined
+	}
+}
+
+func (m multiError) Error() string {
+	messages := make([]string, 0, len(m))
+	for _, err := range m {
+		messages = append(messages, err.Error())
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Is reports whether any of the errors matches the target.
+func (m multiError) Is(target error) bool {
+	for _, err := range m {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first error that matches the target.
+func (m multiError) As(target interface{}) bool {
+	for _, err := range m {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,138 @@
+package mux
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestServer_Serve(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("expecting error nil but got %v", err)
+	}
+
+	requestStarted := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(requestStarted)
+		time.Sleep(50 * time.Millisecond)
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	shutdownChannel := make(ShutdownChannel, 1)
+	server := NewServer(&http.Server{Handler: handler}, shutdownChannel, WithShutdownTimeout(time.Second))
+
+	hookErr := errors.New("flush failed")
+	var mu sync.Mutex
+	calls := make([]string, 0)
+	record := func(name string) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls = append(calls, name)
+	}
+	server.OnShutdown("close db", time.Second, func(ctx context.Context) error {
+		if server.Ready() {
+			t.Errorf("expecting server is not ready while shutting down")
+		}
+		record("close db")
+		return nil
+	})
+	server.OnShutdown("flush logs", time.Second, func(ctx context.Context) error {
+		record("flush logs")
+		return hookErr
+	})
+	server.OnShutdown("stuck", 10*time.Millisecond, func(ctx context.Context) error {
+		record("stuck")
+		time.Sleep(time.Second)
+		return nil
+	})
+
+	served := make(chan error, 1)
+	go func() { served <- server.Serve(l) }()
+
+	// the in-flight request must be completed before the server is closed.
+	responded := make(chan int, 1)
+	go func() {
+		resp, err := http.Get("http://" + l.Addr().String())
+		if err != nil {
+			responded <- 0
+			return
+		}
+		_ = resp.Body.Close()
+		responded <- resp.StatusCode
+	}()
+
+	<-requestStarted
+	if !server.Ready() {
+		t.Fatalf("expecting server is ready")
+	}
+
+	shutdownChannel <- syscall.SIGTERM
+
+	if status := <-responded; status != http.StatusNoContent {
+		t.Fatalf("expecting in-flight request is completed but got status %d", status)
+	}
+
+	err = <-served
+	if !errors.Is(err, hookErr) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expecting combined hook errors but got %v", err)
+	}
+
+	if !strings.Contains(err.Error(), `shutdown hook "flush logs"`) {
+		t.Fatalf("expecting the hook name in the error but got %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	expected := []string{"close db", "flush logs", "stuck"}
+	if !reflect.DeepEqual(expected, calls) {
+		t.Fatalf("expecting hooks: %v but got: %v", expected, calls)
+	}
+}
+
+func TestServer_ListenFailed(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("expecting error nil but got %v", err)
+	}
+	defer l.Close()
+
+	closed := false
+	server := NewServer(&http.Server{Addr: l.Addr().String()}, make(ShutdownChannel))
+	server.OnShutdown("close db", time.Second, func(ctx context.Context) error {
+		closed = true
+		return nil
+	})
+
+	if err := server.ListenAndServe(); err == nil {
+		t.Fatalf("expecting listening error")
+	}
+
+	if !closed {
+		t.Fatalf("expecting the hooks are executed")
+	}
+}
+
+func TestCombineErrors(t *testing.T) {
+	if combineErrors(nil, nil) != nil {
+		t.Fatalf("expecting nil")
+	}
+
+	a, b := errors.New("a"), errors.New("b")
+	if combineErrors(nil, a) != a {
+		t.Fatalf("expecting the single error")
+	}
+
+	err := combineErrors(a, combineErrors(b, a))
+	if err.Error() != "a; b; a" {
+		t.Fatalf("expecting flatten errors but got %q", err.Error())
+	}
+}
@@ -0,0 +1,188 @@
+package mux
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// PanicError is the panic of a handler that is recovered in another goroutine
+// and re-panicked in the request goroutine, such as by Timeout. It keeps the
+// stack of the handler goroutine, since the stack of the re-panic only shows
+// where it is re-panicked.
+type PanicError struct {
+	// Value is the value the handler panicked with.
+	Value interface{}
+
+	// Stack is the stack trace of the handler goroutine.
+	Stack []byte
+}
+
+// Error implements the error interface.
+func (p *PanicError) Error() string {
+	return fmt.Sprint(p.Value)
+}
+
+// Timeout creates a middleware that limits the handler execution time.
+//
+// The handler is given a context with the deadline. If the handler does not
+// return before the deadline, the middleware returns a 503 Service Unavailable
+// error and any late write from the handler fails with http.ErrHandlerTimeout.
+// The timeout is recorded into the State, so the middlewares can report it.
+//
+// The response is buffered until the handler returns, so the handler under
+// Timeout can not stream the response, and it can not hijack the connection.
+//
+// The panic of the handler is re-panicked in the request goroutine as a
+// *PanicError with the stack of the handler, except http.ErrAbortHandler.
+func Timeout(d time.Duration) Middleware {
+	return func(handler Handler) Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) error {
+			state, err := GetState(r.Context())
+			if err != nil {
+				return NewShutdownError(err.Error())
+			}
+
+			state.Timeout = d
+
+			ctx, cancel := context.WithTimeout(r.Context(), d)
+			defer cancel()
+
+			// the handler gets its own copy of the state, so the late handler
+			// can not race with the outer layers on the state.
+			inner := *state
+			ctx = context.WithValue(ctx, StateKey, &inner)
+
+			tw := &timeoutWriter{header: make(http.Header), state: &inner}
+
+			done := make(chan error, 1)
+			panicked := make(chan interface{}, 1)
+			go func() {
+				defer func() {
+					rec := recover()
+					if rec == nil {
+						return
+					}
+
+					if rec == http.ErrAbortHandler {
+						panicked <- rec
+						return
+					}
+
+					panicked <- &PanicError{Value: rec, Stack: debug.Stack()}
+				}()
+				done <- handler.ServeHTTP(tw, r.WithContext(ctx))
+			}()
+
+			select {
+			case rec := <-panicked:
+				// re-panics in the request goroutine, so the recovery middleware can see it.
+				panic(rec)
+			case err := <-done:
+				tw.mu.Lock()
+				defer tw.mu.Unlock()
+
+				// the State fields that are written by the ResponseWriter are
+				// updated when the buffered response is written into w.
+				inner.WroteHeader = state.WroteHeader
+				inner.BytesWritten = state.BytesWritten
+				inner.TimeToFirstByte = state.TimeToFirstByte
+				*state = inner
+
+				if writeErr := tw.writeTo(w); writeErr != nil {
+					return writeErr
+				}
+
+				return err
+			case <-ctx.Done():
+				tw.mu.Lock()
+				defer tw.mu.Unlock()
+				tw.timedOut = true
+
+				if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+					state.TimedOut = true
+				}
+
+				return WrapError(ctx.Err(), http.StatusServiceUnavailable, "timeout", "the request took too long to complete")
+			}
+		}
+
+		return HandlerFunc(fn)
+	}
+}
+
+// timeoutWriter buffers the response of the handler under Timeout.
+//
+// The buffered write is recorded into the State of the handler, so the inner
+// layers know the header has been written, and do not append the error
+// response to the body.
+type timeoutWriter struct {
+	mu       sync.Mutex
+	header   http.Header
+	buf      bytes.Buffer
+	status   int
+	timedOut bool
+	state    *State
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+
+	if tw.status == 0 {
+		tw.writeHeader(http.StatusOK)
+	}
+
+	n, err := tw.buf.Write(b)
+	tw.state.BytesWritten += int64(n)
+	return n, err
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.timedOut || tw.status != 0 {
+		return
+	}
+
+	tw.writeHeader(code)
+}
+
+// writeHeader records the status, the caller must hold the lock.
+func (tw *timeoutWriter) writeHeader(code int) {
+	tw.status = code
+	tw.state.WroteHeader = true
+	tw.state.StatusCode = code
+}
+
+// writeTo writes the buffered response into w.
+// Only the headers are copied if the handler has not written anything,
+// so the outer layers can still write the response.
+func (tw *timeoutWriter) writeTo(w http.ResponseWriter) error {
+	dst := w.Header()
+	for k, v := range tw.header {
+		dst[k] = v
+	}
+
+	if tw.status == 0 {
+		return nil
+	}
+
+	w.WriteHeader(tw.status)
+	_, err := w.Write(tw.buf.Bytes())
+	return err
+}
@@ -0,0 +1,145 @@
+package mux
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTimeout(t *testing.T) {
+	lateWrite := make(chan error, 1)
+
+	var state *State
+	observer := func(handler Handler) Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) error {
+			state, _ = GetState(r.Context())
+			return handler.ServeHTTP(w, r)
+		}
+		return HandlerFunc(fn)
+	}
+
+	router := NewRouter(nil, observer)
+	router.Handle("/fast", HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		w.Header().Set("X-Fast", "1")
+		w.WriteHeader(http.StatusCreated)
+		_, err := io.WriteString(w, "done")
+		return err
+	}), Timeout(time.Second))
+
+	router.Handle("/slow", HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		<-r.Context().Done()
+		time.Sleep(10 * time.Millisecond)
+		_, err := io.WriteString(w, "late")
+		lateWrite <- err
+		return err
+	}), Timeout(20*time.Millisecond))
+
+	router.Handle("/failed", HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		w.Header().Set("Retry-After", "1")
+		return NewError(http.StatusTooManyRequests, "", "")
+	}), Timeout(time.Second))
+
+	t.Run("completed", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/fast", nil))
+
+		if rec.Code != http.StatusCreated || rec.Body.String() != "done" || rec.Header().Get("X-Fast") != "1" {
+			t.Fatalf("expecting buffered response is written but got: %d %q %v", rec.Code, rec.Body.String(), rec.Header())
+		}
+
+		if state.StatusCode != http.StatusCreated || state.BytesWritten != 4 || state.TimedOut || state.Timeout != time.Second {
+			t.Fatalf("expecting state is recorded but got: %+v", state)
+		}
+	})
+
+	t.Run("timed out", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/slow", nil))
+
+		if rec.Code != http.StatusServiceUnavailable {
+			t.Fatalf("expecting status code: %d but got: %d", http.StatusServiceUnavailable, rec.Code)
+		}
+
+		if !state.TimedOut || !errors.Is(state.Err, context.DeadlineExceeded) {
+			t.Fatalf("expecting timeout is recorded but got: %+v", state)
+		}
+
+		if err := <-lateWrite; !errors.Is(err, http.ErrHandlerTimeout) {
+			t.Fatalf("expecting late write fails but got: %v", err)
+		}
+
+		if body := rec.Body.String(); body == "" || body == "late" {
+			t.Fatalf("expecting timeout response but got: %q", body)
+		}
+	})
+
+	t.Run("error without write", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/failed", nil))
+
+		if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "1" {
+			t.Fatalf("expecting the error response with headers but got: %d %v", rec.Code, rec.Header())
+		}
+	})
+}
+
+func TestTimeout_Panics(t *testing.T) {
+	handler := Timeout(time.Second)(HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		panic("boom")
+	}))
+
+	defer func() {
+		pe, ok := recover().(*PanicError)
+		if !ok || pe.Value != "boom" {
+			t.Fatalf("expecting re-panics in the request goroutine but got: %v", pe)
+		}
+
+		if !strings.Contains(string(pe.Stack), "timeout_test.go") {
+			t.Fatalf("expecting the stack of the handler goroutine but got: %s", pe.Stack)
+		}
+	}()
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req = req.WithContext(context.WithValue(req.Context(), StateKey, &State{}))
+	_ = handler.ServeHTTP(httptest.NewRecorder(), req)
+}
+
+func TestTimeout_ErrorAfterWrite(t *testing.T) {
+	router := NewRouter(nil)
+	router.Handle("GET /partial", HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		_, _ = io.WriteString(w, "partial")
+		return errors.New("failed after writing")
+	}), Timeout(time.Second))
+
+	var state *State
+	observer := func(handler Handler) Handler {
+		return HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+			err := handler.ServeHTTP(w, r)
+			state, _ = GetState(r.Context())
+			return err
+		})
+	}
+	router.Handle("GET /observed", HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		_, _ = io.WriteString(w, "partial")
+		return errors.New("failed after writing")
+	}), observer, Timeout(time.Second))
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/partial", nil))
+
+	if rec.Code != http.StatusOK || rec.Body.String() != "partial" {
+		t.Fatalf("expecting the written response is not corrupted but got: %d %q", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/observed", nil))
+
+	if state == nil || state.Err == nil || !state.WroteHeader || state.BytesWritten != 7 {
+		t.Fatalf("expecting the error is recorded into the state but got: %+v", state)
+	}
+}
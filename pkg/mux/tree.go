@@ -0,0 +1,278 @@
+package mux
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+)
+
+// paramsKey is a key to stores and retrieves the path parameters
+// from the request context.
+const paramsKey = keyType(1)
+
+// methodAny is a method key for handlers that serve all methods.
+const methodAny = ""
+
+// Params is a list of path parameters captured by the route pattern.
+type Params []ParamValue
+
+// ParamValue is a single path parameter.
+type ParamValue struct {
+	Key   string
+	Value string
+}
+
+// Get returns the value of the first parameter which has the given key.
+// If no parameter matches the key, an empty string is returned.
+func (p Params) Get(key string) string {
+	for _, v := range p {
+		if v.Key == key {
+			return v.Value
+		}
+	}
+	return ""
+}
+
+// Param gets the path parameter value from the given context.
+//
+// For example, if the route pattern is `GET /v1/users/{id}` and the request path
+// is `/v1/users/42`, then Param(ctx, "id") returns "42".
+func Param(ctx context.Context, key string) string {
+	return GetParams(ctx).Get(key)
+}
+
+// GetParams gets all path parameters from the given context.
+func GetParams(ctx context.Context) Params {
+	v, _ := ctx.Value(paramsKey).(Params)
+	return v
+}
+
+// parsePattern splits the pattern into method and path.
+// The pattern has form `[METHOD ]PATH`, for example `GET /v1/users/{id}`.
+// If the method is omitted, the route serves all methods.
+func parsePattern(pattern string) (method string, path string) {
+	pattern = strings.TrimSpace(pattern)
+	if i := strings.IndexAny(pattern, " \t"); i >= 0 {
+		method, path = pattern[:i], strings.TrimSpace(pattern[i+1:])
+	} else {
+		path = pattern
+	}
+
+	if !strings.HasPrefix(path, "/") {
+		panic(fmt.Sprintf("mux: path must begin with '/' in pattern %q", pattern))
+	}
+
+	return strings.ToUpper(method), path
+}
+
+// cleanPath returns the canonical form of the path, the empty, "." and ".."
+// segments are removed. The trailing slash is kept, so "/a/" stays "/a/".
+func cleanPath(p string) string {
+	if p == "" {
+		return "/"
+	}
+
+	if p[0] != '/' {
+		p = "/" + p
+	}
+
+	np := path.Clean(p)
+	if p[len(p)-1] == '/' && np != "/" {
+		np += "/"
+	}
+	return np
+}
+
+// splitPath splits the path into segments.
+// The leading slash is dropped, so "/" becomes [""] and "/a/" becomes ["a", ""].
+func splitPath(path string) []string {
+	return strings.Split(strings.TrimPrefix(path, "/"), "/")
+}
+
+// node is a node of the routing tree.
+// Each node represents a single path segment.
+type node struct {
+	// static holds the children with fixed segment.
+	static map[string]*node
+
+	// param holds the child that matches any single segment, `{name}`.
+	param     *node
+	paramName string
+
+	// wildcard holds the child that matches the rest of path, `{name...}`.
+	wildcard     *node
+	wildcardName string
+
+	// pattern is the path pattern that ends at this node.
+	pattern string
+
+	// handlers holds the handler for each method.
+	handlers map[string]Handler
+}
+
+func newNode() *node {
+	return &node{
+		static:   make(map[string]*node),
+		handlers: make(map[string]Handler),
+	}
+}
+
+// insert registers the handler for the given method and path.
+func (n *node) insert(method, path string, handler Handler) {
+	segments := splitPath(path)
+	current := n
+	for i, seg := range segments {
+		switch {
+		default:
+			child, exist := current.static[seg]
+			if !exist {
+				child = newNode()
+				current.static[seg] = child
+			}
+			current = child
+
+		case strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "...}"):
+			name := seg[1 : len(seg)-4]
+			if name == "" {
+				panic(fmt.Sprintf("mux: empty wildcard name in path %q", path))
+			}
+
+			if i != len(segments)-1 {
+				panic(fmt.Sprintf("mux: wildcard %q must be the last segment in path %q", seg, path))
+			}
+
+			if current.wildcard == nil {
+				current.wildcard = newNode()
+				current.wildcardName = name
+			}
+
+			if current.wildcardName != name {
+				panic(fmt.Sprintf("mux: wildcard %q conflicts with {%s...} in path %q", seg, current.wildcardName, path))
+			}
+			current = current.wildcard
+
+		case strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}"):
+			name := seg[1 : len(seg)-1]
+			if name == "" {
+				panic(fmt.Sprintf("mux: empty parameter name in path %q", path))
+			}
+
+			if current.param == nil {
+				current.param = newNode()
+				current.paramName = name
+			}
+
+			if current.paramName != name {
+				panic(fmt.Sprintf("mux: parameter %q conflicts with {%s} in path %q", seg, current.paramName, path))
+			}
+			current = current.param
+		}
+	}
+
+	if _, exist := current.handlers[method]; exist {
+		panic(fmt.Sprintf("mux: multiple registrations for %s %s", method, path))
+	}
+
+	current.pattern = path
+	current.handlers[method] = handler
+}
+
+// lookup finds the node that matches the given path segments and is accepted
+// by the accept function. The static segment has higher priority than the
+// parameter segment, and the parameter segment has higher priority than the
+// wildcard segment. If the node of a higher priority is not accepted, for
+// example it does not serve the request method, the lower ones are tried.
+func (n *node) lookup(segments []string, params Params, accept func(n *node) bool) (*node, Params) {
+	if len(segments) == 0 {
+		if !accept(n) {
+			return nil, params
+		}
+		return n, params
+	}
+
+	seg := segments[0]
+
+	if child, exist := n.static[seg]; exist {
+		if found, p := child.lookup(segments[1:], params, accept); found != nil {
+			return found, p
+		}
+	}
+
+	if n.param != nil && seg != "" {
+		p := append(params, ParamValue{Key: n.paramName, Value: seg})
+		if found, p := n.param.lookup(segments[1:], p, accept); found != nil {
+			return found, p
+		}
+	}
+
+	if n.wildcard != nil && accept(n.wildcard) {
+		p := append(params, ParamValue{Key: n.wildcardName, Value: strings.Join(segments, "/")})
+		return n.wildcard, p
+	}
+
+	return nil, params
+}
+
+// registered accepts the node that serves any method.
+func registered(n *node) bool {
+	return len(n.handlers) != 0
+}
+
+// serves returns the accept function of the node that serves the given method.
+// Only the explicit OPTIONS handler serves OPTIONS, the other nodes are answered
+// by the Router.
+func serves(method string) func(n *node) bool {
+	return func(n *node) bool {
+		if method == http.MethodOptions {
+			_, exist := n.handlers[http.MethodOptions]
+			return exist
+		}
+
+		_, exist := n.handler(method)
+		return exist
+	}
+}
+
+// handler returns the handler for the given method.
+// HEAD requests are served by the GET handler if there is no HEAD handler.
+func (n *node) handler(method string) (Handler, bool) {
+	if h, exist := n.handlers[method]; exist {
+		return h, true
+	}
+
+	if method == http.MethodHead {
+		if h, exist := n.handlers[http.MethodGet]; exist {
+			return h, true
+		}
+	}
+
+	h, exist := n.handlers[methodAny]
+	return h, exist
+}
+
+// allowed returns the sorted list of methods served by this node.
+// HEAD and OPTIONS are included if they are served implicitly.
+func (n *node) allowed() []string {
+	methods := make([]string, 0, len(n.handlers)+2)
+	for method := range n.handlers {
+		if method != methodAny {
+			methods = append(methods, method)
+		}
+	}
+
+	if _, exist := n.handlers[http.MethodGet]; exist {
+		if _, exist := n.handlers[http.MethodHead]; !exist {
+			methods = append(methods, http.MethodHead)
+		}
+	}
+
+	if _, exist := n.handlers[http.MethodOptions]; !exist {
+		methods = append(methods, http.MethodOptions)
+	}
+
+	sort.Strings(methods)
+	return methods
+}
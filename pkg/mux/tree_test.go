@@ -0,0 +1,194 @@
+package mux
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRouter_PathParams(t *testing.T) {
+	router := NewRouter(nil)
+
+	echo := func(name string) Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) error {
+			_, err := io.WriteString(w, name+":"+Param(r.Context(), "id")+Param(r.Context(), "path"))
+			return err
+		}
+		return HandlerFunc(fn)
+	}
+
+	router.Handle("GET /v1/users/{id}", echo("get-user"))
+	router.Handle("DELETE /v1/users/{id}", echo("delete-user"))
+	router.Handle("GET /v1/users/me", echo("me"))
+	router.Handle("/files/{path...}", echo("files"))
+	router.Handle("POST /files/upload", echo("upload"))
+	router.Handle("/", echo("root"))
+
+	tests := []struct {
+		method string
+		path   string
+		status int
+		body   string
+		allow  string
+	}{
+		{http.MethodGet, "/v1/users/42", http.StatusOK, "get-user:42", ""},
+		{http.MethodHead, "/v1/users/42", http.StatusOK, "get-user:42", ""},
+		{http.MethodDelete, "/v1/users/42", http.StatusOK, "delete-user:42", ""},
+		{http.MethodGet, "/v1/users/me", http.StatusOK, "me:", ""},
+		{http.MethodDelete, "/v1/users/me", http.StatusOK, "delete-user:me", ""},
+		{http.MethodPut, "/v1/users/42", http.StatusMethodNotAllowed, "", "DELETE, GET, HEAD, OPTIONS"},
+		{http.MethodPut, "/v1/users/me", http.StatusMethodNotAllowed, "", "GET, HEAD, OPTIONS"},
+		{http.MethodOptions, "/v1/users/42", http.StatusNoContent, "", "DELETE, GET, HEAD, OPTIONS"},
+		{http.MethodOptions, "/unknown/path", http.StatusNotFound, "", ""},
+		{http.MethodGet, "/v1/users/", http.StatusNotFound, "", ""},
+		{http.MethodGet, "/v1/users/42/posts", http.StatusNotFound, "", ""},
+		{http.MethodPost, "/files/a/b/c.txt", http.StatusOK, "files:a/b/c.txt", ""},
+		{http.MethodPost, "/files/upload", http.StatusOK, "upload:", ""},
+		{http.MethodGet, "/files/upload", http.StatusOK, "files:upload", ""},
+		{http.MethodGet, "/files", http.StatusNotFound, "", ""},
+		{http.MethodGet, "/", http.StatusOK, "root:", ""},
+		{http.MethodGet, "/unknown", http.StatusNotFound, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("expecting status code: %d but got: %d", tt.status, rec.Code)
+			}
+
+			if tt.status == http.StatusOK && rec.Body.String() != tt.body {
+				t.Fatalf("expecting response body: %q but got: %q", tt.body, rec.Body.String())
+			}
+
+			if allow := rec.Header().Get("Allow"); allow != tt.allow {
+				t.Fatalf("expecting allow header: %q but got: %q", tt.allow, allow)
+			}
+		})
+	}
+}
+
+func TestRouter_CleanPath(t *testing.T) {
+	router := NewRouter(nil)
+	router.Handle("/v1/users/{id}", HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		return nil
+	}))
+
+	tests := []struct {
+		method   string
+		path     string
+		status   int
+		location string
+	}{
+		{http.MethodGet, "/v1//users/42", http.StatusMovedPermanently, "/v1/users/42"},
+		{http.MethodGet, "/v1/./users/42?q=1", http.StatusMovedPermanently, "/v1/users/42?q=1"},
+		{http.MethodHead, "/v1/posts/../users/42", http.StatusMovedPermanently, "/v1/users/42"},
+		{http.MethodDelete, "/v1/users/42/.", http.StatusPermanentRedirect, "/v1/users/42"},
+		{http.MethodGet, "/v1/users//", http.StatusMovedPermanently, "/v1/users/"},
+		{http.MethodGet, "/v1/users/42", http.StatusOK, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+
+			if rec.Code != tt.status {
+				t.Fatalf("expecting status code: %d but got: %d", tt.status, rec.Code)
+			}
+
+			if location := rec.Header().Get("Location"); location != tt.location {
+				t.Fatalf("expecting location: %q but got: %q", tt.location, location)
+			}
+		})
+	}
+}
+
+func TestRouter_HandlePanics(t *testing.T) {
+	handler := HandlerFunc(func(w http.ResponseWriter, r *http.Request) error { return nil })
+
+	tests := map[string][]string{
+		"duplicate route":       {"GET /users/{id}", "GET /users/{id}"},
+		"conflicting parameter": {"GET /users/{id}", "DELETE /users/{name}"},
+		"wildcard not last":     {"/files/{path...}/raw"},
+		"missing slash":         {"GET users"},
+		"empty parameter name":  {"GET /users/{}"},
+		"empty wildcard name":   {"GET /files/{...}"},
+	}
+
+	for name, patterns := range tests {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("expecting panics")
+				}
+			}()
+
+			router := NewRouter(nil)
+			for _, pattern := range patterns {
+				router.Handle(pattern, handler)
+			}
+		})
+	}
+}
+
+func TestRouter_NotFoundRunsGlobalMiddleware(t *testing.T) {
+	called := false
+	gm := func(handler Handler) Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) error {
+			called = true
+			return handler.ServeHTTP(w, r)
+		}
+		return HandlerFunc(fn)
+	}
+
+	router := NewRouter(nil, gm)
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/missing", nil)
+	router.ServeHTTP(rec, req)
+
+	if !called {
+		t.Fatalf("expecting global middleware is called")
+	}
+
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expecting status code: %d but got: %d", http.StatusNotFound, rec.Code)
+	}
+}
+
+func TestRouter_StateRoute(t *testing.T) {
+	var route string
+	observer := func(handler Handler) Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) error {
+			state, _ := GetState(r.Context())
+			route = state.Route
+			return handler.ServeHTTP(w, r)
+		}
+		return HandlerFunc(fn)
+	}
+
+	router := NewRouter(nil, observer)
+	router.Group("/v1").Handle("GET /users/{id}", HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		return nil
+	}))
+
+	tests := map[string]string{
+		"GET /v1/users/42":  "/v1/users/{id}",
+		"POST /v1/users/42": "/v1/users/{id}",
+		"GET /v1/posts":     "",
+	}
+
+	for request, expected := range tests {
+		method, path := parsePattern(request)
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, path, nil))
+
+		if route != expected {
+			t.Fatalf("%s: expecting route %q but got %q", request, expected, route)
+		}
+	}
+}
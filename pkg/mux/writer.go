@@ -0,0 +1,144 @@
+package mux
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+// responseWriter is a http.ResponseWriter that records the status code,
+// the bytes written and the time to first byte into the request State.
+type responseWriter struct {
+	http.ResponseWriter
+	state *State
+}
+
+// wrapWriter wraps the given w with responseWriter.
+//
+// The returned writer implements http.Flusher, http.Hijacker and io.ReaderFrom
+// only if w implements them, so the type assertion done by the handler still
+// tells the truth about the inner writer.
+func wrapWriter(w http.ResponseWriter, s *State) http.ResponseWriter {
+	rw := &responseWriter{ResponseWriter: w, state: s}
+
+	_, isFlusher := w.(http.Flusher)
+	_, isHijacker := w.(http.Hijacker)
+	_, isReaderFrom := w.(io.ReaderFrom)
+
+	f, h, rf := flusher{rw}, hijacker{rw}, readerFrom{rw}
+
+	switch {
+	case isFlusher && isHijacker && isReaderFrom:
+		return struct {
+			*responseWriter
+			http.Flusher
+			http.Hijacker
+			io.ReaderFrom
+		}{rw, f, h, rf}
+	case isFlusher && isHijacker:
+		return struct {
+			*responseWriter
+			http.Flusher
+			http.Hijacker
+		}{rw, f, h}
+	case isFlusher && isReaderFrom:
+		return struct {
+			*responseWriter
+			http.Flusher
+			io.ReaderFrom
+		}{rw, f, rf}
+	case isHijacker && isReaderFrom:
+		return struct {
+			*responseWriter
+			http.Hijacker
+			io.ReaderFrom
+		}{rw, h, rf}
+	case isFlusher:
+		return struct {
+			*responseWriter
+			http.Flusher
+		}{rw, f}
+	case isHijacker:
+		return struct {
+			*responseWriter
+			http.Hijacker
+		}{rw, h}
+	case isReaderFrom:
+		return struct {
+			*responseWriter
+			io.ReaderFrom
+		}{rw, rf}
+	default:
+		return rw
+	}
+}
+
+// Unwrap returns the inner writer.
+// It is used by http.ResponseController since Go 1.20.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	// the informational status is not the final status,
+	// except 101 Switching Protocols.
+	informational := code >= 100 && code < 200 && code != http.StatusSwitchingProtocols
+	if !w.state.WroteHeader && !informational {
+		w.state.WroteHeader = true
+		w.state.StatusCode = code
+		w.state.TimeToFirstByte = time.Since(w.state.RequestCreated)
+	}
+
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if !w.state.WroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	n, err := w.ResponseWriter.Write(b)
+	w.state.BytesWritten += int64(n)
+	return n, err
+}
+
+// flusher implements http.Flusher for responseWriter.
+type flusher struct{ w *responseWriter }
+
+func (f flusher) Flush() {
+	// flushing sends the header, the default status is 200 OK.
+	if !f.w.state.WroteHeader {
+		f.w.WriteHeader(http.StatusOK)
+	}
+
+	f.w.ResponseWriter.(http.Flusher).Flush()
+}
+
+// hijacker implements http.Hijacker for responseWriter.
+type hijacker struct{ w *responseWriter }
+
+func (h hijacker) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := h.w.ResponseWriter.(http.Hijacker).Hijack()
+	if err == nil && !h.w.state.WroteHeader {
+		// the connection is taken over, nothing can be written by the Router anymore.
+		h.w.state.WroteHeader = true
+		h.w.state.TimeToFirstByte = time.Since(h.w.state.RequestCreated)
+	}
+
+	return conn, rw, err
+}
+
+// readerFrom implements io.ReaderFrom for responseWriter.
+type readerFrom struct{ w *responseWriter }
+
+func (r readerFrom) ReadFrom(src io.Reader) (int64, error) {
+	if !r.w.state.WroteHeader {
+		r.w.WriteHeader(http.StatusOK)
+	}
+
+	n, err := r.w.ResponseWriter.(io.ReaderFrom).ReadFrom(src)
+	r.w.state.BytesWritten += n
+	return n, err
+}
@@ -0,0 +1,152 @@
+package mux
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRouter_RecordsState(t *testing.T) {
+	var state *State
+	observer := func(handler Handler) Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) error {
+			state, _ = GetState(r.Context())
+			return handler.ServeHTTP(w, r)
+		}
+		return HandlerFunc(fn)
+	}
+
+	router := NewRouter(nil, observer)
+	router.Handle("/not-found", HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		time.Sleep(time.Millisecond)
+		http.NotFound(w, r)
+		return nil
+	}))
+
+	router.Handle("/written", HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = io.WriteString(w, "partial")
+		return errors.New("failed after write")
+	}))
+
+	t.Run("direct write", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/not-found", nil))
+
+		if state.StatusCode != http.StatusNotFound {
+			t.Fatalf("expecting state status code: %d but got: %d", http.StatusNotFound, state.StatusCode)
+		}
+
+		if state.BytesWritten != int64(rec.Body.Len()) {
+			t.Fatalf("expecting bytes written: %d but got: %d", rec.Body.Len(), state.BytesWritten)
+		}
+
+		if state.TimeToFirstByte < time.Millisecond {
+			t.Fatalf("expecting time to first byte is recorded but got: %s", state.TimeToFirstByte)
+		}
+	})
+
+	t.Run("error after write", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/written", nil))
+
+		if rec.Code != http.StatusAccepted || rec.Body.String() != "partial" {
+			t.Fatalf("expecting response is not replaced but got: %d %q", rec.Code, rec.Body.String())
+		}
+
+		if state.StatusCode != http.StatusAccepted || state.Err == nil {
+			t.Fatalf("expecting status and error are recorded but got: %+v", state)
+		}
+	})
+}
+
+// fakeWriter implements http.ResponseWriter only.
+type fakeWriter struct {
+	http.ResponseWriter
+}
+
+// fakeFullWriter implements http.ResponseWriter, http.Flusher, http.Hijacker and io.ReaderFrom.
+type fakeFullWriter struct {
+	*httptest.ResponseRecorder
+	readFrom bool
+	hijacked bool
+}
+
+func (f *fakeFullWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	f.hijacked = true
+	return nil, nil, nil
+}
+
+func (f *fakeFullWriter) ReadFrom(r io.Reader) (int64, error) {
+	f.readFrom = true
+	return io.Copy(f.ResponseRecorder, r)
+}
+
+func TestWrapWriter(t *testing.T) {
+	t.Run("plain writer", func(t *testing.T) {
+		w := wrapWriter(fakeWriter{httptest.NewRecorder()}, &State{})
+		if _, ok := w.(http.Flusher); ok {
+			t.Fatalf("expecting not a flusher")
+		}
+		if _, ok := w.(http.Hijacker); ok {
+			t.Fatalf("expecting not a hijacker")
+		}
+		if _, ok := w.(io.ReaderFrom); ok {
+			t.Fatalf("expecting not a reader from")
+		}
+	})
+
+	t.Run("flusher only", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		state := State{}
+		w := wrapWriter(rec, &state)
+
+		f, ok := w.(http.Flusher)
+		if !ok {
+			t.Fatalf("expecting a flusher")
+		}
+
+		if _, ok := w.(http.Hijacker); ok {
+			t.Fatalf("expecting not a hijacker")
+		}
+
+		f.Flush()
+		if !rec.Flushed || !state.WroteHeader || state.StatusCode != http.StatusOK {
+			t.Fatalf("expecting flushed with status 200 but got: %+v", state)
+		}
+	})
+
+	t.Run("all interfaces", func(t *testing.T) {
+		inner := &fakeFullWriter{ResponseRecorder: httptest.NewRecorder()}
+		state := State{}
+		w := wrapWriter(inner, &state)
+
+		rf, ok := w.(io.ReaderFrom)
+		if !ok {
+			t.Fatalf("expecting a reader from")
+		}
+
+		n, err := rf.ReadFrom(strings.NewReader("hello"))
+		if err != nil || n != 5 || !inner.readFrom {
+			t.Fatalf("expecting inner ReadFrom is used but got: %d %v", n, err)
+		}
+
+		if state.BytesWritten != 5 {
+			t.Fatalf("expecting bytes written: 5 but got: %d", state.BytesWritten)
+		}
+
+		if _, _, err := w.(http.Hijacker).Hijack(); err != nil || !inner.hijacked {
+			t.Fatalf("expecting inner Hijack is used")
+		}
+
+		if _, ok := w.(http.Flusher); !ok {
+			t.Fatalf("expecting a flusher")
+		}
+	})
+}
@@ -0,0 +1,236 @@
+package openapi
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/josestg/justforfun/pkg/mux"
+)
+
+// Version is the OpenAPI Specification version of the generated document.
+const Version = "3.0.3"
+
+// anyMethods are the methods documented for the routes that serve all methods.
+var anyMethods = []string{
+	http.MethodGet,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+}
+
+// Document represents the OpenAPI 3.0 document.
+// see: https://spec.openapis.org/oas/v3.0.3.
+type Document struct {
+	OpenAPI    string               `json:"openapi"`
+	Info       Info                 `json:"info"`
+	Paths      map[string]*PathItem `json:"paths"`
+	Components *Components          `json:"components,omitempty"`
+}
+
+// Info represents the API metadata.
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+// PathItem holds the operations of a single path.
+type PathItem struct {
+	Get     *Operation `json:"get,omitempty"`
+	Put     *Operation `json:"put,omitempty"`
+	Post    *Operation `json:"post,omitempty"`
+	Delete  *Operation `json:"delete,omitempty"`
+	Options *Operation `json:"options,omitempty"`
+	Head    *Operation `json:"head,omitempty"`
+	Patch   *Operation `json:"patch,omitempty"`
+}
+
+// Operation describes a single API operation on a path.
+type Operation struct {
+	Summary     string               `json:"summary,omitempty"`
+	OperationID string               `json:"operationId,omitempty"`
+	Parameters  []Parameter          `json:"parameters,omitempty"`
+	RequestBody *RequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*Response `json:"responses"`
+	Middleware  []string             `json:"x-middleware,omitempty"`
+}
+
+// Parameter describes a single operation parameter.
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *Schema `json:"schema"`
+}
+
+// RequestBody describes a single request body.
+type RequestBody struct {
+	Required bool                  `json:"required"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+// Response describes a single response of an operation.
+type Response struct {
+	Description string                `json:"description"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+// MediaType holds the schema of the media type.
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Components holds the reusable schemas.
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas,omitempty"`
+}
+
+// Generator knows how to generate the OpenAPI document from the routes.
+type Generator struct {
+	info        Info
+	contentType string
+}
+
+// NewGenerator creates a new Generator.
+// The request and response bodies are documented as application/json.
+func NewGenerator(info Info) *Generator {
+	return &Generator{
+		info:        info,
+		contentType: "application/json",
+	}
+}
+
+// Generate generates the OpenAPI document from the given routes.
+func (g *Generator) Generate(routes []mux.Route) *Document {
+	schemas := newSchemaRegistry()
+
+	doc := Document{
+		OpenAPI: Version,
+		Info:    g.info,
+		Paths:   make(map[string]*PathItem),
+	}
+
+	for _, route := range routes {
+		path, params := convertPattern(route.Pattern)
+
+		item, exist := doc.Paths[path]
+		if !exist {
+			item = &PathItem{}
+			doc.Paths[path] = item
+		}
+
+		methods := []string{route.Method}
+		if route.Method == "" {
+			methods = anyMethods
+		}
+
+		for _, method := range methods {
+			op := g.operation(schemas, method, path, params, route)
+			item.set(method, op)
+		}
+	}
+
+	if len(schemas.components) != 0 {
+		doc.Components = &Components{Schemas: schemas.components}
+	}
+
+	return &doc
+}
+
+// operation creates the operation of the given route.
+func (g *Generator) operation(schemas *schemaRegistry, method string, path string, params []string, route mux.Route) *Operation {
+	op := Operation{
+		Summary:     route.Summary,
+		OperationID: operationID(method, path),
+		Responses:   make(map[string]*Response),
+		Middleware:  route.Middleware,
+	}
+
+	for _, name := range params {
+		op.Parameters = append(op.Parameters, Parameter{
+			Name:     name,
+			In:       "path",
+			Required: true,
+			Schema:   &Schema{Type: "string"},
+		})
+	}
+
+	if route.Request != nil {
+		op.RequestBody = &RequestBody{
+			Required: true,
+			Content: map[string]*MediaType{
+				g.contentType: {Schema: schemas.schemaOf(route.Request)},
+			},
+		}
+	}
+
+	for status, v := range route.Responses {
+		resp := Response{Description: http.StatusText(status)}
+		if v != nil {
+			resp.Content = map[string]*MediaType{
+				g.contentType: {Schema: schemas.schemaOf(v)},
+			}
+		}
+		op.Responses[strconv.Itoa(status)] = &resp
+	}
+
+	// the responses object must contain at least one response.
+	if len(op.Responses) == 0 {
+		op.Responses["default"] = &Response{Description: "Default response"}
+	}
+
+	return &op
+}
+
+// set sets the operation for the given method.
+func (p *PathItem) set(method string, op *Operation) {
+	switch method {
+	case http.MethodGet:
+		p.Get = op
+	case http.MethodPut:
+		p.Put = op
+	case http.MethodPost:
+		p.Post = op
+	case http.MethodDelete:
+		p.Delete = op
+	case http.MethodOptions:
+		p.Options = op
+	case http.MethodHead:
+		p.Head = op
+	case http.MethodPatch:
+		p.Patch = op
+	}
+}
+
+// convertPattern converts the mux path pattern into the OpenAPI path template
+// and returns the parameter names. The wildcard `{path...}` becomes `{path}`.
+func convertPattern(pattern string) (string, []string) {
+	segments := strings.Split(pattern, "/")
+	params := make([]string, 0)
+	for i, seg := range segments {
+		if !strings.HasPrefix(seg, "{") || !strings.HasSuffix(seg, "}") {
+			continue
+		}
+
+		name := strings.TrimSuffix(seg[1:len(seg)-1], "...")
+		segments[i] = "{" + name + "}"
+		params = append(params, name)
+	}
+
+	return strings.Join(segments, "/"), params
+}
+
+// operationID creates the operation id from the method and path,
+// for example `GET /v1/users/{id}` becomes "get_v1_users_id".
+func operationID(method string, path string) string {
+	parts := []string{strings.ToLower(method)}
+	for _, seg := range strings.Split(path, "/") {
+		seg = strings.Trim(seg, "{}")
+		if seg != "" {
+			parts = append(parts, seg)
+		}
+	}
+	return strings.Join(parts, "_")
+}
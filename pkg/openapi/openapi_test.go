@@ -0,0 +1,106 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/josestg/justforfun/pkg/mux"
+)
+
+type testBase struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type testUser struct {
+	testBase
+	Name    string             `json:"name"`
+	Email   *string            `json:"email"`
+	Age     int                `json:"age,omitempty"`
+	Tags    []string           `json:"tags"`
+	Meta    map[string]float64 `json:"meta,omitempty"`
+	Friends []*testUser        `json:"friends,omitempty"`
+	secret  string
+	Ignored string              `json:"-"`
+	Scores  map[string][]uint16 `json:"scores,omitempty"`
+}
+
+func TestGenerator_Generate(t *testing.T) {
+	handler := mux.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error { return nil })
+
+	router := mux.NewRouter(nil)
+	router.Handle("GET /v1/users/{id}", handler).
+		Describe("Shows a user.").
+		Returns(http.StatusOK, testUser{})
+
+	router.Handle("POST /v1/users", handler).
+		Accepts(testUser{}).
+		Returns(http.StatusCreated, testUser{}).
+		Returns(http.StatusNoContent, nil)
+
+	router.Handle("/files/{path...}", handler)
+
+	doc := NewGenerator(Info{Title: "test", Version: "1"}).Generate(router.Routes())
+
+	if doc.OpenAPI != Version {
+		t.Fatalf("expecting version %s but got %s", Version, doc.OpenAPI)
+	}
+
+	get := doc.Paths["/v1/users/{id}"].Get
+	if get == nil || get.Summary != "Shows a user." || get.OperationID != "get_v1_users_id" {
+		t.Fatalf("expecting get operation but got %+v", get)
+	}
+
+	expectedParams := []Parameter{{Name: "id", In: "path", Required: true, Schema: &Schema{Type: "string"}}}
+	if !reflect.DeepEqual(expectedParams, get.Parameters) {
+		t.Fatalf("expecting parameters %+v but got %+v", expectedParams, get.Parameters)
+	}
+
+	ref := get.Responses["200"].Content["application/json"].Schema.Ref
+	if ref != "#/components/schemas/openapi.testUser" {
+		t.Fatalf("expecting component reference but got %q", ref)
+	}
+
+	post := doc.Paths["/v1/users"].Post
+	if post.RequestBody == nil || post.Responses["204"].Content != nil || post.Responses["201"] == nil {
+		t.Fatalf("expecting post operation but got %+v", post)
+	}
+
+	files := doc.Paths["/files/{path}"]
+	if files.Get == nil || files.Delete == nil || files.Get.Responses["default"] == nil {
+		t.Fatalf("expecting any method route is documented but got %+v", files)
+	}
+
+	user := doc.Components.Schemas["openapi.testUser"]
+	expectedUser := &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"id":         {Type: "string"},
+			"created_at": {Type: "string", Format: "date-time"},
+			"name":       {Type: "string"},
+			"email":      {Type: "string", Nullable: true},
+			"age":        {Type: "integer", Format: "int64"},
+			"tags":       {Type: "array", Items: &Schema{Type: "string"}},
+			"meta":       {Type: "object", AdditionalProperties: &Schema{Type: "number", Format: "double"}},
+			"friends":    {Type: "array", Items: &Schema{Ref: "#/components/schemas/openapi.testUser"}},
+			"scores": {Type: "object", AdditionalProperties: &Schema{
+				Type:  "array",
+				Items: &Schema{Type: "integer", Format: "int32"},
+			}},
+		},
+		Required: []string{"id", "created_at", "name", "tags"},
+	}
+
+	if !reflect.DeepEqual(expectedUser, user) {
+		a, _ := json.Marshal(expectedUser)
+		b, _ := json.Marshal(user)
+		t.Fatalf("expecting schema %s but got %s", a, b)
+	}
+
+	if _, err := json.Marshal(doc); err != nil {
+		t.Fatalf("expecting error nil but got %v", err)
+	}
+}
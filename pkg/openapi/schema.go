@@ -0,0 +1,232 @@
+package openapi
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	rawMessageType    = reflect.TypeOf(json.RawMessage{})
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// Schema represents the OpenAPI schema object.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+// schemaRegistry knows how to create the schema from the Go types.
+// The named struct types are registered as components and referenced by $ref,
+// so the recursive types are supported.
+type schemaRegistry struct {
+	components map[string]*Schema
+	names      map[reflect.Type]string
+}
+
+func newSchemaRegistry() *schemaRegistry {
+	return &schemaRegistry{
+		components: make(map[string]*Schema),
+		names:      make(map[reflect.Type]string),
+	}
+}
+
+// schemaOf returns the schema of the type of the given value.
+func (s *schemaRegistry) schemaOf(v interface{}) *Schema {
+	return s.schema(reflect.TypeOf(v))
+}
+
+// schema returns the schema of the given type.
+// The schema follows the encoding/json rules.
+func (s *schemaRegistry) schema(t reflect.Type) *Schema {
+	if t == nil {
+		return &Schema{}
+	}
+
+	if t.Kind() == reflect.Ptr {
+		schema := s.schema(t.Elem())
+		if schema.Ref == "" {
+			schema.Nullable = true
+		}
+		return schema
+	}
+
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == rawMessageType:
+		return &Schema{}
+	case t.Implements(jsonMarshalerType), reflect.PtrTo(t).Implements(jsonMarshalerType):
+		// the JSON form is unknown.
+		return &Schema{}
+	case t.Implements(textMarshalerType), reflect.PtrTo(t).Implements(textMarshalerType):
+		return &Schema{Type: "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 && t.Kind() == reflect.Slice {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: s.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: s.schema(t.Elem())}
+	case reflect.Struct:
+		return s.structSchema(t)
+	default:
+		// interface and the others can hold any value.
+		return &Schema{}
+	}
+}
+
+// structSchema returns a reference to the component of the named struct type,
+// or the inline object schema of the anonymous struct type.
+func (s *schemaRegistry) structSchema(t reflect.Type) *Schema {
+	if t.Name() == "" {
+		return s.objectSchema(t)
+	}
+
+	name, exist := s.names[t]
+	if !exist {
+		name = s.componentName(t)
+		s.names[t] = name
+
+		// registers the name before creating the object schema,
+		// so the recursive field refers to this component.
+		s.components[name] = &Schema{}
+		*s.components[name] = *s.objectSchema(t)
+	}
+
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+// componentName returns a unique component name for the type,
+// for example "health.Report".
+func (s *schemaRegistry) componentName(t reflect.Type) string {
+	pkg := t.PkgPath()
+	if i := strings.LastIndex(pkg, "/"); i >= 0 {
+		pkg = pkg[i+1:]
+	}
+
+	base := t.Name()
+	if pkg != "" {
+		base = pkg + "." + base
+	}
+
+	// generic-like names contain characters that are not allowed.
+	base = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '_', r == '-':
+			return r
+		default:
+			return '_'
+		}
+	}, base)
+
+	name := base
+	for i := 2; ; i++ {
+		if _, taken := s.components[name]; !taken {
+			return name
+		}
+		name = base + "_" + strconv.Itoa(i)
+	}
+}
+
+// objectSchema returns the object schema of the struct type.
+func (s *schemaRegistry) objectSchema(t reflect.Type) *Schema {
+	schema := Schema{
+		Type:       "object",
+		Properties: make(map[string]*Schema),
+	}
+
+	s.collectFields(t, &schema)
+	return &schema
+}
+
+// collectFields collects the exported fields into the schema.
+// The fields of the embedded struct without json tag are promoted.
+func (s *schemaRegistry) collectFields(t reflect.Type, schema *Schema) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name, opts := parseTag(tag)
+
+		if field.Anonymous && name == "" {
+			ft := field.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+
+			if ft.Kind() == reflect.Struct {
+				s.collectFields(ft, schema)
+				continue
+			}
+		}
+
+		if field.PkgPath != "" {
+			continue // unexported.
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		fs := s.schema(field.Type)
+		if opts.contains("string") {
+			fs = &Schema{Type: "string"}
+		}
+
+		schema.Properties[name] = fs
+		if !opts.contains("omitempty") && field.Type.Kind() != reflect.Ptr {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+}
+
+// tagOptions is the string following a comma in a struct field's "json" tag.
+type tagOptions string
+
+func parseTag(tag string) (string, tagOptions) {
+	if i := strings.Index(tag, ","); i >= 0 {
+		return tag[:i], tagOptions(tag[i+1:])
+	}
+	return tag, ""
+}
+
+func (o tagOptions) contains(name string) bool {
+	for _, opt := range strings.Split(string(o), ",") {
+		if opt == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,37 @@
+package pqx
+
+import (
+	"database/sql"
+
+	"github.com/josestg/justforfun/pkg/metrics"
+)
+
+// NewStatsCollector creates a metrics.Collector of the connection pool statistics
+// of the given db. The name is the value of the db label.
+func NewStatsCollector(name string, db *sql.DB) metrics.Collector {
+	return metrics.CollectorFunc(func() []metrics.Family {
+		s := db.Stats()
+		labels := []metrics.Label{{Name: "db", Value: name}}
+
+		family := func(name, help string, typ metrics.Type, v float64) metrics.Family {
+			return metrics.Family{
+				Name:    name,
+				Help:    help,
+				Type:    typ,
+				Samples: []metrics.Sample{{Name: name, Labels: labels, Value: v}},
+			}
+		}
+
+		return []metrics.Family{
+			family("sql_max_open_connections", "Maximum number of open connections to the database.", metrics.TypeGauge, float64(s.MaxOpenConnections)),
+			family("sql_open_connections", "Number of established connections both in use and idle.", metrics.TypeGauge, float64(s.OpenConnections)),
+			family("sql_in_use_connections", "Number of connections currently in use.", metrics.TypeGauge, float64(s.InUse)),
+			family("sql_idle_connections", "Number of idle connections.", metrics.TypeGauge, float64(s.Idle)),
+			family("sql_wait_count_total", "Total number of connections waited for.", metrics.TypeCounter, float64(s.WaitCount)),
+			family("sql_wait_duration_seconds_total", "Total time blocked waiting for a new connection.", metrics.TypeCounter, s.WaitDuration.Seconds()),
+			family("sql_max_idle_closed_total", "Total number of connections closed due to SetMaxIdleConns.", metrics.TypeCounter, float64(s.MaxIdleClosed)),
+			family("sql_max_idle_time_closed_total", "Total number of connections closed due to SetConnMaxIdleTime.", metrics.TypeCounter, float64(s.MaxIdleTimeClosed)),
+			family("sql_max_lifetime_closed_total", "Total number of connections closed due to SetConnMaxLifetime.", metrics.TypeCounter, float64(s.MaxLifetimeClosed)),
+		}
+	})
+}
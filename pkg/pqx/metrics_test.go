@@ -0,0 +1,77 @@
+package pqx
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+
+	"github.com/josestg/justforfun/pkg/metrics"
+)
+
+func TestNewStatsCollector(t *testing.T) {
+	db := sql.OpenDB(fakeConnector{})
+	defer db.Close()
+	db.SetMaxOpenConns(3)
+
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("expecting nil error but got %v", err)
+	}
+	defer conn.Close()
+
+	families := NewStatsCollector("main", db).Collect()
+
+	values := make(map[string]float64, len(families))
+	for _, f := range families {
+		if len(f.Samples) != 1 || len(f.Samples[0].Labels) != 1 || f.Samples[0].Labels[0] != (metrics.Label{Name: "db", Value: "main"}) {
+			t.Fatalf("%s: expecting a single sample labeled db=main but got %+v", f.Name, f.Samples)
+		}
+
+		if strings.HasSuffix(f.Name, "_total") != (f.Type == metrics.TypeCounter) {
+			t.Fatalf("%s: expecting the counters are suffixed by _total but got %s", f.Name, f.Type)
+		}
+
+		values[f.Name] = f.Samples[0].Value
+	}
+
+	tests := map[string]float64{
+		"sql_max_open_connections":        3,
+		"sql_open_connections":            1,
+		"sql_in_use_connections":          1,
+		"sql_idle_connections":            0,
+		"sql_wait_count_total":            0,
+		"sql_wait_duration_seconds_total": 0,
+		"sql_max_idle_closed_total":       0,
+		"sql_max_idle_time_closed_total":  0,
+		"sql_max_lifetime_closed_total":   0,
+	}
+
+	if len(values) != len(tests) {
+		t.Fatalf("expecting %d families but got %d", len(tests), len(values))
+	}
+
+	for name, want := range tests {
+		if got, exist := values[name]; !exist || got != want {
+			t.Errorf("%s: expecting %v but got %v", name, want, got)
+		}
+	}
+
+	// the collector reads the current stats on every scrape.
+	_ = conn.Close()
+
+	reg := metrics.NewRegistry()
+	reg.MustRegister(NewStatsCollector("main", db))
+
+	var buf bytes.Buffer
+	if err := reg.WriteText(&buf); err != nil {
+		t.Fatalf("expecting nil error but got %v", err)
+	}
+
+	for _, line := range []string{`sql_in_use_connections{db="main"} 0`, `sql_idle_connections{db="main"} 1`} {
+		if !strings.Contains(buf.String(), line) {
+			t.Fatalf("expecting %s in\n%s", line, buf.String())
+		}
+	}
+}
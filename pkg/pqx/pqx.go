@@ -8,7 +8,7 @@ import (
 	"net/url"
 	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 const (
@@ -62,13 +62,18 @@ func (c *Config) DSN() string {
 // The returned DB is safe for concurrent use by multiple goroutines and maintains its own pool of idle connections.
 // Thus, the Open function should be called just once.
 // It is rarely necessary to close a DB.
+//
+// The queries of the returned DB are traced as the child spans of the span in
+// the query context, see tracing.Start.
 func Open(cfg *Config) (*sql.DB, error) {
 	dsn := cfg.DSN()
-	db, err := sql.Open(Driver, dsn)
+	connector, err := pq.NewConnector(dsn)
 	if err != nil {
 		return nil, fmt.Errorf("%w: open db connection", err)
 	}
 
+	db := sql.OpenDB(&tracedConnector{Connector: connector})
+
 	db.SetMaxOpenConns(cfg.MaxOpenConnection)
 	db.SetMaxIdleConns(cfg.MaxIdleConnection)
 
@@ -100,10 +105,24 @@ func CheckConnection(ctx context.Context, maxTries int, db *sql.DB) error {
 
 	// Make one round trip to database to make sure if the database ready to
 	// handle query.
-	_, err := db.ExecContext(ctx, "SELECT true;")
-	if err != nil {
+	return roundTrip(ctx, db)
+}
+
+// Ping returns an error if the database is not ready to execute a query.
+// Unlike CheckConnection, it does not retry, so it suits the periodic checks
+// of a running service, such as the readiness probe.
+//
+// Use context.WithTimeout make a deadline.
+func Ping(ctx context.Context, db *sql.DB) error {
+	if err := db.PingContext(ctx); err != nil {
 		return err
 	}
 
-	return nil
+	return roundTrip(ctx, db)
+}
+
+// roundTrip executes the one-round-trip query.
+func roundTrip(ctx context.Context, db *sql.DB) error {
+	_, err := db.ExecContext(ctx, "SELECT true;")
+	return err
 }
@@ -0,0 +1,118 @@
+package pqx
+
+import (
+	"context"
+	"database/sql/driver"
+
+	"github.com/josestg/justforfun/pkg/tracing"
+)
+
+// tracedConnector is a connector that creates the traced connections.
+type tracedConnector struct {
+	driver.Connector
+}
+
+func (c *tracedConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &tracedConn{Conn: conn}, nil
+}
+
+// tracedConn is a connection that starts a child span for each query,
+// statement preparation and transaction when the context holds a span.
+// Without a span in the context, it just delegates to the underlying connection.
+type tracedConn struct {
+	driver.Conn
+}
+
+func (c *tracedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	ctx, span := startSpan(ctx, "db.query", query)
+	defer span.End()
+
+	rows, err := queryer.QueryContext(ctx, query, args)
+	if err != driver.ErrSkip {
+		span.SetError(err)
+	}
+	return rows, err
+}
+
+func (c *tracedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	ctx, span := startSpan(ctx, "db.exec", query)
+	defer span.End()
+
+	res, err := execer.ExecContext(ctx, query, args)
+	if err != driver.ErrSkip {
+		span.SetError(err)
+	}
+	return res, err
+}
+
+func (c *tracedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	ctx, span := startSpan(ctx, "db.prepare", query)
+	defer span.End()
+
+	var (
+		stmt driver.Stmt
+		err  error
+	)
+
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		stmt, err = preparer.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.Conn.Prepare(query)
+	}
+
+	span.SetError(err)
+	return stmt, err
+}
+
+func (c *tracedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	ctx, span := startSpan(ctx, "db.begin", "")
+	defer span.End()
+
+	var (
+		tx  driver.Tx
+		err error
+	)
+
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		tx, err = beginner.BeginTx(ctx, opts)
+	} else {
+		tx, err = c.Conn.Begin()
+	}
+
+	span.SetError(err)
+	return tx, err
+}
+
+func (c *tracedConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+// startSpan starts a database span as the child of the span in ctx.
+func startSpan(ctx context.Context, name, statement string) (context.Context, *tracing.Span) {
+	attributes := map[string]interface{}{
+		"db.system": "postgresql",
+	}
+
+	if statement != "" {
+		attributes["db.statement"] = statement
+	}
+
+	return tracing.Start(ctx, name, tracing.WithKind(tracing.KindClient), tracing.WithAttributes(attributes))
+}
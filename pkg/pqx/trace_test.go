@@ -0,0 +1,205 @@
+package pqx
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/josestg/justforfun/pkg/tracing"
+)
+
+// errFake is the error of the fake driver for the statements that contain "fail".
+var errFake = errors.New("fake: statement failed")
+
+// fakeConnector creates the fakeConn.
+type fakeConnector struct{}
+
+func (c fakeConnector) Connect(_ context.Context) (driver.Conn, error) { return &fakeConn{}, nil }
+func (c fakeConnector) Driver() driver.Driver                          { return fakeDriver{} }
+
+type fakeDriver struct{}
+
+func (d fakeDriver) Open(_ string) (driver.Conn, error) { return &fakeConn{}, nil }
+
+// fakeConn is a connection that fails the statements that contain "fail".
+type fakeConn struct{}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	if strings.Contains(query, "fail") {
+		return nil, errFake
+	}
+	return &fakeStmt{}, nil
+}
+
+func (c *fakeConn) Close() error              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) { return fakeTx{}, nil }
+
+func (c *fakeConn) BeginTx(ctx context.Context, _ driver.TxOptions) (driver.Tx, error) {
+	if ctx.Value(failBegin{}) != nil {
+		return nil, errFake
+	}
+	return fakeTx{}, nil
+}
+
+func (c *fakeConn) QueryContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Rows, error) {
+	if strings.Contains(query, "fail") {
+		return nil, errFake
+	}
+	return &fakeRows{}, nil
+}
+
+func (c *fakeConn) ExecContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	if strings.Contains(query, "fail") {
+		return nil, errFake
+	}
+	return driver.RowsAffected(1), nil
+}
+
+// failBegin is the context key that fails the fakeConn.BeginTx.
+type failBegin struct{}
+
+type fakeTx struct{}
+
+func (tx fakeTx) Commit() error   { return nil }
+func (tx fakeTx) Rollback() error { return nil }
+
+type fakeStmt struct{}
+
+func (s *fakeStmt) Close() error                                 { return nil }
+func (s *fakeStmt) NumInput() int                                { return -1 }
+func (s *fakeStmt) Exec(_ []driver.Value) (driver.Result, error) { return driver.RowsAffected(1), nil }
+func (s *fakeStmt) Query(_ []driver.Value) (driver.Rows, error)  { return &fakeRows{}, nil }
+
+// fakeRows has a single row of a single column.
+type fakeRows struct{ done bool }
+
+func (r *fakeRows) Columns() []string { return []string{"n"} }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0] = int64(1)
+	return nil
+}
+
+func TestTracedConn(t *testing.T) {
+	exporter := tracing.NewMemoryExporter()
+	tracer := tracing.NewTracer("test", exporter)
+
+	db := sql.OpenDB(&tracedConnector{Connector: fakeConnector{}})
+	defer db.Close()
+
+	ctx, root := tracer.Start(context.Background(), "root")
+
+	var n int
+	if err := db.QueryRowContext(ctx, "SELECT 1").Scan(&n); err != nil || n != 1 {
+		t.Fatalf("expecting 1 but got %d and error %v", n, err)
+	}
+
+	if _, err := db.QueryContext(ctx, "SELECT fail"); !errors.Is(err, errFake) {
+		t.Fatalf("expecting errFake but got %v", err)
+	}
+
+	if _, err := db.ExecContext(ctx, "UPDATE users SET name = $1", "a"); err != nil {
+		t.Fatalf("expecting nil error but got %v", err)
+	}
+
+	if _, err := db.ExecContext(ctx, "UPDATE fail"); !errors.Is(err, errFake) {
+		t.Fatalf("expecting errFake but got %v", err)
+	}
+
+	stmt, err := db.PrepareContext(ctx, "SELECT 2")
+	if err != nil {
+		t.Fatalf("expecting nil error but got %v", err)
+	}
+	_ = stmt.Close()
+
+	if _, err := db.PrepareContext(ctx, "SELECT fail"); !errors.Is(err, errFake) {
+		t.Fatalf("expecting errFake but got %v", err)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("expecting nil error but got %v", err)
+	}
+	_ = tx.Commit()
+
+	if _, err := db.BeginTx(context.WithValue(ctx, failBegin{}, true), nil); !errors.Is(err, errFake) {
+		t.Fatalf("expecting errFake but got %v", err)
+	}
+
+	root.End()
+
+	tests := []struct {
+		name      string
+		statement string
+		failed    bool
+	}{
+		{"db.query", "SELECT 1", false},
+		{"db.query", "SELECT fail", true},
+		{"db.exec", "UPDATE users SET name = $1", false},
+		{"db.exec", "UPDATE fail", true},
+		{"db.prepare", "SELECT 2", false},
+		{"db.prepare", "SELECT fail", true},
+		{"db.begin", "", false},
+		{"db.begin", "", true},
+	}
+
+	spans := exporter.Spans()
+	if len(spans) != len(tests)+1 {
+		t.Fatalf("expecting %d spans but got %d", len(tests)+1, len(spans))
+	}
+
+	// the spans are exported when they end, the root is the last one.
+	rootData := spans[len(spans)-1]
+	for i, tt := range tests {
+		span := spans[i]
+		if span.Name != tt.name || span.Kind != tracing.KindClient {
+			t.Fatalf("%d: expecting client span %s but got %s %s", i, tt.name, span.Kind, span.Name)
+		}
+
+		if span.TraceID != rootData.TraceID || span.ParentID != rootData.SpanID {
+			t.Fatalf("%d: expecting the child span of the root", i)
+		}
+
+		if span.End.IsZero() || span.End.Before(span.Start) {
+			t.Fatalf("%d: expecting the span is ended", i)
+		}
+
+		statement, _ := span.Attributes["db.statement"].(string)
+		if statement != tt.statement || span.Attributes["db.system"] != "postgresql" {
+			t.Fatalf("%d: expecting statement %q but got %v", i, tt.statement, span.Attributes)
+		}
+
+		if tt.failed && (span.Status != tracing.StatusError || span.StatusMessage != errFake.Error()) {
+			t.Fatalf("%d: expecting the error status but got %s %q", i, span.Status, span.StatusMessage)
+		}
+
+		if !tt.failed && span.Status != tracing.StatusOK {
+			t.Fatalf("%d: expecting the ok status but got %s %q", i, span.Status, span.StatusMessage)
+		}
+	}
+}
+
+// TestTracedConn_WithoutSpan checks the connection just delegates to the
+// driver if the context holds no span.
+func TestTracedConn_WithoutSpan(t *testing.T) {
+	db := sql.OpenDB(&tracedConnector{Connector: fakeConnector{}})
+	defer db.Close()
+
+	var n int
+	if err := db.QueryRowContext(context.Background(), "SELECT 1").Scan(&n); err != nil || n != 1 {
+		t.Fatalf("expecting 1 but got %d and error %v", n, err)
+	}
+
+	if _, err := db.ExecContext(context.Background(), "UPDATE fail"); !errors.Is(err, errFake) {
+		t.Fatalf("expecting errFake but got %v", err)
+	}
+}
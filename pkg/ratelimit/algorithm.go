@@ -0,0 +1,172 @@
+package ratelimit
+
+import (
+	"math"
+	"time"
+)
+
+// Bucket is the persisted state of a single key.
+// Each Algorithm interprets the fields differently.
+type Bucket struct {
+	// Count is the remaining tokens for the token bucket, or the number of
+	// requests in the current window for the sliding window.
+	Count float64
+
+	// Previous is the number of requests in the previous window for the sliding window.
+	Previous float64
+
+	// Stamp is the last refill time for the token bucket, or the start of the
+	// current window for the sliding window.
+	Stamp time.Time
+}
+
+// Decision is the result of taking a request from the Bucket.
+type Decision struct {
+	// Allowed is true if the request is allowed.
+	Allowed bool
+
+	// Limit is the maximum number of requests.
+	Limit int
+
+	// Remaining is the number of requests left.
+	Remaining int
+
+	// Reset is the time until the quota is fully restored.
+	Reset time.Duration
+
+	// RetryAfter is the time until the next request will be allowed.
+	// It is zero if the request is allowed.
+	RetryAfter time.Duration
+}
+
+// Algorithm knows how to take a request from the Bucket.
+type Algorithm interface {
+	// Take takes a single request from the bucket at the given time.
+	Take(b *Bucket, now time.Time) Decision
+
+	// TTL returns how long the idle bucket must be kept.
+	TTL() time.Duration
+}
+
+// tokenBucket implements the token bucket algorithm.
+type tokenBucket struct {
+	limit  int
+	period time.Duration
+}
+
+// TokenBucket creates a token bucket algorithm.
+// The bucket holds at most limit tokens and is refilled by limit tokens each period,
+// so a client can burst up to limit requests and then continue at limit/period.
+func TokenBucket(limit int, period time.Duration) Algorithm {
+	if limit <= 0 || period <= 0 {
+		panic("ratelimit: token bucket limit and period must be positive")
+	}
+
+	return &tokenBucket{limit: limit, period: period}
+}
+
+func (t *tokenBucket) TTL() time.Duration { return t.period }
+
+func (t *tokenBucket) Take(b *Bucket, now time.Time) Decision {
+	limit := float64(t.limit)
+	rate := limit / t.period.Seconds() // tokens per second.
+
+	if b.Stamp.IsZero() {
+		b.Count = limit
+		b.Stamp = now
+	}
+
+	if elapsed := now.Sub(b.Stamp).Seconds(); elapsed > 0 {
+		b.Count = math.Min(limit, b.Count+elapsed*rate)
+		b.Stamp = now
+	}
+
+	d := Decision{Limit: t.limit}
+	if b.Count >= 1 {
+		b.Count--
+		d.Allowed = true
+	} else {
+		d.RetryAfter = seconds((1 - b.Count) / rate)
+	}
+
+	d.Remaining = int(math.Floor(b.Count))
+	d.Reset = seconds((limit - b.Count) / rate)
+	return d
+}
+
+// slidingWindow implements the sliding window counter algorithm.
+type slidingWindow struct {
+	limit  int
+	window time.Duration
+}
+
+// SlidingWindow creates a sliding window counter algorithm.
+// It allows at most limit requests in any window, the number of requests in the
+// window is estimated from the counters of the current and the previous fixed window.
+func SlidingWindow(limit int, window time.Duration) Algorithm {
+	if limit <= 0 || window <= 0 {
+		panic("ratelimit: sliding window limit and window must be positive")
+	}
+
+	return &slidingWindow{limit: limit, window: window}
+}
+
+func (s *slidingWindow) TTL() time.Duration { return 2 * s.window }
+
+func (s *slidingWindow) Take(b *Bucket, now time.Time) Decision {
+	limit := float64(s.limit)
+	start := now.Truncate(s.window)
+
+	if !b.Stamp.Equal(start) {
+		// the previous counter is only meaningful if it is the window right before.
+		if start.Sub(b.Stamp) == s.window {
+			b.Previous = b.Count
+		} else {
+			b.Previous = 0
+		}
+
+		b.Count = 0
+		b.Stamp = start
+	}
+
+	elapsed := now.Sub(start)
+	weight := 1 - float64(elapsed)/float64(s.window)
+	estimated := b.Previous*weight + b.Count
+
+	d := Decision{
+		Limit: s.limit,
+		Reset: start.Add(s.window).Sub(now),
+	}
+
+	if estimated+1 <= limit {
+		b.Count++
+		d.Allowed = true
+		d.Remaining = int(math.Floor(limit - estimated - 1))
+		return d
+	}
+
+	d.RetryAfter = s.retryAfter(b, elapsed)
+	return d
+}
+
+// retryAfter estimates the time until the next request will be allowed.
+func (s *slidingWindow) retryAfter(b *Bucket, elapsed time.Duration) time.Duration {
+	limit := float64(s.limit)
+	window := float64(s.window)
+
+	// the current window is full, waits for the next window where the current
+	// counter becomes the previous counter, then waits until its weight is low enough.
+	if b.Count+1 > limit {
+		wait := float64(s.window-elapsed) + (1-(limit-1)/b.Count)*window
+		return time.Duration(wait)
+	}
+
+	// waits until the weight of the previous window is low enough.
+	weight := (limit - b.Count - 1) / b.Previous
+	return time.Duration((1-weight)*window) - elapsed
+}
+
+// seconds converts the float seconds into time.Duration.
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
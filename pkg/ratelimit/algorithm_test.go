@@ -0,0 +1,76 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	alg := TokenBucket(3, 3*time.Second)
+	now := time.Unix(1000, 0)
+
+	var b Bucket
+	for i := 2; i >= 0; i-- {
+		d := alg.Take(&b, now)
+		if !d.Allowed || d.Remaining != i || d.Limit != 3 {
+			t.Fatalf("expecting burst is allowed with remaining %d but got: %+v", i, d)
+		}
+	}
+
+	d := alg.Take(&b, now)
+	if d.Allowed || d.RetryAfter != time.Second || d.Reset != 3*time.Second {
+		t.Fatalf("expecting rejected with retry after 1s but got: %+v", d)
+	}
+
+	// one token is refilled each second.
+	d = alg.Take(&b, now.Add(time.Second))
+	if !d.Allowed || d.Remaining != 0 {
+		t.Fatalf("expecting refilled token is allowed but got: %+v", d)
+	}
+
+	// the bucket never holds more than the limit.
+	d = alg.Take(&b, now.Add(time.Hour))
+	if !d.Allowed || d.Remaining != 2 {
+		t.Fatalf("expecting full bucket but got: %+v", d)
+	}
+}
+
+func TestSlidingWindow(t *testing.T) {
+	alg := SlidingWindow(4, 10*time.Second)
+	start := time.Unix(1000, 0)
+
+	var b Bucket
+	for i := 3; i >= 0; i-- {
+		d := alg.Take(&b, start.Add(time.Second))
+		if !d.Allowed || d.Remaining != i {
+			t.Fatalf("expecting allowed with remaining %d but got: %+v", i, d)
+		}
+	}
+
+	d := alg.Take(&b, start.Add(2*time.Second))
+	if d.Allowed || d.Reset != 8*time.Second {
+		t.Fatalf("expecting rejected in the full window but got: %+v", d)
+	}
+
+	// the next window starts at 1010, the previous weight must be <= 3/4,
+	// so the request is allowed at 1012.5.
+	if d.RetryAfter != 10500*time.Millisecond {
+		t.Fatalf("expecting retry after 10.5s but got: %s", d.RetryAfter)
+	}
+
+	d = alg.Take(&b, start.Add(12*time.Second))
+	if d.Allowed {
+		t.Fatalf("expecting rejected by the previous window weight but got: %+v", d)
+	}
+
+	d = alg.Take(&b, start.Add(12500*time.Millisecond))
+	if !d.Allowed || d.Remaining != 0 {
+		t.Fatalf("expecting allowed after the retry time but got: %+v", d)
+	}
+
+	// the previous counter is dropped after an idle window.
+	d = alg.Take(&b, start.Add(35*time.Second))
+	if !d.Allowed || d.Remaining != 3 {
+		t.Fatalf("expecting a fresh window but got: %+v", d)
+	}
+}
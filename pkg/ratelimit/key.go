@@ -0,0 +1,117 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// KeyFunc knows how to get the rate limit key of the request.
+// An empty key means the request is not limited.
+type KeyFunc func(r *http.Request) (string, error)
+
+// ClientIP creates a KeyFunc that uses the client IP address as the key.
+//
+// The X-Forwarded-For and X-Real-IP headers are only honored if the request
+// comes from one of the trusted proxies, given as CIDR (for example "10.0.0.0/8")
+// or single IP. The X-Forwarded-For is read from right to left and the first
+// address that is not a trusted proxy is the client.
+//
+// ClientIP panics if a trusted proxy is not a valid CIDR or IP.
+func ClientIP(trustedProxies ...string) KeyFunc {
+	trusted := make([]*net.IPNet, 0, len(trustedProxies))
+	for _, proxy := range trustedProxies {
+		if !strings.Contains(proxy, "/") {
+			if ip := net.ParseIP(proxy); ip != nil && ip.To4() != nil {
+				proxy += "/32"
+			} else {
+				proxy += "/128"
+			}
+		}
+
+		_, network, err := net.ParseCIDR(proxy)
+		if err != nil {
+			panic(fmt.Sprintf("ratelimit: invalid trusted proxy %q: %v", proxy, err))
+		}
+
+		trusted = append(trusted, network)
+	}
+
+	isTrusted := func(ip net.IP) bool {
+		for _, network := range trusted {
+			if network.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return func(r *http.Request) (string, error) {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+
+		client := net.ParseIP(host)
+		if client == nil {
+			return "", fmt.Errorf("ratelimit: invalid remote address %q", r.RemoteAddr)
+		}
+
+		if !isTrusted(client) {
+			return "ip:" + client.String(), nil
+		}
+
+		forwarded := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+		for i := len(forwarded) - 1; i >= 0; i-- {
+			ip := net.ParseIP(strings.TrimSpace(forwarded[i]))
+			if ip == nil {
+				// the chain is broken, the rest can not be trusted.
+				break
+			}
+
+			client = ip
+			if !isTrusted(ip) {
+				return "ip:" + client.String(), nil
+			}
+		}
+
+		if ip := net.ParseIP(strings.TrimSpace(r.Header.Get("X-Real-IP"))); ip != nil && client.Equal(net.ParseIP(host)) {
+			client = ip
+		}
+
+		return "ip:" + client.String(), nil
+	}
+}
+
+// FromContext creates a KeyFunc that uses the value from the request context
+// as the key, for example the JWT subject stored by the authentication middleware.
+// The request is not limited by this key if the value is empty.
+func FromContext(name string, fn func(ctx context.Context) string) KeyFunc {
+	return func(r *http.Request) (string, error) {
+		v := fn(r.Context())
+		if v == "" {
+			return "", nil
+		}
+		return name + ":" + v, nil
+	}
+}
+
+// First creates a KeyFunc that uses the first non-empty key of the given keys,
+// for example to limit the authenticated clients by subject and the others by IP.
+func First(keys ...KeyFunc) KeyFunc {
+	return func(r *http.Request) (string, error) {
+		for _, fn := range keys {
+			key, err := fn(r)
+			if err != nil {
+				return "", err
+			}
+
+			if key != "" {
+				return key, nil
+			}
+		}
+		return "", nil
+	}
+}
@@ -0,0 +1,84 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	key := ClientIP("10.0.0.0/8", "192.168.1.1")
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  string
+		realIP     string
+		expected   string
+	}{
+		{"untrusted remote ignores headers", "203.0.113.7:1234", "1.2.3.4", "5.6.7.8", "ip:203.0.113.7"},
+		{"trusted remote without headers", "10.1.2.3:1234", "", "", "ip:10.1.2.3"},
+		{"trusted remote with forwarded for", "10.1.2.3:1234", "1.2.3.4, 198.51.100.1", "", "ip:198.51.100.1"},
+		{"skips trusted proxies in chain", "10.1.2.3:1234", "198.51.100.1, 192.168.1.1, 10.9.9.9", "", "ip:198.51.100.1"},
+		{"stops at invalid entry", "10.1.2.3:1234", "198.51.100.1, garbage, 10.9.9.9", "", "ip:10.9.9.9"},
+		{"trusted remote with real ip", "192.168.1.1:1234", "", "198.51.100.2", "ip:198.51.100.2"},
+		{"ipv6 remote", "[2001:db8::1]:1234", "", "", "ip:2001:db8::1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.forwarded != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			if tt.realIP != "" {
+				req.Header.Set("X-Real-IP", tt.realIP)
+			}
+
+			got, err := key(req)
+			if err != nil {
+				t.Fatalf("expecting error nil but got %v", err)
+			}
+
+			if got != tt.expected {
+				t.Fatalf("expecting key %q but got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestClientIPInvalidProxy(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("expecting panic for invalid trusted proxy")
+		}
+	}()
+
+	ClientIP("not-an-ip")
+}
+
+func TestFirst(t *testing.T) {
+	type ctxKey struct{}
+	subject := func(ctx context.Context) string {
+		s, _ := ctx.Value(ctxKey{}).(string)
+		return s
+	}
+
+	key := First(FromContext("sub", subject), ClientIP())
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "203.0.113.7:1234"
+
+	got, _ := key(req)
+	if got != "ip:203.0.113.7" {
+		t.Fatalf("expecting fallback to ip but got %q", got)
+	}
+
+	req = req.WithContext(context.WithValue(req.Context(), ctxKey{}, "user-1"))
+	got, _ = key(req)
+	if got != "sub:user-1" {
+		t.Fatalf("expecting subject key but got %q", got)
+	}
+}
@@ -0,0 +1,138 @@
+// Package ratelimit provides a rate limiting middleware for the mux.Router.
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/josestg/justforfun/pkg/mux"
+	"github.com/josestg/justforfun/pkg/xerrs"
+)
+
+// Headers of the rate limit, see draft-ietf-httpapi-ratelimit-headers.
+const (
+	HeaderLimit     = "RateLimit-Limit"
+	HeaderRemaining = "RateLimit-Remaining"
+	HeaderReset     = "RateLimit-Reset"
+	HeaderRetry     = "Retry-After"
+)
+
+// DefaultShards is the default number of shards of the MemoryStore.
+const DefaultShards = 32
+
+// Option is an option type that can be used to customize the Limiter.
+type Option func(l *Limiter)
+
+// WithStore sets the Store of the Limiter.
+// The default is a MemoryStore with DefaultShards.
+func WithStore(s Store) Option {
+	return func(l *Limiter) {
+		l.store = s
+	}
+}
+
+// WithKey sets the KeyFunc of the Limiter.
+// The default is ClientIP without trusted proxies.
+func WithKey(fn KeyFunc) Option {
+	return func(l *Limiter) {
+		l.key = fn
+	}
+}
+
+// WithClock sets the time source of the Limiter.
+func WithClock(now func() time.Time) Option {
+	return func(l *Limiter) {
+		l.now = now
+	}
+}
+
+// Limiter limits the number of requests of each key.
+type Limiter struct {
+	algorithm Algorithm
+	store     Store
+	key       KeyFunc
+	now       func() time.Time
+}
+
+// New creates a new Limiter with the given algorithm.
+func New(algorithm Algorithm, options ...Option) *Limiter {
+	l := Limiter{
+		algorithm: algorithm,
+		store:     NewMemoryStore(DefaultShards),
+		key:       ClientIP(),
+		now:       time.Now,
+	}
+
+	for _, fn := range options {
+		fn(&l)
+	}
+
+	return &l
+}
+
+// Allow takes a single request of the given key.
+func (l *Limiter) Allow(r *http.Request, key string) (Decision, error) {
+	var d Decision
+	now := l.now()
+
+	err := l.store.Update(r.Context(), key, l.algorithm.TTL(), func(b *Bucket) {
+		d = l.algorithm.Take(b, now)
+	})
+	if err != nil {
+		return Decision{}, xerrs.Wrap(err, fmt.Sprintf("updating bucket of %q", key))
+	}
+
+	return d, nil
+}
+
+// Middleware creates a middleware that limits the requests.
+//
+// The RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers are set
+// on every limited response. When the limit is exceeded, the handler is not
+// called and a 429 Too Many Requests error is returned with the Retry-After header.
+// The requests with an empty key are not limited, and the Store failure is
+// returned as an error, so the request fails instead of bypassing the limit.
+func (l *Limiter) Middleware() mux.Middleware {
+	return func(handler mux.Handler) mux.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) error {
+			key, err := l.key(r)
+			if err != nil {
+				return xerrs.Wrap(err, "getting rate limit key")
+			}
+
+			if key == "" {
+				return handler.ServeHTTP(w, r)
+			}
+
+			d, err := l.Allow(r, key)
+			if err != nil {
+				return err
+			}
+
+			h := w.Header()
+			h.Set(HeaderLimit, strconv.Itoa(d.Limit))
+			h.Set(HeaderRemaining, strconv.Itoa(d.Remaining))
+			h.Set(HeaderReset, ceilSeconds(d.Reset))
+
+			if !d.Allowed {
+				h.Set(HeaderRetry, ceilSeconds(d.RetryAfter))
+				return mux.NewError(http.StatusTooManyRequests, "rate_limited", "too many requests, please try again later")
+			}
+
+			return handler.ServeHTTP(w, r)
+		}
+
+		return mux.HandlerFunc(fn)
+	}
+}
+
+// ceilSeconds formats the duration as the number of seconds rounded up.
+func ceilSeconds(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
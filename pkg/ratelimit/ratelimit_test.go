@@ -0,0 +1,142 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/josestg/justforfun/pkg/mux"
+)
+
+func TestLimiterMiddleware(t *testing.T) {
+	now := time.Unix(1000, 0)
+	limiter := New(TokenBucket(2, 10*time.Second), WithClock(func() time.Time { return now }))
+
+	router := mux.NewRouter(nil)
+	router.Handle("GET /ping", mux.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		_, err := io.WriteString(w, "pong")
+		return err
+	}), limiter.Middleware())
+
+	do := func(remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+		req.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for i, remaining := range []string{"1", "0"} {
+		rec := do("203.0.113.7:1234")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("request %d: expecting status 200 but got %d", i, rec.Code)
+		}
+
+		if got := rec.Header().Get(HeaderRemaining); got != remaining {
+			t.Fatalf("request %d: expecting remaining %s but got %q", i, remaining, got)
+		}
+
+		if got := rec.Header().Get(HeaderLimit); got != "2" {
+			t.Fatalf("request %d: expecting limit 2 but got %q", i, got)
+		}
+	}
+
+	rec := do("203.0.113.7:1234")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expecting status 429 but got %d", rec.Code)
+	}
+
+	if got := rec.Header().Get(HeaderRetry); got != "5" {
+		t.Fatalf("expecting retry after 5 but got %q", got)
+	}
+
+	if got := rec.Header().Get(HeaderReset); got != "10" {
+		t.Fatalf("expecting reset 10 but got %q", got)
+	}
+
+	if !strings.Contains(rec.Body.String(), "rate_limited") {
+		t.Fatalf("expecting rate_limited error code but got %q", rec.Body.String())
+	}
+
+	// the other client has its own bucket.
+	if rec := do("203.0.113.8:1234"); rec.Code != http.StatusOK {
+		t.Fatalf("expecting other client is allowed but got %d", rec.Code)
+	}
+
+	now = now.Add(5 * time.Second)
+	if rec := do("203.0.113.7:1234"); rec.Code != http.StatusOK {
+		t.Fatalf("expecting allowed after retry time but got %d", rec.Code)
+	}
+}
+
+type failingStore struct{}
+
+func (failingStore) Update(context.Context, string, time.Duration, func(*Bucket)) error {
+	return errors.New("connection refused")
+}
+
+func TestLimiterMiddlewareSkipAndFailure(t *testing.T) {
+	handler := mux.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		return nil
+	})
+
+	skip := New(TokenBucket(1, time.Second), WithStore(failingStore{}), WithKey(func(r *http.Request) (string, error) {
+		return "", nil
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if err := skip.Middleware()(handler).ServeHTTP(httptest.NewRecorder(), req); err != nil {
+		t.Fatalf("expecting empty key is not limited but got %v", err)
+	}
+
+	failing := New(TokenBucket(1, time.Second), WithStore(failingStore{}))
+	if err := failing.Middleware()(handler).ServeHTTP(httptest.NewRecorder(), req); err == nil {
+		t.Fatalf("expecting store failure is returned")
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	now := time.Unix(1000, 0)
+	store := NewMemoryStore(4)
+	store.now = func() time.Time { return now }
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = store.Update(context.Background(), "k", time.Minute, func(b *Bucket) { b.Count++ })
+		}()
+	}
+	wg.Wait()
+
+	var count float64
+	_ = store.Update(context.Background(), "k", time.Minute, func(b *Bucket) { count = b.Count })
+	if count != 100 {
+		t.Fatalf("expecting count 100 but got %v", count)
+	}
+
+	_ = store.Update(context.Background(), "other", time.Minute, func(b *Bucket) {})
+	if store.Len() != 2 {
+		t.Fatalf("expecting 2 buckets but got %d", store.Len())
+	}
+
+	// the expired buckets are swept on the next update.
+	now = now.Add(2 * time.Minute)
+	for i := 0; i < 10; i++ {
+		_ = store.Update(context.Background(), "k", time.Minute, func(b *Bucket) {})
+		_ = store.Update(context.Background(), "fresh", time.Minute, func(b *Bucket) {})
+	}
+
+	var fresh float64 = -1
+	_ = store.Update(context.Background(), "k", time.Minute, func(b *Bucket) { fresh = b.Count })
+	if fresh != 0 {
+		t.Fatalf("expecting expired bucket is reset but got %v", fresh)
+	}
+}
@@ -0,0 +1,106 @@
+package ratelimit
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+// Store knows how to persist the buckets.
+//
+// The Store must be safe for concurrent use. The MemoryStore is suitable for a
+// single instance, to share the limit between instances, implements the Store
+// on a shared storage, for example on Postgres by loading the bucket row with
+// `SELECT ... FOR UPDATE`, calling fn and upserting the row in one transaction.
+type Store interface {
+	// Update atomically loads the bucket of the key, passes it to fn, and
+	// stores the modified bucket. The missing bucket is passed as a zero Bucket.
+	// The bucket can be removed after it is not updated for ttl.
+	Update(ctx context.Context, key string, ttl time.Duration, fn func(b *Bucket)) error
+}
+
+// entry is a bucket with its expiration time.
+type entry struct {
+	bucket  Bucket
+	expires time.Time
+}
+
+// shard is a part of the MemoryStore with its own lock.
+type shard struct {
+	mu        sync.Mutex
+	entries   map[string]*entry
+	nextSweep time.Time
+}
+
+// MemoryStore is an in-memory Store.
+// The keys are spread into shards, so the requests of different keys rarely
+// wait for the same lock.
+type MemoryStore struct {
+	shards []*shard
+	now    func() time.Time
+}
+
+// NewMemoryStore creates a new MemoryStore with the given number of shards.
+func NewMemoryStore(shards int) *MemoryStore {
+	if shards <= 0 {
+		shards = 1
+	}
+
+	s := MemoryStore{
+		shards: make([]*shard, shards),
+		now:    time.Now,
+	}
+
+	for i := range s.shards {
+		s.shards[i] = &shard{entries: make(map[string]*entry)}
+	}
+
+	return &s
+}
+
+func (s *MemoryStore) Update(_ context.Context, key string, ttl time.Duration, fn func(b *Bucket)) error {
+	sh := s.shard(key)
+	now := s.now()
+
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	// removes the expired buckets at most once per ttl for each shard.
+	if now.After(sh.nextSweep) {
+		for k, e := range sh.entries {
+			if now.After(e.expires) {
+				delete(sh.entries, k)
+			}
+		}
+		sh.nextSweep = now.Add(ttl)
+	}
+
+	e, exist := sh.entries[key]
+	if !exist || now.After(e.expires) {
+		e = &entry{}
+		sh.entries[key] = e
+	}
+
+	fn(&e.bucket)
+	e.expires = now.Add(ttl)
+	return nil
+}
+
+// Len returns the number of stored buckets.
+func (s *MemoryStore) Len() int {
+	n := 0
+	for _, sh := range s.shards {
+		sh.mu.Lock()
+		n += len(sh.entries)
+		sh.mu.Unlock()
+	}
+	return n
+}
+
+// shard returns the shard of the key.
+func (s *MemoryStore) shard(key string) *shard {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return s.shards[h.Sum32()%uint32(len(s.shards))]
+}
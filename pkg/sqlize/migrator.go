@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"strings"
 	"time"
+
+	"github.com/josestg/justforfun/pkg/tracing"
 )
 
 // Migrator knows how to manage database migration.
@@ -87,7 +89,9 @@ func (m *Migrator) Up(ctx context.Context) error {
 	for _, migration := range migrations {
 		history, exist := histories[migration.Version]
 		if !exist {
-			err := m.repository.ApplyNewMigration(ctx, migration.Version, migration.Script, appliedAt)
+			err := m.step(ctx, "apply new", migration, func(ctx context.Context) error {
+				return m.repository.ApplyNewMigration(ctx, migration.Version, migration.Script, appliedAt)
+			})
 			if err != nil {
 				return fmt.Errorf("%w: applying new migration", err)
 			}
@@ -97,7 +101,9 @@ func (m *Migrator) Up(ctx context.Context) error {
 		}
 
 		if !history.Applied {
-			err := m.repository.ApplyExistingMigration(ctx, migration.Version, migration.Script, appliedAt)
+			err := m.step(ctx, "apply existing", migration, func(ctx context.Context) error {
+				return m.repository.ApplyExistingMigration(ctx, migration.Version, migration.Script, appliedAt)
+			})
 			if err != nil {
 				return fmt.Errorf("%w: applying exsting migration", err)
 			}
@@ -134,7 +140,9 @@ func (m *Migrator) Down(ctx context.Context) error {
 		}
 
 		if history.Applied {
-			err := m.repository.UndoExistingMigration(ctx, migration.Version, migration.Script, appliedAt)
+			err := m.step(ctx, "undo existing", migration, func(ctx context.Context) error {
+				return m.repository.UndoExistingMigration(ctx, migration.Version, migration.Script, appliedAt)
+			})
 			if err != nil {
 				return fmt.Errorf("%w: undo existing migration", err)
 			}
@@ -171,7 +179,9 @@ func (m *Migrator) Undo(ctx context.Context) error {
 		}
 
 		if history.Applied {
-			err := m.repository.UndoExistingMigration(ctx, migration.Version, migration.Script, appliedAt)
+			err := m.step(ctx, "undo existing", migration, func(ctx context.Context) error {
+				return m.repository.UndoExistingMigration(ctx, migration.Version, migration.Script, appliedAt)
+			})
 			if err != nil {
 				return fmt.Errorf("%w: undo existing migration", err)
 			}
@@ -184,6 +194,19 @@ func (m *Migrator) Undo(ctx context.Context) error {
 	return nil
 }
 
+// step runs a single migration step in a child span of the span in ctx.
+func (m *Migrator) step(ctx context.Context, action string, migration Migration, fn func(ctx context.Context) error) error {
+	ctx, span := tracing.Start(ctx, "sqlize "+action, tracing.WithAttributes(map[string]interface{}{
+		"migration.version": migration.Version,
+		"migration.source":  migration.SourcePath,
+	}))
+	defer span.End()
+
+	err := fn(ctx)
+	span.SetError(err)
+	return err
+}
+
 // Status prints the migration status.
 func (m *Migrator) Status(ctx context.Context) error {
 	histories, err := m.repository.FetchCurrentMigrations(ctx)
@@ -0,0 +1,69 @@
+package sse
+
+import (
+	"strconv"
+	"sync"
+)
+
+// Buffer keeps the last events of a stream, so the reconnecting clients can
+// resume from their Last-Event-ID, see WithReplay. It is safe for concurrent
+// use, and it is usually shared by all the streams of the same events.
+type Buffer struct {
+	mu     sync.Mutex
+	size   int
+	seq    uint64
+	events []Event
+}
+
+// NewBuffer creates a new Buffer that keeps at most size events.
+func NewBuffer(size int) *Buffer {
+	if size < 1 {
+		panic("sse: buffer size must be positive")
+	}
+
+	return &Buffer{size: size, events: make([]Event, 0, size)}
+}
+
+// Add adds the event into the buffer, the oldest event is dropped if the
+// buffer is full. The event without ID is given the next sequence number as
+// its ID. It returns the added event, which should be sent to the clients.
+func (b *Buffer) Add(e Event) Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if e.ID == "" {
+		b.seq++
+		e.ID = strconv.FormatUint(b.seq, 10)
+	}
+
+	if len(b.events) == b.size {
+		copy(b.events, b.events[1:])
+		b.events = b.events[:b.size-1]
+	}
+
+	b.events = append(b.events, e)
+	return e
+}
+
+// Since returns the events after the event with the given ID. If the ID is
+// not in the buffer anymore, the client may have missed any of them, so all
+// the buffered events are returned. The empty ID returns nothing, the client
+// connects for the first time.
+func (b *Buffer) Since(id string) []Event {
+	if id == "" {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	start := 0
+	for i := len(b.events) - 1; i >= 0; i-- {
+		if b.events[i].ID == id {
+			start = i + 1
+			break
+		}
+	}
+
+	return append([]Event(nil), b.events[start:]...)
+}
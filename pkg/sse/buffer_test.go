@@ -0,0 +1,41 @@
+package sse
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBuffer(t *testing.T) {
+	b := NewBuffer(3)
+
+	if e := b.Add(Event{Data: "a"}); e.ID != "1" {
+		t.Fatalf("expecting id 1 but got %q", e.ID)
+	}
+
+	b.Add(Event{ID: "custom", Data: "b"})
+	b.Add(Event{Data: "c"})
+	b.Add(Event{Data: "d"}) // drops a.
+
+	ids := func(events []Event) []string {
+		var got []string
+		for _, e := range events {
+			got = append(got, e.ID)
+		}
+		return got
+	}
+
+	tests := map[string][]string{
+		"":       nil,
+		"custom": {"2", "3"},
+		"2":      {"3"},
+		"3":      nil,
+		"1":      {"custom", "2", "3"}, // evicted, replays all.
+		"404":    {"custom", "2", "3"},
+	}
+
+	for id, want := range tests {
+		if got := ids(b.Since(id)); !reflect.DeepEqual(got, want) {
+			t.Errorf("since %q: expecting %v but got %v", id, want, got)
+		}
+	}
+}
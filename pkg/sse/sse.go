@@ -0,0 +1,305 @@
+// Package sse provides the Server-Sent Events streaming for the mux handlers.
+// see: https://html.spec.whatwg.org/multipage/server-sent-events.html.
+//
+// The Stream writes the events into the response and flushes each of them, so
+// the handler must not be under mux.Timeout, which buffers the response, and
+// the http.Server WriteTimeout must be longer than the streams.
+package sse
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ContentType is the media type of the event stream.
+const ContentType = "text/event-stream"
+
+// LastEventIDHeader is the header the reconnecting client sends with
+// the ID of the last event it has received.
+const LastEventIDHeader = "Last-Event-ID"
+
+// DefaultHeartbeat is the default interval of the heartbeats, it keeps the idle
+// connection open through the proxies that close the silent connections.
+const DefaultHeartbeat = 15 * time.Second
+
+var (
+	// ErrStreamingUnsupported is an error when the ResponseWriter can not be flushed.
+	ErrStreamingUnsupported = errors.New("sse: streaming unsupported")
+
+	// ErrInvalidEvent is an error when the event ID or name contains a line break.
+	ErrInvalidEvent = errors.New("sse: invalid event")
+
+	// ErrClosed is an error when the stream is closed.
+	ErrClosed = errors.New("sse: stream closed")
+)
+
+// Event is a message of the event stream.
+type Event struct {
+	// ID is the event ID, the client sends it back in the Last-Event-ID header
+	// when it reconnects. It must not contain a line break.
+	ID string
+
+	// Event is the event name, the client dispatches the unnamed events as
+	// "message". It must not contain a line break.
+	Event string
+
+	// Data is the event payload, each line is sent as a data field.
+	Data string
+
+	// Retry tells the client how long it waits before reconnecting.
+	// The zero Retry is not sent.
+	Retry time.Duration
+}
+
+// Option is an option type that can be used to customize the Stream.
+type Option func(s *Stream)
+
+// WithHeartbeat sets the interval of the heartbeats, zero disables them.
+// The default is DefaultHeartbeat.
+func WithHeartbeat(d time.Duration) Option {
+	return func(s *Stream) {
+		s.heartbeat = d
+	}
+}
+
+// WithRetry sets the reconnection time of the client, it is sent once when
+// the stream is opened.
+func WithRetry(d time.Duration) Option {
+	return func(s *Stream) {
+		s.retry = d
+	}
+}
+
+// WithReplay replays the events of the buffer that the reconnecting client
+// has missed, the events after its Last-Event-ID, when the stream is opened.
+func WithReplay(b *Buffer) Option {
+	return func(s *Stream) {
+		s.replay = b
+	}
+}
+
+// Stream writes the events into the response of a request.
+type Stream struct {
+	heartbeat time.Duration
+	retry     time.Duration
+	replay    *Buffer
+
+	mu      sync.Mutex
+	w       http.ResponseWriter
+	flusher http.Flusher
+	done    <-chan struct{}
+	err     error
+	closed  bool
+
+	lastEventID string
+	stop        chan struct{}
+	stopped     chan struct{}
+}
+
+// NewStream opens the event stream of the request. It writes the headers,
+// the retry and the replayed events, then it starts sending the heartbeats.
+// The stream must be closed before the handler returns, for example:
+//
+// 	func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) error {
+// 		stream, err := sse.NewStream(w, r, sse.WithReplay(h.buffer))
+// 		if err != nil {
+// 			return err
+// 		}
+// 		defer stream.Close()
+//
+// 		for {
+// 			select {
+// 			case <-stream.Done():
+// 				return nil // the client has gone.
+// 			case e := <-h.events:
+// 				if err := stream.Send(e); err != nil {
+// 					return nil
+// 				}
+// 			}
+// 		}
+// 	}
+//
+// It returns ErrStreamingUnsupported if w does not implement http.Flusher.
+// Nothing is written in that case, so the error can still be responded.
+func NewStream(w http.ResponseWriter, r *http.Request, options ...Option) (*Stream, error) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return nil, ErrStreamingUnsupported
+	}
+
+	s := Stream{
+		heartbeat:   DefaultHeartbeat,
+		w:           w,
+		flusher:     flusher,
+		done:        r.Context().Done(),
+		lastEventID: r.Header.Get(LastEventIDHeader),
+		stop:        make(chan struct{}),
+		stopped:     make(chan struct{}),
+	}
+
+	for _, fn := range options {
+		fn(&s)
+	}
+
+	h := w.Header()
+	h.Set("Content-Type", ContentType)
+	h.Set("Cache-Control", "no-cache")
+	h.Set("X-Accel-Buffering", "no") // disables the response buffering of nginx.
+	w.WriteHeader(http.StatusOK)
+
+	var b strings.Builder
+	if s.retry > 0 {
+		writeRetry(&b, s.retry)
+		b.WriteString("\n")
+	}
+
+	if s.replay != nil {
+		for _, e := range s.replay.Since(s.lastEventID) {
+			writeEvent(&b, e)
+		}
+	}
+
+	s.mu.Lock()
+	err := s.write(b.String())
+	s.mu.Unlock()
+	if err != nil {
+		close(s.stopped)
+		return nil, err
+	}
+
+	if s.heartbeat > 0 {
+		go s.beat()
+	} else {
+		close(s.stopped)
+	}
+
+	return &s, nil
+}
+
+// LastEventID returns the Last-Event-ID of the reconnecting client,
+// or empty if the client connects for the first time.
+func (s *Stream) LastEventID() string {
+	return s.lastEventID
+}
+
+// Done returns a channel that is closed when the client disconnects.
+func (s *Stream) Done() <-chan struct{} {
+	return s.done
+}
+
+// Send writes the event and flushes it to the client. It returns the
+// ErrInvalidEvent if the event is invalid, ErrClosed if the stream is closed
+// or the client has disconnected, or the error of the writer.
+func (s *Stream) Send(e Event) error {
+	if strings.ContainsAny(e.ID, "\r\n\x00") || strings.ContainsAny(e.Event, "\r\n") {
+		return ErrInvalidEvent
+	}
+
+	var b strings.Builder
+	writeEvent(&b, e)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.write(b.String())
+}
+
+// Close stops the heartbeats, the later sends fail with ErrClosed.
+// It does not close the connection, the handler does it by returning.
+func (s *Stream) Close() {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.stop)
+		if s.err == nil {
+			s.err = ErrClosed
+		}
+	}
+	s.mu.Unlock()
+
+	<-s.stopped
+}
+
+// beat sends a comment line on every heartbeat until the stream is closed
+// or the client disconnects.
+func (s *Stream) beat() {
+	defer close(s.stopped)
+
+	ticker := time.NewTicker(s.heartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.mu.Lock()
+			err := s.write(": heartbeat\n\n")
+			s.mu.Unlock()
+			if err != nil {
+				return
+			}
+		case <-s.stop:
+			return
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// write writes and flushes the data, flushing the empty data sends the headers.
+// The first error fails the later writes.
+// The caller must hold the lock.
+func (s *Stream) write(data string) error {
+	if s.err != nil {
+		return s.err
+	}
+
+	select {
+	case <-s.done:
+		s.err = ErrClosed
+		return s.err
+	default:
+	}
+
+	if data != "" {
+		if _, err := s.w.Write([]byte(data)); err != nil {
+			s.err = err
+			return err
+		}
+	}
+
+	s.flusher.Flush()
+	return nil
+}
+
+// writeEvent formats the event fields, the empty event is a single blank line.
+func writeEvent(b *strings.Builder, e Event) {
+	if e.ID != "" {
+		b.WriteString("id: " + e.ID + "\n")
+	}
+
+	if e.Event != "" {
+		b.WriteString("event: " + e.Event + "\n")
+	}
+
+	if e.Retry > 0 {
+		writeRetry(b, e.Retry)
+	}
+
+	if e.Data != "" {
+		data := strings.ReplaceAll(e.Data, "\r\n", "\n")
+		data = strings.ReplaceAll(data, "\r", "\n")
+		for _, line := range strings.Split(data, "\n") {
+			b.WriteString("data: " + line + "\n")
+		}
+	}
+
+	b.WriteString("\n")
+}
+
+// writeRetry formats the retry field in milliseconds.
+func writeRetry(b *strings.Builder, d time.Duration) {
+	b.WriteString("retry: " + strconv.FormatInt(d.Milliseconds(), 10) + "\n")
+}
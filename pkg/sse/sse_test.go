@@ -0,0 +1,234 @@
+package sse
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/josestg/justforfun/pkg/compress"
+	"github.com/josestg/justforfun/pkg/mux"
+)
+
+func TestStream_Send(t *testing.T) {
+	rec := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/events", nil)
+
+	stream, err := NewStream(rec, r, WithHeartbeat(0), WithRetry(3*time.Second))
+	if err != nil {
+		t.Fatalf("expecting nil error but got %v", err)
+	}
+
+	events := []Event{
+		{ID: "1", Event: "progress", Data: `{"done":1}`},
+		{Data: "line 1\nline 2\r\nline 3"},
+		{ID: "2", Retry: 500 * time.Millisecond},
+	}
+
+	for _, e := range events {
+		if err := stream.Send(e); err != nil {
+			t.Fatalf("expecting nil error but got %v", err)
+		}
+	}
+
+	stream.Close()
+
+	if err := stream.Send(Event{Data: "late"}); !errors.Is(err, ErrClosed) {
+		t.Fatalf("expecting ErrClosed but got %v", err)
+	}
+
+	if got := rec.Header().Get("Content-Type"); got != ContentType {
+		t.Fatalf("expecting content type %q but got %q", ContentType, got)
+	}
+
+	if got := rec.Header().Get("Cache-Control"); got != "no-cache" {
+		t.Fatalf("expecting no-cache but got %q", got)
+	}
+
+	if !rec.Flushed {
+		t.Fatalf("expecting the events are flushed")
+	}
+
+	want := "retry: 3000\n\n" +
+		"id: 1\nevent: progress\ndata: {\"done\":1}\n\n" +
+		"data: line 1\ndata: line 2\ndata: line 3\n\n" +
+		"id: 2\nretry: 500\n\n"
+	if got := rec.Body.String(); got != want {
+		t.Fatalf("expecting body\n%q\nbut got\n%q", want, got)
+	}
+}
+
+func TestStream_InvalidEvent(t *testing.T) {
+	rec := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/events", nil)
+
+	stream, err := NewStream(rec, r, WithHeartbeat(0))
+	if err != nil {
+		t.Fatalf("expecting nil error but got %v", err)
+	}
+	defer stream.Close()
+
+	for _, e := range []Event{{ID: "1\n"}, {Event: "a\rb"}} {
+		if err := stream.Send(e); !errors.Is(err, ErrInvalidEvent) {
+			t.Fatalf("expecting ErrInvalidEvent but got %v", err)
+		}
+	}
+}
+
+func TestStream_Unsupported(t *testing.T) {
+	w := struct{ http.ResponseWriter }{httptest.NewRecorder()}
+	r := httptest.NewRequest(http.MethodGet, "/events", nil)
+
+	if _, err := NewStream(w, r); !errors.Is(err, ErrStreamingUnsupported) {
+		t.Fatalf("expecting ErrStreamingUnsupported but got %v", err)
+	}
+}
+
+func TestStream_Heartbeat(t *testing.T) {
+	rec := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/events", nil)
+
+	stream, err := NewStream(rec, r, WithHeartbeat(5*time.Millisecond))
+	if err != nil {
+		t.Fatalf("expecting nil error but got %v", err)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	stream.Close()
+
+	if got := rec.Body.String(); !strings.HasPrefix(got, ": heartbeat\n\n") {
+		t.Fatalf("expecting heartbeats but got %q", got)
+	}
+}
+
+func TestStream_Disconnect(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	rec := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/events", nil).WithContext(ctx)
+
+	stream, err := NewStream(rec, r, WithHeartbeat(time.Millisecond))
+	if err != nil {
+		t.Fatalf("expecting nil error but got %v", err)
+	}
+
+	cancel()
+
+	select {
+	case <-stream.Done():
+	case <-time.After(time.Second):
+		t.Fatalf("expecting the stream is done")
+	}
+
+	if err := stream.Send(Event{Data: "gone"}); !errors.Is(err, ErrClosed) {
+		t.Fatalf("expecting ErrClosed but got %v", err)
+	}
+
+	stream.Close()
+}
+
+func TestStream_Replay(t *testing.T) {
+	buffer := NewBuffer(10)
+	for _, data := range []string{"a", "b", "c"} {
+		buffer.Add(Event{Data: data})
+	}
+
+	rec := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/events", nil)
+	r.Header.Set(LastEventIDHeader, "1")
+
+	stream, err := NewStream(rec, r, WithHeartbeat(0), WithReplay(buffer))
+	if err != nil {
+		t.Fatalf("expecting nil error but got %v", err)
+	}
+	stream.Close()
+
+	if got := stream.LastEventID(); got != "1" {
+		t.Fatalf("expecting last event id 1 but got %q", got)
+	}
+
+	want := "id: 2\ndata: b\n\nid: 3\ndata: c\n\n"
+	if got := rec.Body.String(); got != want {
+		t.Fatalf("expecting body\n%q\nbut got\n%q", want, got)
+	}
+}
+
+func TestStream_Router(t *testing.T) {
+	events := make(chan Event)
+	handlerDone := make(chan error, 1)
+
+	handler := func(w http.ResponseWriter, r *http.Request) error {
+		stream, err := NewStream(w, r, WithHeartbeat(0))
+		if err != nil {
+			return err
+		}
+		defer stream.Close()
+
+		for {
+			select {
+			case <-stream.Done():
+				handlerDone <- nil
+				return nil
+			case e := <-events:
+				if err := stream.Send(e); err != nil {
+					handlerDone <- err
+					return nil
+				}
+			}
+		}
+	}
+
+	router := mux.NewRouter(make(mux.ShutdownChannel, 1), compress.Middleware())
+	router.Handle("GET /events", mux.HandlerFunc(handler))
+
+	srv := httptest.NewServer(router)
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/events", nil)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("expecting nil error but got %v", err)
+	}
+	defer res.Body.Close()
+
+	if got := res.Header.Get("Content-Type"); got != ContentType {
+		t.Fatalf("expecting content type %q but got %q", ContentType, got)
+	}
+
+	// each event is received before the next is sent, so it is flushed
+	// through the compression and the mux writers.
+	body := bufio.NewReader(res.Body)
+	for _, data := range []string{"first", "second"} {
+		events <- Event{Data: data}
+
+		line, err := body.ReadString('\n')
+		if err != nil {
+			t.Fatalf("expecting nil error but got %v", err)
+		}
+
+		if want := "data: " + data + "\n"; line != want {
+			t.Fatalf("expecting %q but got %q", want, line)
+		}
+
+		if _, err := body.ReadString('\n'); err != nil {
+			t.Fatalf("expecting nil error but got %v", err)
+		}
+	}
+
+	cancel()
+
+	select {
+	case err := <-handlerDone:
+		if err != nil {
+			t.Fatalf("expecting nil error but got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expecting the handler detects the disconnect")
+	}
+}
@@ -0,0 +1,203 @@
+// Package tracing provides spans with the W3C Trace Context propagation.
+// see: https://www.w3.org/TR/trace-context/.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Headers of the W3C Trace Context.
+const (
+	TraceparentHeader = "traceparent"
+	TracestateHeader  = "tracestate"
+)
+
+// FlagSampled is the trace flag that means the trace is recorded.
+const FlagSampled = byte(0x01)
+
+var (
+	// ErrInvalidTraceparent is an error when the traceparent header is malformed.
+	ErrInvalidTraceparent = errors.New("invalid traceparent")
+
+	// ErrInvalidTracestate is an error when the tracestate header is malformed.
+	ErrInvalidTracestate = errors.New("invalid tracestate")
+)
+
+// TraceID is the identifier of a trace.
+type TraceID [16]byte
+
+// IsValid reports whether the id is not all zeros.
+func (id TraceID) IsValid() bool { return id != TraceID{} }
+
+func (id TraceID) String() string { return hex.EncodeToString(id[:]) }
+
+// MarshalText encodes the id as hex, so it is readable in the exported spans.
+func (id TraceID) MarshalText() ([]byte, error) { return []byte(id.String()), nil }
+
+// SpanID is the identifier of a span.
+type SpanID [8]byte
+
+// IsValid reports whether the id is not all zeros.
+func (id SpanID) IsValid() bool { return id != SpanID{} }
+
+func (id SpanID) String() string { return hex.EncodeToString(id[:]) }
+
+// MarshalText encodes the id as hex, so it is readable in the exported spans.
+// The zero id is encoded as empty string.
+func (id SpanID) MarshalText() ([]byte, error) {
+	if !id.IsValid() {
+		return []byte{}, nil
+	}
+	return []byte(id.String()), nil
+}
+
+// SpanContext is the part of the span that is propagated across the services.
+type SpanContext struct {
+	TraceID    TraceID
+	SpanID     SpanID
+	Flags      byte
+	TraceState TraceState
+}
+
+// IsValid reports whether both ids are valid.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// IsSampled reports whether the sampled flag is set.
+func (sc SpanContext) IsSampled() bool {
+	return sc.Flags&FlagSampled != 0
+}
+
+// Traceparent formats the span context as the version 00 traceparent header.
+func (sc SpanContext) Traceparent() string {
+	return fmt.Sprintf("00-%s-%s-%02x", sc.TraceID, sc.SpanID, sc.Flags)
+}
+
+// ParseTraceparent parses the traceparent header value.
+//
+// The header has form `version-traceid-parentid-flags`. The future versions
+// are parsed as version 00, and their additional fields are ignored.
+func ParseTraceparent(s string) (SpanContext, error) {
+	var sc SpanContext
+
+	parts := strings.Split(strings.TrimSpace(s), "-")
+	if len(parts) < 4 {
+		return sc, fmt.Errorf("%w: expecting 4 fields but got %d", ErrInvalidTraceparent, len(parts))
+	}
+
+	version, err := decodeHex(parts[0], 1)
+	if err != nil || version[0] == 0xff {
+		return sc, fmt.Errorf("%w: invalid version %q", ErrInvalidTraceparent, parts[0])
+	}
+
+	if version[0] == 0 && len(parts) != 4 {
+		return sc, fmt.Errorf("%w: version 00 must have exactly 4 fields", ErrInvalidTraceparent)
+	}
+
+	traceID, err := decodeHex(parts[1], 16)
+	if err != nil {
+		return sc, fmt.Errorf("%w: invalid trace id %q", ErrInvalidTraceparent, parts[1])
+	}
+
+	spanID, err := decodeHex(parts[2], 8)
+	if err != nil {
+		return sc, fmt.Errorf("%w: invalid parent id %q", ErrInvalidTraceparent, parts[2])
+	}
+
+	flags, err := decodeHex(parts[3], 1)
+	if err != nil {
+		return sc, fmt.Errorf("%w: invalid flags %q", ErrInvalidTraceparent, parts[3])
+	}
+
+	copy(sc.TraceID[:], traceID)
+	copy(sc.SpanID[:], spanID)
+	sc.Flags = flags[0]
+
+	if !sc.IsValid() {
+		return SpanContext{}, fmt.Errorf("%w: all zeros id", ErrInvalidTraceparent)
+	}
+
+	return sc, nil
+}
+
+// Extract extracts the span context from the traceparent and tracestate headers.
+// It returns false if the traceparent is missing or invalid. The invalid tracestate
+// is discarded without discarding the traceparent.
+func Extract(h http.Header) (SpanContext, bool) {
+	sc, err := ParseTraceparent(h.Get(TraceparentHeader))
+	if err != nil {
+		return SpanContext{}, false
+	}
+
+	if ts, err := ParseTraceState(strings.Join(h.Values(TracestateHeader), ",")); err == nil {
+		sc.TraceState = ts
+	}
+
+	return sc, true
+}
+
+// Inject writes the span context of the span in ctx into the headers,
+// for example into the headers of an outgoing request.
+func Inject(ctx context.Context, h http.Header) {
+	span := SpanFromContext(ctx)
+	if span == nil {
+		return
+	}
+
+	sc := span.SpanContext()
+	h.Set(TraceparentHeader, sc.Traceparent())
+	if len(sc.TraceState) != 0 {
+		h.Set(TracestateHeader, sc.TraceState.String())
+	} else {
+		h.Del(TracestateHeader)
+	}
+}
+
+// decodeHex decodes the lowercase hex string of n bytes.
+func decodeHex(s string, n int) ([]byte, error) {
+	if len(s) != 2*n || strings.ToLower(s) != s {
+		return nil, ErrInvalidTraceparent
+	}
+	return hex.DecodeString(s)
+}
+
+// newTraceID generates a random TraceID.
+func newTraceID() TraceID {
+	var id TraceID
+	for !id.IsValid() {
+		_, _ = rand.Read(id[:])
+	}
+	return id
+}
+
+// newSpanID generates a random SpanID.
+func newSpanID() SpanID {
+	var id SpanID
+	for !id.IsValid() {
+		_, _ = rand.Read(id[:])
+	}
+	return id
+}
+
+// contextKey is the type of the context keys of this package.
+type contextKey int
+
+const spanKey = contextKey(0)
+
+// ContextWithSpan returns a copy of ctx that holds the span.
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	return context.WithValue(ctx, spanKey, span)
+}
+
+// SpanFromContext returns the span in ctx, or nil if there is none.
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey).(*Span)
+	return span
+}
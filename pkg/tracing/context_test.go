@@ -0,0 +1,190 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  string
+		sampled bool
+		err     bool
+	}{
+		{"sampled", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true, false},
+		{"not sampled", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", false, false},
+		{"future version with extra field", "cc-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-what", true, false},
+		{"version 00 with extra field", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-what", false, true},
+		{"forbidden version", "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false, true},
+		{"uppercase", "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", false, true},
+		{"zero trace id", "00-00000000000000000000000000000000-00f067aa0ba902b7-01", false, true},
+		{"zero parent id", "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", false, true},
+		{"short trace id", "00-4bf92f3577b34da6a3ce929d0e0e473-00f067aa0ba902b7-01", false, true},
+		{"missing field", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7", false, true},
+		{"empty", "", false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc, err := ParseTraceparent(tt.header)
+			if tt.err {
+				if !errors.Is(err, ErrInvalidTraceparent) {
+					t.Fatalf("expecting error %v but got %v", ErrInvalidTraceparent, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expecting no error but got %v", err)
+			}
+
+			if sc.IsSampled() != tt.sampled {
+				t.Fatalf("expecting sampled %v but got %v", tt.sampled, sc.IsSampled())
+			}
+
+			if got := sc.TraceID.String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+				t.Fatalf("unexpected trace id %s", got)
+			}
+
+			if got := sc.SpanID.String(); got != "00f067aa0ba902b7" {
+				t.Fatalf("unexpected span id %s", got)
+			}
+		})
+	}
+}
+
+func TestSpanContext_Traceparent(t *testing.T) {
+	header := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	sc, err := ParseTraceparent(header)
+	if err != nil {
+		t.Fatalf("expecting no error but got %v", err)
+	}
+
+	if got := sc.Traceparent(); got != header {
+		t.Fatalf("expecting %s but got %s", header, got)
+	}
+}
+
+func TestExtract(t *testing.T) {
+	h := make(http.Header)
+	h.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	h.Add(TracestateHeader, "rojo=00f067aa0ba902b7")
+	h.Add(TracestateHeader, "congo=t61rcWkgMzE")
+
+	sc, ok := Extract(h)
+	if !ok {
+		t.Fatalf("expecting span context is extracted")
+	}
+
+	if got := sc.TraceState.String(); got != "rojo=00f067aa0ba902b7,congo=t61rcWkgMzE" {
+		t.Fatalf("unexpected tracestate %s", got)
+	}
+
+	h.Set(TracestateHeader, "invalid")
+	sc, ok = Extract(h)
+	if !ok {
+		t.Fatalf("expecting the invalid tracestate does not discard the traceparent")
+	}
+
+	if len(sc.TraceState) != 0 {
+		t.Fatalf("expecting the invalid tracestate is discarded but got %s", sc.TraceState)
+	}
+
+	h.Set(TraceparentHeader, "invalid")
+	if _, ok := Extract(h); ok {
+		t.Fatalf("expecting the invalid traceparent is not extracted")
+	}
+}
+
+func TestInject(t *testing.T) {
+	h := make(http.Header)
+	Inject(context.Background(), h)
+	if h.Get(TraceparentHeader) != "" {
+		t.Fatalf("expecting no traceparent without span")
+	}
+
+	remote, _ := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	remote.TraceState = TraceState{{Key: "rojo", Value: "1"}}
+
+	tracer := NewTracer("test", NewMemoryExporter())
+	ctx, span := tracer.Start(context.Background(), "client", WithRemoteParent(remote))
+
+	Inject(ctx, h)
+
+	if got, want := h.Get(TraceparentHeader), span.SpanContext().Traceparent(); got != want {
+		t.Fatalf("expecting traceparent %s but got %s", want, got)
+	}
+
+	if got := h.Get(TracestateHeader); got != "rojo=1" {
+		t.Fatalf("expecting tracestate rojo=1 but got %s", got)
+	}
+}
+
+func TestParseTraceState(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+		err    bool
+	}{
+		{"", "", false},
+		{"rojo=00f067aa0ba902b7", "rojo=00f067aa0ba902b7", false},
+		{" rojo=1 , , congo=2 ", "rojo=1,congo=2", false},
+		{"tenant@vendor=1", "tenant@vendor=1", false},
+		{"rojo=1,rojo=2", "", true},
+		{"Rojo=1", "", true},
+		{"rojo", "", true},
+		{"rojo=a,b", "", true},
+		{"rojo=a=b", "", true},
+	}
+
+	for _, tt := range tests {
+		ts, err := ParseTraceState(tt.header)
+		if tt.err {
+			if !errors.Is(err, ErrInvalidTracestate) {
+				t.Errorf("%q: expecting error %v but got %v", tt.header, ErrInvalidTracestate, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%q: expecting no error but got %v", tt.header, err)
+			continue
+		}
+
+		if got := ts.String(); got != tt.want {
+			t.Errorf("%q: expecting %q but got %q", tt.header, tt.want, got)
+		}
+	}
+}
+
+func TestTraceState_Insert(t *testing.T) {
+	ts, err := ParseTraceState("rojo=1,congo=2")
+	if err != nil {
+		t.Fatalf("expecting no error but got %v", err)
+	}
+
+	updated, err := ts.Insert("congo", "3")
+	if err != nil {
+		t.Fatalf("expecting no error but got %v", err)
+	}
+
+	if got := updated.String(); got != "congo=3,rojo=1" {
+		t.Fatalf("expecting congo=3,rojo=1 but got %s", got)
+	}
+
+	if got := ts.String(); got != "rojo=1,congo=2" {
+		t.Fatalf("expecting the original list is not changed but got %s", got)
+	}
+
+	if updated.Get("congo") != "3" || updated.Get("unknown") != "" {
+		t.Fatalf("unexpected Get result")
+	}
+
+	if _, err := ts.Insert("Invalid", "1"); !errors.Is(err, ErrInvalidTracestate) {
+		t.Fatalf("expecting error %v but got %v", ErrInvalidTracestate, err)
+	}
+}
@@ -0,0 +1,101 @@
+package tracing
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/josestg/justforfun/pkg/xerrs"
+)
+
+// Exporter knows how to export the ended spans.
+// The Exporter must be safe for concurrent use.
+type Exporter interface {
+	// Export exports a single ended span.
+	Export(span SpanData) error
+}
+
+// JSONLinesExporter writes each span as a JSON object on its own line.
+type JSONLinesExporter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewJSONLinesExporter creates a new JSONLinesExporter that writes into w.
+func NewJSONLinesExporter(w io.Writer) *JSONLinesExporter {
+	return &JSONLinesExporter{w: w}
+}
+
+// NewFileExporter creates a new JSONLinesExporter that appends to the file at path.
+// The file is created if it does not exist. Call Close to close the file.
+func NewFileExporter(path string) (*JSONLinesExporter, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, xerrs.Wrap(err, "opening trace file")
+	}
+
+	return NewJSONLinesExporter(f), nil
+}
+
+func (e *JSONLinesExporter) Export(span SpanData) error {
+	b, err := json.Marshal(span)
+	if err != nil {
+		return xerrs.Wrap(err, "encoding span")
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if _, err := e.w.Write(append(b, '\n')); err != nil {
+		return xerrs.Wrap(err, "writing span")
+	}
+
+	return nil
+}
+
+// Close closes the underlying writer if it is an io.Closer.
+func (e *JSONLinesExporter) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if c, ok := e.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// MemoryExporter keeps the exported spans in memory, it is useful for testing.
+type MemoryExporter struct {
+	mu    sync.Mutex
+	spans []SpanData
+}
+
+// NewMemoryExporter creates a new empty MemoryExporter.
+func NewMemoryExporter() *MemoryExporter {
+	return &MemoryExporter{}
+}
+
+func (e *MemoryExporter) Export(span SpanData) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.spans = append(e.spans, span)
+	return nil
+}
+
+// Spans returns the exported spans in the export order.
+func (e *MemoryExporter) Spans() []SpanData {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return append([]SpanData(nil), e.spans...)
+}
+
+// Reset removes all exported spans.
+func (e *MemoryExporter) Reset() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.spans = nil
+}
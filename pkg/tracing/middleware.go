@@ -0,0 +1,62 @@
+package tracing
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/josestg/justforfun/pkg/mux"
+)
+
+// Middleware creates a middleware that starts a server span for each request.
+//
+// The span continues the trace of the incoming traceparent and tracestate
+// headers, or starts a new trace. The span is named by the method and the route
+// pattern, and it is marked as failed for the 5xx responses. The handlers can
+// start the child spans from the request context.
+func Middleware(tracer *Tracer) mux.Middleware {
+	return func(handler mux.Handler) mux.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) error {
+			state, err := mux.GetState(r.Context())
+			if err != nil {
+				return mux.NewShutdownError(err.Error())
+			}
+
+			route := state.Route
+			if route == "" {
+				route = "unmatched"
+			}
+
+			options := []StartOption{
+				WithKind(KindServer),
+				WithAttributes(map[string]interface{}{
+					"http.method":     r.Method,
+					"http.target":     r.URL.RequestURI(),
+					"http.route":      state.Route,
+					"http.request_id": state.RequestID,
+				}),
+			}
+
+			if remote, ok := Extract(r.Header); ok {
+				options = append(options, WithRemoteParent(remote))
+			}
+
+			ctx, span := tracer.Start(r.Context(), r.Method+" "+route, options...)
+			defer span.End()
+
+			err = handler.ServeHTTP(w, r.WithContext(ctx))
+
+			span.SetAttribute("http.status_code", state.StatusCode)
+			if state.StatusCode >= http.StatusInternalServerError {
+				cause := state.Err
+				if cause == nil {
+					cause = fmt.Errorf("%d %s", state.StatusCode, http.StatusText(state.StatusCode))
+				}
+				span.SetError(cause)
+			}
+
+			return err
+		}
+
+		return mux.HandlerFunc(fn)
+	}
+}
@@ -0,0 +1,71 @@
+package tracing
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/josestg/justforfun/pkg/mux"
+)
+
+func TestMiddleware(t *testing.T) {
+	exporter := NewMemoryExporter()
+	tracer := NewTracer("test", exporter)
+
+	var inner SpanContext
+	router := mux.NewRouter(nil, Middleware(tracer))
+	router.Handle("GET /users/{id}", mux.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		ctx, span := Start(r.Context(), "query")
+		defer span.End()
+
+		inner = SpanFromContext(ctx).SpanContext()
+		return nil
+	}))
+	router.Handle("GET /failed", mux.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		return errors.New("boom")
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+	req.Header.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := exporter.Spans()
+	if len(spans) != 2 {
+		t.Fatalf("expecting 2 spans but got %d", len(spans))
+	}
+
+	query, server := spans[0], spans[1]
+	if server.Name != "GET /users/{id}" || server.Kind != KindServer {
+		t.Fatalf("unexpected server span %+v", server)
+	}
+
+	if server.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || server.ParentID.String() != "00f067aa0ba902b7" {
+		t.Fatalf("expecting the server span continues the incoming trace")
+	}
+
+	if query.ParentID != server.SpanID || query.SpanID != inner.SpanID {
+		t.Fatalf("expecting the handler span is the child of the server span")
+	}
+
+	if server.Attributes["http.status_code"] != http.StatusOK || server.Status != StatusOK {
+		t.Fatalf("unexpected server span status %+v", server)
+	}
+
+	exporter.Reset()
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/failed", nil))
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/unknown", nil))
+
+	spans = exporter.Spans()
+	if len(spans) != 2 {
+		t.Fatalf("expecting 2 spans but got %d", len(spans))
+	}
+
+	if spans[0].Name != "GET /failed" || spans[0].Status != StatusError || spans[0].ParentID.IsValid() {
+		t.Fatalf("expecting the failed request is a failed root span but got %+v", spans[0])
+	}
+
+	if spans[1].Name != "GET unmatched" || spans[1].Status != StatusOK {
+		t.Fatalf("expecting the unmatched request is not failed but got %+v", spans[1])
+	}
+}
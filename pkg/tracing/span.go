@@ -0,0 +1,263 @@
+package tracing
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Kind is the role of the span in the trace.
+type Kind string
+
+// Span kinds.
+const (
+	KindInternal Kind = "internal"
+	KindServer   Kind = "server"
+	KindClient   Kind = "client"
+)
+
+// Status is the status of the span.
+type Status string
+
+// Span statuses.
+const (
+	StatusOK    Status = "ok"
+	StatusError Status = "error"
+)
+
+// SpanData is the recorded span that is given to the Exporter.
+type SpanData struct {
+	TraceID       TraceID                `json:"trace_id"`
+	SpanID        SpanID                 `json:"span_id"`
+	ParentID      SpanID                 `json:"parent_id"`
+	TraceState    TraceState             `json:"trace_state,omitempty"`
+	Service       string                 `json:"service"`
+	Name          string                 `json:"name"`
+	Kind          Kind                   `json:"kind"`
+	Start         time.Time              `json:"start"`
+	End           time.Time              `json:"end"`
+	Duration      time.Duration          `json:"duration_ns"`
+	Attributes    map[string]interface{} `json:"attributes,omitempty"`
+	Status        Status                 `json:"status"`
+	StatusMessage string                 `json:"status_message,omitempty"`
+}
+
+// Span is a single operation of a trace.
+// The methods of the nil Span do nothing, so the caller does not need to check
+// whether the operation is traced.
+type Span struct {
+	tracer  *Tracer
+	sampled bool
+	sc      SpanContext
+
+	mu    sync.Mutex
+	data  SpanData
+	ended bool
+}
+
+// SpanContext returns the span context to propagate.
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.sc
+}
+
+// SetName replaces the span name, for example when the route is known after routing.
+func (s *Span) SetName(name string) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Name = name
+}
+
+// SetAttribute sets the attribute of the span.
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.data.Attributes == nil {
+		s.data.Attributes = make(map[string]interface{})
+	}
+	s.data.Attributes[key] = value
+}
+
+// SetError marks the span as failed if err is not nil.
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data.Status = StatusError
+	s.data.StatusMessage = err.Error()
+}
+
+// End completes the span and exports it if the trace is sampled.
+// The calls after the first one do nothing.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+
+	s.ended = true
+	s.data.End = time.Now()
+	s.data.Duration = s.data.End.Sub(s.data.Start)
+	data := s.data
+	s.mu.Unlock()
+
+	if s.sampled {
+		if err := s.tracer.exporter.Export(data); err != nil {
+			s.tracer.onError(err)
+		}
+	}
+}
+
+// TracerOption is an option type that can be used to customize the Tracer.
+type TracerOption func(t *Tracer)
+
+// WithErrorHandler sets the handler of the export errors.
+// The errors are ignored by default.
+func WithErrorHandler(fn func(err error)) TracerOption {
+	return func(t *Tracer) {
+		t.onError = fn
+	}
+}
+
+// Tracer creates the spans and exports them to its Exporter.
+type Tracer struct {
+	service  string
+	exporter Exporter
+	onError  func(err error)
+}
+
+// NewTracer creates a new Tracer for the given service.
+func NewTracer(service string, exporter Exporter, options ...TracerOption) *Tracer {
+	t := Tracer{
+		service:  service,
+		exporter: exporter,
+		onError:  func(error) {},
+	}
+
+	for _, fn := range options {
+		fn(&t)
+	}
+
+	return &t
+}
+
+// StartOption is an option type that can be used to customize the started span.
+type StartOption func(c *startConfig)
+
+// startConfig holds the options of the started span.
+type startConfig struct {
+	kind       Kind
+	attributes map[string]interface{}
+	remote     *SpanContext
+}
+
+// WithKind sets the span kind, the default is KindInternal.
+func WithKind(kind Kind) StartOption {
+	return func(c *startConfig) {
+		c.kind = kind
+	}
+}
+
+// WithAttributes sets the initial attributes of the span.
+func WithAttributes(attributes map[string]interface{}) StartOption {
+	return func(c *startConfig) {
+		for k, v := range attributes {
+			c.attributes[k] = v
+		}
+	}
+}
+
+// WithRemoteParent makes the span a child of the span context extracted from
+// the incoming request, instead of the span in the context.
+func WithRemoteParent(sc SpanContext) StartOption {
+	return func(c *startConfig) {
+		if sc.IsValid() {
+			c.remote = &sc
+		}
+	}
+}
+
+// Start starts a new span. The span is a child of the remote parent if given,
+// otherwise of the span in ctx, otherwise it starts a new sampled trace.
+// The returned context holds the new span.
+func (t *Tracer) Start(ctx context.Context, name string, options ...StartOption) (context.Context, *Span) {
+	c := startConfig{
+		kind:       KindInternal,
+		attributes: make(map[string]interface{}),
+	}
+
+	for _, fn := range options {
+		fn(&c)
+	}
+
+	var parent SpanContext
+	switch {
+	case c.remote != nil:
+		parent = *c.remote
+	case SpanFromContext(ctx) != nil:
+		parent = SpanFromContext(ctx).SpanContext()
+	default:
+		parent = SpanContext{TraceID: newTraceID(), Flags: FlagSampled}
+	}
+
+	sc := SpanContext{
+		TraceID:    parent.TraceID,
+		SpanID:     newSpanID(),
+		Flags:      parent.Flags,
+		TraceState: parent.TraceState,
+	}
+
+	span := &Span{
+		tracer:  t,
+		sampled: sc.IsSampled(),
+		sc:      sc,
+		data: SpanData{
+			TraceID:    sc.TraceID,
+			SpanID:     sc.SpanID,
+			ParentID:   parent.SpanID,
+			TraceState: sc.TraceState,
+			Service:    t.service,
+			Name:       name,
+			Kind:       c.kind,
+			Start:      time.Now(),
+			Status:     StatusOK,
+		},
+	}
+
+	if len(c.attributes) != 0 {
+		span.data.Attributes = c.attributes
+	}
+
+	return ContextWithSpan(ctx, span), span
+}
+
+// Start starts a child span of the span in ctx with the same Tracer.
+// If ctx has no span, the operation is not traced and the returned span is nil,
+// so the libraries can create spans without knowing the Tracer.
+func Start(ctx context.Context, name string, options ...StartOption) (context.Context, *Span) {
+	parent := SpanFromContext(ctx)
+	if parent == nil {
+		return ctx, nil
+	}
+	return parent.tracer.Start(ctx, name, options...)
+}
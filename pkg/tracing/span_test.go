@@ -0,0 +1,113 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestTracer_Start(t *testing.T) {
+	exporter := NewMemoryExporter()
+	tracer := NewTracer("test", exporter)
+
+	ctx, root := tracer.Start(context.Background(), "root")
+	_, child := Start(ctx, "child", WithAttributes(map[string]interface{}{"key": "value"}))
+	child.SetError(errors.New("boom"))
+	child.End()
+	root.End()
+	root.End()
+
+	spans := exporter.Spans()
+	if len(spans) != 2 {
+		t.Fatalf("expecting 2 spans but got %d", len(spans))
+	}
+
+	c, r := spans[0], spans[1]
+	if r.Name != "root" || c.Name != "child" {
+		t.Fatalf("expecting child then root but got %s then %s", c.Name, r.Name)
+	}
+
+	if r.ParentID.IsValid() {
+		t.Fatalf("expecting root has no parent but got %s", r.ParentID)
+	}
+
+	if c.TraceID != r.TraceID || c.ParentID != r.SpanID {
+		t.Fatalf("expecting child of root")
+	}
+
+	if c.Status != StatusError || c.StatusMessage != "boom" || r.Status != StatusOK {
+		t.Fatalf("unexpected statuses %s %s", c.Status, r.Status)
+	}
+
+	if c.Attributes["key"] != "value" || c.Service != "test" {
+		t.Fatalf("unexpected child span %+v", c)
+	}
+}
+
+func TestTracer_Start_RemoteParent(t *testing.T) {
+	exporter := NewMemoryExporter()
+	tracer := NewTracer("test", exporter)
+
+	remote, _ := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	_, span := tracer.Start(context.Background(), "server", WithRemoteParent(remote))
+	span.End()
+
+	got := exporter.Spans()[0]
+	if got.TraceID != remote.TraceID || got.ParentID != remote.SpanID {
+		t.Fatalf("expecting child of the remote parent")
+	}
+
+	remote.Flags = 0
+	_, span = tracer.Start(context.Background(), "server", WithRemoteParent(remote))
+	span.End()
+
+	if n := len(exporter.Spans()); n != 1 {
+		t.Fatalf("expecting the not sampled span is not exported, got %d spans", n)
+	}
+}
+
+func TestStart_WithoutSpan(t *testing.T) {
+	ctx := context.Background()
+
+	got, span := Start(ctx, "untraced")
+	if span != nil || got != ctx {
+		t.Fatalf("expecting no span without a parent")
+	}
+
+	// the nil span is no-op.
+	span.SetName("name")
+	span.SetAttribute("key", "value")
+	span.SetError(errors.New("boom"))
+	span.End()
+
+	if span.SpanContext().IsValid() {
+		t.Fatalf("expecting invalid span context")
+	}
+}
+
+func TestJSONLinesExporter(t *testing.T) {
+	var buf bytes.Buffer
+	tracer := NewTracer("test", NewJSONLinesExporter(&buf))
+
+	ctx, root := tracer.Start(context.Background(), "root")
+	_, child := Start(ctx, "child")
+	child.End()
+	root.End()
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expecting 2 lines but got %d", len(lines))
+	}
+
+	var span map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &span); err != nil {
+		t.Fatalf("expecting no error but got %v", err)
+	}
+
+	if span["name"] != "child" || span["trace_id"] != root.SpanContext().TraceID.String() || span["parent_id"] != root.SpanContext().SpanID.String() {
+		t.Fatalf("unexpected span %s", lines[0])
+	}
+}
@@ -0,0 +1,109 @@
+package tracing
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// maxTraceStateMembers is the maximum number of the tracestate list members.
+const maxTraceStateMembers = 32
+
+var (
+	traceStateKeyRe   = regexp.MustCompile(`^([a-z][a-z0-9_\-*/]{0,255}|[a-z0-9][a-z0-9_\-*/]{0,240}@[a-z][a-z0-9_\-*/]{0,13})$`)
+	traceStateValueRe = regexp.MustCompile(`^[\x20-\x2b\x2d-\x3c\x3e-\x7e]{0,255}[\x21-\x2b\x2d-\x3c\x3e-\x7e]$`)
+)
+
+// Member is a tracestate list member.
+type Member struct {
+	Key   string
+	Value string
+}
+
+// TraceState is the vendor-specific trace data, the left-most member is the
+// most recently updated one.
+type TraceState []Member
+
+// ParseTraceState parses the tracestate header value.
+// The empty list members are ignored.
+func ParseTraceState(s string) (TraceState, error) {
+	ts := make(TraceState, 0)
+	seen := make(map[string]struct{})
+
+	for _, member := range strings.Split(s, ",") {
+		member = strings.TrimSpace(member)
+		if member == "" {
+			continue
+		}
+
+		i := strings.IndexByte(member, '=')
+		if i < 0 {
+			return nil, fmt.Errorf("%w: missing '=' in %q", ErrInvalidTracestate, member)
+		}
+
+		key, value := member[:i], member[i+1:]
+		if !traceStateKeyRe.MatchString(key) || !traceStateValueRe.MatchString(value) {
+			return nil, fmt.Errorf("%w: invalid member %q", ErrInvalidTracestate, member)
+		}
+
+		if _, exist := seen[key]; exist {
+			return nil, fmt.Errorf("%w: duplicate key %q", ErrInvalidTracestate, key)
+		}
+		seen[key] = struct{}{}
+
+		ts = append(ts, Member{Key: key, Value: value})
+	}
+
+	if len(ts) > maxTraceStateMembers {
+		return nil, fmt.Errorf("%w: more than %d members", ErrInvalidTracestate, maxTraceStateMembers)
+	}
+
+	return ts, nil
+}
+
+// Get returns the value of the key.
+func (ts TraceState) Get(key string) string {
+	for _, m := range ts {
+		if m.Key == key {
+			return m.Value
+		}
+	}
+	return ""
+}
+
+// Insert returns a new TraceState with the key moved to the left-most position
+// with the new value, as a vendor does when it updates its entry.
+func (ts TraceState) Insert(key, value string) (TraceState, error) {
+	if !traceStateKeyRe.MatchString(key) || !traceStateValueRe.MatchString(value) {
+		return nil, fmt.Errorf("%w: invalid member %s=%s", ErrInvalidTracestate, key, value)
+	}
+
+	updated := make(TraceState, 0, len(ts)+1)
+	updated = append(updated, Member{Key: key, Value: value})
+	for _, m := range ts {
+		if m.Key != key {
+			updated = append(updated, m)
+		}
+	}
+
+	// the right-most members are dropped when the list is full.
+	if len(updated) > maxTraceStateMembers {
+		updated = updated[:maxTraceStateMembers]
+	}
+
+	return updated, nil
+}
+
+// String formats the list as the tracestate header value.
+func (ts TraceState) String() string {
+	members := make([]string, 0, len(ts))
+	for _, m := range ts {
+		members = append(members, m.Key+"="+m.Value)
+	}
+	return strings.Join(members, ",")
+}
+
+// MarshalText encodes the list as the header value.
+func (ts TraceState) MarshalText() ([]byte, error) {
+	return []byte(ts.String()), nil
+}
@@ -0,0 +1,160 @@
+package websocket
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// WithHeader adds the header to the handshake request of Dial, for example
+// the Authorization or the Origin. It is ignored by Upgrade.
+func WithHeader(h http.Header) Option {
+	return func(c *config) {
+		c.header = h
+	}
+}
+
+// WithTLSConfig sets the TLS config of the wss and https URLs of Dial.
+// It is ignored by Upgrade.
+func WithTLSConfig(cfg *tls.Config) Option {
+	return func(c *config) {
+		c.tlsConfig = cfg
+	}
+}
+
+// Dial opens a client connection to the WebSocket server. The URL scheme is
+// ws or wss, and http or https are accepted too, so the URL of an
+// httptest.Server can be used as is:
+//
+// 	conn, _, err := websocket.Dial(ctx, srv.URL+"/v1/chat")
+//
+// If the server does not switch the protocols, it returns ErrBadHandshake
+// with the response, the response body is not readable.
+func Dial(ctx context.Context, rawURL string, options ...Option) (*Conn, *http.Response, error) {
+	cfg := newConfig(options)
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	secure := false
+	switch u.Scheme {
+	case "ws", "http":
+	case "wss", "https":
+		secure = true
+	default:
+		return nil, nil, fmt.Errorf("websocket: unsupported scheme %q", u.Scheme)
+	}
+
+	addr := u.Host
+	if u.Port() == "" {
+		if secure {
+			addr = net.JoinHostPort(u.Hostname(), "443")
+		} else {
+			addr = net.JoinHostPort(u.Hostname(), "80")
+		}
+	}
+
+	var d net.Dialer
+	netConn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = netConn.SetDeadline(deadline)
+	}
+
+	if secure {
+		tlsConfig := cfg.tlsConfig.Clone()
+		if tlsConfig == nil {
+			tlsConfig = &tls.Config{}
+		}
+		if tlsConfig.ServerName == "" {
+			tlsConfig.ServerName = u.Hostname()
+		}
+
+		tlsConn := tls.Client(netConn, tlsConfig)
+		if err := tlsConn.Handshake(); err != nil {
+			netConn.Close()
+			return nil, nil, err
+		}
+		netConn = tlsConn
+	}
+
+	c, res, err := handshake(netConn, u, cfg)
+	if err != nil {
+		netConn.Close()
+		return nil, res, err
+	}
+
+	_ = netConn.SetDeadline(time.Time{})
+	return c, res, nil
+}
+
+// handshake sends the handshake request and checks the response.
+func handshake(netConn net.Conn, u *url.URL, cfg config) (*Conn, *http.Response, error) {
+	var nonce [16]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return nil, nil, err
+	}
+	key := base64.StdEncoding.EncodeToString(nonce[:])
+
+	req := &http.Request{
+		Method:     http.MethodGet,
+		URL:        &url.URL{Path: u.Path, RawPath: u.RawPath, RawQuery: u.RawQuery},
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     make(http.Header),
+		Host:       u.Host,
+	}
+
+	for k, v := range cfg.header {
+		req.Header[k] = v
+	}
+
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	for _, p := range cfg.subprotocols {
+		req.Header.Add("Sec-WebSocket-Protocol", p)
+	}
+
+	bw := bufio.NewWriter(netConn)
+	if err := req.Write(bw); err != nil {
+		return nil, nil, err
+	}
+	if err := bw.Flush(); err != nil {
+		return nil, nil, err
+	}
+
+	br := bufio.NewReader(netConn)
+	res, err := http.ReadResponse(br, req)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if res.StatusCode != http.StatusSwitchingProtocols ||
+		!headerContains(res.Header, "Upgrade", "websocket") ||
+		!headerContains(res.Header, "Connection", "upgrade") ||
+		res.Header.Get("Sec-WebSocket-Accept") != acceptKey(key) {
+		return nil, res, fmt.Errorf("%w: status %s", ErrBadHandshake, res.Status)
+	}
+
+	subprotocol := res.Header.Get("Sec-WebSocket-Protocol")
+	if subprotocol != "" && selectSubprotocol(cfg.subprotocols, []string{subprotocol}) == "" {
+		return nil, res, fmt.Errorf("%w: unexpected subprotocol %q", ErrBadHandshake, subprotocol)
+	}
+
+	return newConn(netConn, br, bw, true, subprotocol, cfg), res, nil
+}
@@ -0,0 +1,505 @@
+package websocket
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// MessageType is the type of the data message.
+type MessageType int
+
+// Types of the data messages.
+const (
+	TextMessage   MessageType = 1
+	BinaryMessage MessageType = 2
+)
+
+// Opcodes of the frames.
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xa
+)
+
+// maxControlPayload is the maximum payload size of the control frames.
+const maxControlPayload = 125
+
+// Close codes, see RFC 6455 section 7.4.1. The CloseNoStatus and the
+// CloseAbnormal are never sent, they are reported when the peer closes
+// without a code or without a close frame.
+const (
+	CloseNormal             = 1000
+	CloseGoingAway          = 1001
+	CloseProtocolError      = 1002
+	CloseUnsupportedData    = 1003
+	CloseNoStatus           = 1005
+	CloseAbnormal           = 1006
+	CloseInvalidPayload     = 1007
+	ClosePolicyViolation    = 1008
+	CloseMessageTooBig      = 1009
+	CloseMandatoryExtension = 1010
+	CloseInternalError      = 1011
+)
+
+var (
+	// ErrProtocol is an error when the peer violates the protocol,
+	// the connection is closed with CloseProtocolError.
+	ErrProtocol = errors.New("websocket: protocol error")
+
+	// ErrInvalidUTF8 is an error when the text message is not valid UTF-8,
+	// the connection is closed with CloseInvalidPayload.
+	ErrInvalidUTF8 = errors.New("websocket: invalid UTF-8 text")
+
+	// ErrReadLimit is an error when the message exceeds the read limit,
+	// the connection is closed with CloseMessageTooBig.
+	ErrReadLimit = errors.New("websocket: message exceeds the read limit")
+
+	// ErrCloseSent is an error when a message is written after the close frame.
+	ErrCloseSent = errors.New("websocket: close sent")
+)
+
+// CloseError is the close code and reason of the close frame sent by the peer.
+type CloseError struct {
+	Code   int
+	Reason string
+}
+
+func (e *CloseError) Error() string {
+	if e.Reason == "" {
+		return "websocket: close " + strconv.Itoa(e.Code)
+	}
+	return "websocket: close " + strconv.Itoa(e.Code) + ": " + e.Reason
+}
+
+// CloseCode returns the close code of the *CloseError, or CloseAbnormal if
+// err is another error, which means the connection is closed without a close frame.
+func CloseCode(err error) int {
+	var closeErr *CloseError
+	if errors.As(err, &closeErr) {
+		return closeErr.Code
+	}
+	return CloseAbnormal
+}
+
+// Conn is a WebSocket connection.
+//
+// The messages are read by one goroutine at a time, and they can be written
+// by any goroutines, the writes are serialized. The pings are answered while
+// reading.
+type Conn struct {
+	conn        net.Conn
+	br          *bufio.Reader
+	bw          *bufio.Writer
+	client      bool
+	subprotocol string
+	readLimit   int64
+	pongHandler func(data []byte)
+
+	// msgMu is held while a fragmented message is written, so the other
+	// messages can not be interleaved, but the control frames can.
+	msgMu sync.Mutex
+
+	// wmu is held while a frame is written.
+	wmu       sync.Mutex
+	closeSent bool
+}
+
+// newConn creates the Conn of the upgraded connection.
+func newConn(conn net.Conn, br *bufio.Reader, bw *bufio.Writer, client bool, subprotocol string, cfg config) *Conn {
+	return &Conn{
+		conn:        conn,
+		br:          br,
+		bw:          bw,
+		client:      client,
+		subprotocol: subprotocol,
+		readLimit:   cfg.readLimit,
+		pongHandler: cfg.pongHandler,
+	}
+}
+
+// Subprotocol returns the negotiated subprotocol, or empty if there is none.
+func (c *Conn) Subprotocol() string { return c.subprotocol }
+
+// LocalAddr returns the local network address.
+func (c *Conn) LocalAddr() net.Addr { return c.conn.LocalAddr() }
+
+// RemoteAddr returns the remote network address.
+func (c *Conn) RemoteAddr() net.Addr { return c.conn.RemoteAddr() }
+
+// SetReadDeadline sets the deadline of the reads, the zero t means no deadline.
+func (c *Conn) SetReadDeadline(t time.Time) error { return c.conn.SetReadDeadline(t) }
+
+// SetWriteDeadline sets the deadline of the writes, the zero t means no deadline.
+func (c *Conn) SetWriteDeadline(t time.Time) error { return c.conn.SetWriteDeadline(t) }
+
+// Close closes the underlying connection without the close handshake,
+// see WriteClose.
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
+
+// WriteMessage writes the message as a single frame.
+func (c *Conn) WriteMessage(typ MessageType, data []byte) error {
+	if typ != TextMessage && typ != BinaryMessage {
+		return fmt.Errorf("websocket: invalid message type %d", typ)
+	}
+
+	c.msgMu.Lock()
+	defer c.msgMu.Unlock()
+
+	return c.writeFrame(true, byte(typ), data)
+}
+
+// NextWriter returns a writer of a fragmented message, each Write sends a
+// frame, and Close sends the final frame. The other messages are written after
+// the writer is closed, so the writer must be closed.
+func (c *Conn) NextWriter(typ MessageType) (io.WriteCloser, error) {
+	if typ != TextMessage && typ != BinaryMessage {
+		return nil, fmt.Errorf("websocket: invalid message type %d", typ)
+	}
+
+	c.msgMu.Lock()
+	return &messageWriter{c: c, opcode: byte(typ)}, nil
+}
+
+// messageWriter writes the frames of a fragmented message.
+type messageWriter struct {
+	c      *Conn
+	opcode byte
+	closed bool
+}
+
+func (w *messageWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, errors.New("websocket: write to closed writer")
+	}
+
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	if err := w.c.writeFrame(false, w.opcode, p); err != nil {
+		return 0, err
+	}
+
+	w.opcode = opContinuation
+	return len(p), nil
+}
+
+func (w *messageWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+
+	w.closed = true
+	defer w.c.msgMu.Unlock()
+	return w.c.writeFrame(true, w.opcode, nil)
+}
+
+// Ping sends a ping with the payload of at most 125 bytes,
+// the peer answers with a pong, see WithPongHandler.
+func (c *Conn) Ping(data []byte) error {
+	if len(data) > maxControlPayload {
+		return errors.New("websocket: control payload too large")
+	}
+	return c.writeFrame(true, opPing, data)
+}
+
+// WriteClose starts the close handshake by sending the close frame with the
+// code and the reason, the peer answers with its close frame, which is read
+// as the *CloseError. Nothing can be written after the close frame.
+func (c *Conn) WriteClose(code int, reason string) error {
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	payload = append(payload, reason...)
+
+	if len(payload) > maxControlPayload {
+		return errors.New("websocket: close reason too long")
+	}
+
+	return c.writeFrame(true, opClose, payload)
+}
+
+// writeFrame writes a frame, the client frames are masked.
+func (c *Conn) writeFrame(fin bool, opcode byte, payload []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+
+	if c.closeSent {
+		return ErrCloseSent
+	}
+
+	if opcode == opClose {
+		c.closeSent = true
+	}
+
+	var header [14]byte
+	header[0] = opcode
+	if fin {
+		header[0] |= 0x80
+	}
+
+	n := 2
+	switch length := len(payload); {
+	case length <= 125:
+		header[1] = byte(length)
+	case length <= 0xffff:
+		header[1] = 126
+		binary.BigEndian.PutUint16(header[2:], uint16(length))
+		n += 2
+	default:
+		header[1] = 127
+		binary.BigEndian.PutUint64(header[2:], uint64(length))
+		n += 8
+	}
+
+	if c.client {
+		header[1] |= 0x80
+
+		var key [4]byte
+		if _, err := rand.Read(key[:]); err != nil {
+			return err
+		}
+		copy(header[n:], key[:])
+		n += 4
+
+		masked := make([]byte, len(payload))
+		copy(masked, payload)
+		maskBytes(key, masked)
+		payload = masked
+	}
+
+	if _, err := c.bw.Write(header[:n]); err != nil {
+		return err
+	}
+
+	if _, err := c.bw.Write(payload); err != nil {
+		return err
+	}
+
+	return c.bw.Flush()
+}
+
+// frameHeader is the decoded header of a frame.
+type frameHeader struct {
+	fin    bool
+	rsv    byte
+	opcode byte
+	masked bool
+	length uint64
+	mask   [4]byte
+}
+
+// readHeader reads the header of the next frame.
+func (c *Conn) readHeader() (frameHeader, error) {
+	var b [8]byte
+	if _, err := io.ReadFull(c.br, b[:2]); err != nil {
+		return frameHeader{}, err
+	}
+
+	h := frameHeader{
+		fin:    b[0]&0x80 != 0,
+		rsv:    b[0] & 0x70,
+		opcode: b[0] & 0x0f,
+		masked: b[1]&0x80 != 0,
+		length: uint64(b[1] & 0x7f),
+	}
+
+	switch h.length {
+	case 126:
+		if _, err := io.ReadFull(c.br, b[:2]); err != nil {
+			return frameHeader{}, err
+		}
+		h.length = uint64(binary.BigEndian.Uint16(b[:2]))
+	case 127:
+		if _, err := io.ReadFull(c.br, b[:8]); err != nil {
+			return frameHeader{}, err
+		}
+		h.length = binary.BigEndian.Uint64(b[:8])
+	}
+
+	if h.masked {
+		if _, err := io.ReadFull(c.br, h.mask[:]); err != nil {
+			return frameHeader{}, err
+		}
+	}
+
+	return h, nil
+}
+
+// ReadMessage reads the next data message, the fragmented message is
+// reassembled. The pings are answered and the pongs are passed to the pong
+// handler while reading.
+//
+// If the peer sends the close frame, the close frame is echoed unless it is
+// already sent, and it returns the *CloseError. If the peer violates the
+// protocol, the connection is closed with the matching close code, and it
+// returns ErrProtocol, ErrInvalidUTF8 or ErrReadLimit. The connection must be
+// closed once ReadMessage returns an error.
+func (c *Conn) ReadMessage() (MessageType, []byte, error) {
+	var (
+		typ     MessageType
+		message []byte
+		started bool
+	)
+
+	for {
+		h, err := c.readHeader()
+		if err != nil {
+			return 0, nil, err
+		}
+
+		if err := c.checkHeader(h, started); err != nil {
+			return 0, nil, c.fail(CloseProtocolError, err)
+		}
+
+		isControl := h.opcode&0x8 != 0
+		if !isControl && h.length > uint64(c.readLimit)-uint64(len(message)) {
+			return 0, nil, c.fail(CloseMessageTooBig, ErrReadLimit)
+		}
+
+		payload := make([]byte, h.length)
+		if _, err := io.ReadFull(c.br, payload); err != nil {
+			return 0, nil, err
+		}
+
+		if h.masked {
+			maskBytes(h.mask, payload)
+		}
+
+		switch h.opcode {
+		case opPing:
+			if err := c.writeFrame(true, opPong, payload); err != nil && !errors.Is(err, ErrCloseSent) {
+				return 0, nil, err
+			}
+			continue
+		case opPong:
+			if c.pongHandler != nil {
+				c.pongHandler(payload)
+			}
+			continue
+		case opClose:
+			return 0, nil, c.readClose(payload)
+		case opText, opBinary:
+			typ, started = MessageType(h.opcode), true
+		}
+
+		message = append(message, payload...)
+		if !h.fin {
+			continue
+		}
+
+		if typ == TextMessage && !utf8.Valid(message) {
+			return 0, nil, c.fail(CloseInvalidPayload, ErrInvalidUTF8)
+		}
+
+		return typ, message, nil
+	}
+}
+
+// checkHeader validates the frame header against the message being read.
+func (c *Conn) checkHeader(h frameHeader, started bool) error {
+	switch {
+	case h.rsv != 0:
+		return fmt.Errorf("%w: reserved bits are set", ErrProtocol)
+	case h.masked == c.client:
+		if c.client {
+			return fmt.Errorf("%w: server frame is masked", ErrProtocol)
+		}
+		return fmt.Errorf("%w: client frame is not masked", ErrProtocol)
+	case h.length>>63 != 0:
+		return fmt.Errorf("%w: invalid payload length", ErrProtocol)
+	}
+
+	switch h.opcode {
+	case opContinuation:
+		if !started {
+			return fmt.Errorf("%w: continuation frame without a message", ErrProtocol)
+		}
+	case opText, opBinary:
+		if started {
+			return fmt.Errorf("%w: data frame inside a fragmented message", ErrProtocol)
+		}
+	case opClose, opPing, opPong:
+		if !h.fin || h.length > maxControlPayload {
+			return fmt.Errorf("%w: control frame is fragmented or too large", ErrProtocol)
+		}
+	default:
+		return fmt.Errorf("%w: reserved opcode %d", ErrProtocol, h.opcode)
+	}
+
+	return nil
+}
+
+// readClose decodes the close frame of the peer and echoes it.
+func (c *Conn) readClose(payload []byte) error {
+	closeErr := &CloseError{Code: CloseNoStatus}
+
+	switch {
+	case len(payload) == 1:
+		return c.fail(CloseProtocolError, fmt.Errorf("%w: invalid close payload", ErrProtocol))
+	case len(payload) >= 2:
+		closeErr.Code = int(binary.BigEndian.Uint16(payload))
+		closeErr.Reason = string(payload[2:])
+
+		if !validCloseCode(closeErr.Code) {
+			return c.fail(CloseProtocolError, fmt.Errorf("%w: invalid close code %d", ErrProtocol, closeErr.Code))
+		}
+
+		if !utf8.ValidString(closeErr.Reason) {
+			return c.fail(CloseInvalidPayload, ErrInvalidUTF8)
+		}
+	}
+
+	// echoes the code, the close without a code is echoed without a code.
+	echo := payload
+	if len(echo) > 2 {
+		echo = echo[:2]
+	}
+
+	if err := c.writeFrame(true, opClose, echo); err != nil && !errors.Is(err, ErrCloseSent) {
+		return err
+	}
+
+	return closeErr
+}
+
+// fail sends the close frame with the code and closes the connection.
+func (c *Conn) fail(code int, err error) error {
+	_ = c.WriteClose(code, "")
+	_ = c.conn.Close()
+	return err
+}
+
+// validCloseCode reports whether the close code can be sent in a close frame.
+func validCloseCode(code int) bool {
+	switch {
+	case code >= CloseNormal && code <= CloseUnsupportedData:
+		return true
+	case code >= CloseInvalidPayload && code <= CloseInternalError:
+		return true
+	case code >= 3000 && code <= 4999:
+		return true
+	default:
+		return false
+	}
+}
+
+// maskBytes masks or unmasks the data in place.
+func maskBytes(key [4]byte, data []byte) {
+	for i := range data {
+		data[i] ^= key[i&3]
+	}
+}
@@ -0,0 +1,271 @@
+// Package websocket implements the WebSocket protocol for the mux handlers.
+// see: https://datatracker.ietf.org/doc/html/rfc6455.
+//
+// The connection is upgraded inside a mux.Handler, so the global and the route
+// middlewares, such as the request ID, the logger and the authentication, run
+// before the handshake and can still reject the request with a normal response.
+// The handler must not be under mux.Timeout, which can not hijack the
+// connection. The extensions, such as permessage-deflate, are not supported.
+package websocket
+
+import (
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/josestg/justforfun/pkg/mux"
+)
+
+// DefaultReadLimit is the default maximum size of a received message.
+const DefaultReadLimit = 1 << 20
+
+// acceptGUID is the GUID that the Sec-WebSocket-Accept is computed with.
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+var (
+	// ErrBadHandshake is an error when the opening handshake fails.
+	ErrBadHandshake = errors.New("websocket: bad handshake")
+
+	// ErrHijackUnsupported is an error when the ResponseWriter can not be hijacked.
+	ErrHijackUnsupported = errors.New("websocket: hijacking unsupported")
+)
+
+// Option is an option type that can be used to customize the Conn created by
+// Upgrade, Handler or Dial.
+type Option func(c *config)
+
+// config holds the options.
+type config struct {
+	readLimit    int64
+	subprotocols []string
+	checkOrigin  func(r *http.Request) bool
+	pongHandler  func(data []byte)
+	header       http.Header
+	tlsConfig    *tls.Config
+}
+
+// newConfig creates the config with the options applied.
+func newConfig(options []Option) config {
+	c := config{
+		readLimit:   DefaultReadLimit,
+		checkOrigin: sameOrigin,
+	}
+
+	for _, fn := range options {
+		fn(&c)
+	}
+
+	return c
+}
+
+// WithReadLimit sets the maximum size of a received message, the larger
+// message closes the connection with CloseMessageTooBig.
+// The default is DefaultReadLimit.
+func WithReadLimit(n int64) Option {
+	return func(c *config) {
+		c.readLimit = n
+	}
+}
+
+// WithSubprotocols sets the supported subprotocols in the order of preference.
+// The server selects the first one the client requests, and the client
+// requests all of them.
+func WithSubprotocols(protocols ...string) Option {
+	return func(c *config) {
+		c.subprotocols = protocols
+	}
+}
+
+// WithCheckOrigin sets the function that allows the Origin of the request.
+// By default, the request with the Origin is allowed only if the Origin host
+// is the request host, which protects the browsers from the cross-site
+// WebSocket hijacking. It is ignored by Dial.
+func WithCheckOrigin(fn func(r *http.Request) bool) Option {
+	return func(c *config) {
+		c.checkOrigin = fn
+	}
+}
+
+// WithPongHandler sets the function that is called with the payload of each
+// received pong. The pongs are ignored by default.
+func WithPongHandler(fn func(data []byte)) Option {
+	return func(c *config) {
+		c.pongHandler = fn
+	}
+}
+
+// Upgrade completes the opening handshake and takes over the connection.
+//
+// If the request is not a valid handshake, nothing is written and it returns
+// the *mux.Error that the router writes as the response: 400 Bad Request, or
+// 426 Upgrade Required for an unsupported version, or 403 Forbidden if the
+// Origin is not allowed. The headers set by the middlewares, such as the
+// X-Request-ID, are sent with the 101 Switching Protocols response.
+func Upgrade(w http.ResponseWriter, r *http.Request, options ...Option) (*Conn, error) {
+	cfg := newConfig(options)
+
+	if r.Method != http.MethodGet {
+		return nil, badHandshake("the method must be GET")
+	}
+
+	if !headerContains(r.Header, "Connection", "upgrade") || !headerContains(r.Header, "Upgrade", "websocket") {
+		return nil, badHandshake("the request must upgrade the connection to websocket")
+	}
+
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		err := mux.NewError(http.StatusUpgradeRequired, "unsupported_version", "the websocket version must be 13")
+		err.Cause = ErrBadHandshake
+		return nil, err
+	}
+
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if b, err := base64.StdEncoding.DecodeString(key); err != nil || len(b) != 16 {
+		return nil, badHandshake("the Sec-WebSocket-Key must be a base64-encoded 16-byte value")
+	}
+
+	if !cfg.checkOrigin(r) {
+		err := mux.NewError(http.StatusForbidden, "forbidden_origin", "the origin is not allowed")
+		err.Cause = ErrBadHandshake
+		return nil, err
+	}
+
+	subprotocol := selectSubprotocol(cfg.subprotocols, headerTokens(r.Header, "Sec-WebSocket-Protocol"))
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		return nil, ErrHijackUnsupported
+	}
+
+	netConn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	if state, err := mux.GetState(r.Context()); err == nil {
+		state.StatusCode = http.StatusSwitchingProtocols
+	}
+
+	// the server may have set the deadlines of the request,
+	// the connection is long-lived, so it manages its own deadlines.
+	_ = netConn.SetDeadline(time.Time{})
+
+	h := w.Header().Clone()
+	h.Set("Upgrade", "websocket")
+	h.Set("Connection", "Upgrade")
+	h.Set("Sec-WebSocket-Accept", acceptKey(key))
+	if subprotocol != "" {
+		h.Set("Sec-WebSocket-Protocol", subprotocol)
+	}
+
+	bw := rw.Writer
+	bw.WriteString("HTTP/1.1 101 Switching Protocols\r\n")
+	if err := h.Write(bw); err != nil {
+		netConn.Close()
+		return nil, err
+	}
+	bw.WriteString("\r\n")
+	if err := bw.Flush(); err != nil {
+		netConn.Close()
+		return nil, err
+	}
+
+	return newConn(netConn, rw.Reader, bw, false, subprotocol, cfg), nil
+}
+
+// Handler creates a mux.Handler that upgrades the request and serves the
+// connection by fn, then closes it. The handshake errors are returned, so
+// the router responds them. If fn returns an error, the connection is closed
+// with CloseInternalError, and the error is recorded into the mux.State for
+// logging. The *CloseError of the peer is not an error.
+func Handler(fn func(c *Conn, r *http.Request) error, options ...Option) mux.Handler {
+	h := func(w http.ResponseWriter, r *http.Request) error {
+		c, err := Upgrade(w, r, options...)
+		if err != nil {
+			return err
+		}
+		defer c.Close()
+
+		err = fn(c, r)
+
+		var closeErr *CloseError
+		if err == nil || errors.As(err, &closeErr) {
+			_ = c.WriteClose(CloseNormal, "")
+			return nil
+		}
+
+		_ = c.WriteClose(CloseInternalError, "")
+		return err
+	}
+
+	return mux.HandlerFunc(h)
+}
+
+// badHandshake creates the 400 Bad Request error of the invalid handshake.
+func badHandshake(detail string) error {
+	err := mux.NewError(http.StatusBadRequest, "bad_handshake", detail)
+	err.Cause = ErrBadHandshake
+	return err
+}
+
+// acceptKey computes the Sec-WebSocket-Accept of the Sec-WebSocket-Key.
+func acceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// headerTokens returns the comma-separated tokens of the header values.
+func headerTokens(h http.Header, name string) []string {
+	var tokens []string
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				tokens = append(tokens, t)
+			}
+		}
+	}
+	return tokens
+}
+
+// headerContains reports whether the header has the token, case-insensitively.
+func headerContains(h http.Header, name, token string) bool {
+	for _, t := range headerTokens(h, name) {
+		if strings.EqualFold(t, token) {
+			return true
+		}
+	}
+	return false
+}
+
+// selectSubprotocol returns the first supported subprotocol that is requested.
+func selectSubprotocol(supported, requested []string) string {
+	for _, s := range supported {
+		for _, r := range requested {
+			if s == r {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+// sameOrigin allows the request without the Origin, which is not a browser,
+// or with the Origin of the same host.
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+
+	return strings.EqualFold(u.Host, r.Host)
+}
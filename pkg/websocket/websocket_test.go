@@ -0,0 +1,305 @@
+package websocket
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/josestg/justforfun/pkg/compress"
+	"github.com/josestg/justforfun/pkg/mux"
+)
+
+func TestAcceptKey(t *testing.T) {
+	// the example of RFC 6455 section 1.3.
+	if got, want := acceptKey("dGhlIHNhbXBsZSBub25jZQ=="), "s3pPLMBiTxaQ9kYGzzhZRbK+xOo="; got != want {
+		t.Fatalf("expecting %s but got %s", want, got)
+	}
+}
+
+func TestUpgrade_BadHandshake(t *testing.T) {
+	valid := func() *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "http://example.com/ws", nil)
+		r.Header.Set("Connection", "keep-alive, Upgrade")
+		r.Header.Set("Upgrade", "websocket")
+		r.Header.Set("Sec-WebSocket-Version", "13")
+		r.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+		return r
+	}
+
+	tests := map[string]struct {
+		modify func(r *http.Request)
+		status int
+	}{
+		"method":  {func(r *http.Request) { r.Method = http.MethodPost }, http.StatusBadRequest},
+		"upgrade": {func(r *http.Request) { r.Header.Del("Upgrade") }, http.StatusBadRequest},
+		"version": {func(r *http.Request) { r.Header.Set("Sec-WebSocket-Version", "8") }, http.StatusUpgradeRequired},
+		"key":     {func(r *http.Request) { r.Header.Set("Sec-WebSocket-Key", "short") }, http.StatusBadRequest},
+		"origin":  {func(r *http.Request) { r.Header.Set("Origin", "https://evil.example") }, http.StatusForbidden},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := valid()
+			tt.modify(r)
+
+			rec := httptest.NewRecorder()
+			_, err := Upgrade(rec, r)
+
+			var httpErr *mux.Error
+			if !errors.As(err, &httpErr) || httpErr.Status != tt.status {
+				t.Fatalf("expecting status %d but got %v", tt.status, err)
+			}
+
+			if !errors.Is(err, ErrBadHandshake) {
+				t.Fatalf("expecting ErrBadHandshake but got %v", err)
+			}
+		})
+	}
+
+	r := valid()
+	r.Header.Set("Origin", "http://example.com")
+	if _, err := Upgrade(httptest.NewRecorder(), r); !errors.Is(err, ErrHijackUnsupported) {
+		t.Fatalf("expecting the same origin is allowed but got %v", err)
+	}
+}
+
+// newServer serves the handler behind the router with a compression and
+// a token-checking middleware, and records the final state of the requests.
+func newServer(t *testing.T, handler mux.Handler) (*httptest.Server, chan *mux.State) {
+	states := make(chan *mux.State, 1)
+	observer := func(handler mux.Handler) mux.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) error {
+			err := handler.ServeHTTP(w, r)
+			state, _ := mux.GetState(r.Context())
+			states <- state
+			return err
+		}
+		return mux.HandlerFunc(fn)
+	}
+
+	authenticate := func(handler mux.Handler) mux.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) error {
+			if r.Header.Get("Authorization") != "Bearer secret" {
+				return mux.NewError(http.StatusUnauthorized, "missing_credentials", "missing bearer token")
+			}
+			return handler.ServeHTTP(w, r)
+		}
+		return mux.HandlerFunc(fn)
+	}
+
+	router := mux.NewRouter(make(mux.ShutdownChannel, 1), observer, compress.Middleware(), authenticate)
+	router.Handle("GET /ws", handler)
+
+	srv := httptest.NewServer(router)
+	t.Cleanup(srv.Close)
+	return srv, states
+}
+
+var authorized = WithHeader(http.Header{"Authorization": {"Bearer secret"}})
+
+// echo echoes the messages until the client closes.
+func echo(c *Conn, _ *http.Request) error {
+	for {
+		typ, data, err := c.ReadMessage()
+		if err != nil {
+			return err
+		}
+
+		if err := c.WriteMessage(typ, data); err != nil {
+			return err
+		}
+	}
+}
+
+func TestHandler_Echo(t *testing.T) {
+	srv, states := newServer(t, Handler(echo, WithSubprotocols("chat.v2", "chat.v1")))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	c, res, err := Dial(ctx, srv.URL+"/ws", authorized, WithSubprotocols("chat.v1"))
+	if err != nil {
+		t.Fatalf("expecting nil error but got %v", err)
+	}
+	defer c.Close()
+
+	if res.Header.Get(mux.RequestIDHeader) == "" {
+		t.Fatalf("expecting the request id of the middleware in the handshake response")
+	}
+
+	if got := c.Subprotocol(); got != "chat.v1" {
+		t.Fatalf("expecting subprotocol chat.v1 but got %q", got)
+	}
+
+	if err := c.WriteMessage(TextMessage, []byte("hello")); err != nil {
+		t.Fatalf("expecting nil error but got %v", err)
+	}
+
+	// the fragmented message with a ping between the fragments.
+	w, _ := c.NextWriter(BinaryMessage)
+	w.Write([]byte{1, 2})
+	if err := c.Ping([]byte("ping")); err != nil {
+		t.Fatalf("expecting nil error but got %v", err)
+	}
+	w.Write([]byte{3})
+	if err := w.Close(); err != nil {
+		t.Fatalf("expecting nil error but got %v", err)
+	}
+
+	large := bytes.Repeat([]byte("x"), 70000)
+	c.WriteMessage(BinaryMessage, large)
+
+	expected := []struct {
+		typ  MessageType
+		data []byte
+	}{
+		{TextMessage, []byte("hello")},
+		{BinaryMessage, []byte{1, 2, 3}},
+		{BinaryMessage, large},
+	}
+
+	for _, e := range expected {
+		typ, data, err := c.ReadMessage()
+		if err != nil {
+			t.Fatalf("expecting nil error but got %v", err)
+		}
+
+		if typ != e.typ || !bytes.Equal(data, e.data) {
+			t.Fatalf("expecting %d message of %d bytes but got %d of %d bytes", e.typ, len(e.data), typ, len(data))
+		}
+	}
+
+	if err := c.WriteClose(4000, "bye"); err != nil {
+		t.Fatalf("expecting nil error but got %v", err)
+	}
+
+	if err := c.WriteMessage(TextMessage, []byte("late")); !errors.Is(err, ErrCloseSent) {
+		t.Fatalf("expecting ErrCloseSent but got %v", err)
+	}
+
+	_, _, err = c.ReadMessage()
+	if code := CloseCode(err); code != 4000 {
+		t.Fatalf("expecting the echoed close code 4000 but got %v", err)
+	}
+
+	state := <-states
+	if state.StatusCode != http.StatusSwitchingProtocols || state.Err != nil {
+		t.Fatalf("expecting status 101 without error but got %d %v", state.StatusCode, state.Err)
+	}
+}
+
+func TestHandler_Unauthorized(t *testing.T) {
+	srv, _ := newServer(t, Handler(echo))
+
+	_, res, err := Dial(context.Background(), srv.URL+"/ws")
+	if !errors.Is(err, ErrBadHandshake) {
+		t.Fatalf("expecting ErrBadHandshake but got %v", err)
+	}
+
+	if res.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expecting status 401 but got %d", res.StatusCode)
+	}
+}
+
+func TestConn_PingPong(t *testing.T) {
+	srv, _ := newServer(t, Handler(echo))
+
+	pongs := make(chan string, 1)
+	c, _, err := Dial(context.Background(), srv.URL+"/ws", authorized, WithPongHandler(func(data []byte) {
+		pongs <- string(data)
+	}))
+	if err != nil {
+		t.Fatalf("expecting nil error but got %v", err)
+	}
+	defer c.Close()
+
+	c.Ping([]byte("are you there"))
+	c.WriteMessage(TextMessage, []byte("done"))
+
+	// the pong is handled while reading the next message.
+	if _, _, err := c.ReadMessage(); err != nil {
+		t.Fatalf("expecting nil error but got %v", err)
+	}
+
+	select {
+	case got := <-pongs:
+		if got != "are you there" {
+			t.Fatalf("expecting the ping payload but got %q", got)
+		}
+	default:
+		t.Fatalf("expecting a pong")
+	}
+}
+
+func TestConn_Violations(t *testing.T) {
+	tests := map[string]struct {
+		options []Option
+		send    func(c *Conn) error
+		code    int
+	}{
+		"read limit": {
+			options: []Option{WithReadLimit(8)},
+			send:    func(c *Conn) error { return c.WriteMessage(BinaryMessage, make([]byte, 9)) },
+			code:    CloseMessageTooBig,
+		},
+		"invalid utf-8": {
+			send: func(c *Conn) error { return c.WriteMessage(TextMessage, []byte{0xff, 0xfe}) },
+			code: CloseInvalidPayload,
+		},
+		"unmasked frame": {
+			send: func(c *Conn) error {
+				c.client = false
+				return c.WriteMessage(TextMessage, []byte("hi"))
+			},
+			code: CloseProtocolError,
+		},
+		"continuation without message": {
+			send: func(c *Conn) error { return c.writeFrame(true, opContinuation, []byte("hi")) },
+			code: CloseProtocolError,
+		},
+		"reserved opcode": {
+			send: func(c *Conn) error { return c.writeFrame(true, 0x3, nil) },
+			code: CloseProtocolError,
+		},
+		"fragmented ping": {
+			send: func(c *Conn) error { return c.writeFrame(false, opPing, nil) },
+			code: CloseProtocolError,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			srv, _ := newServer(t, Handler(echo, tt.options...))
+
+			c, _, err := Dial(context.Background(), srv.URL+"/ws", authorized)
+			if err != nil {
+				t.Fatalf("expecting nil error but got %v", err)
+			}
+			defer c.Close()
+
+			if err := tt.send(c); err != nil {
+				t.Fatalf("expecting nil error but got %v", err)
+			}
+
+			c.client = true
+			c.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+			_, _, err = c.ReadMessage()
+			if code := CloseCode(err); code != tt.code {
+				t.Fatalf("expecting close code %d but got %v", tt.code, err)
+			}
+		})
+	}
+}
+
+func TestDial_UnsupportedScheme(t *testing.T) {
+	_, _, err := Dial(context.Background(), "ftp://example.com")
+	if err == nil || !strings.Contains(err.Error(), "unsupported scheme") {
+		t.Fatalf("expecting unsupported scheme error but got %v", err)
+	}
+}
@@ -0,0 +1,19 @@
+-- up script here...
+CREATE TABLE IF NOT EXISTS idempotency_keys
+(
+    key             TEXT         NOT NULL PRIMARY KEY,
+    fingerprint     CHAR(64)     NOT NULL,
+    response_status INT,
+    response_header JSONB,
+    response_body   BYTEA,
+    locked_until    TIMESTAMPTZ  NOT NULL,
+    date_created    TIMESTAMPTZ  NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idempotency_keys_date_created_index ON idempotency_keys (date_created);
+
+---+split+---
+
+-- down script here...
+DROP INDEX IF EXISTS idempotency_keys_date_created_index;
+DROP TABLE IF EXISTS idempotency_keys;